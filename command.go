@@ -0,0 +1,76 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/storage"
+)
+
+// commands run by `wetalk <command> [flags]` instead of web server.
+var commands = map[string]func(args []string) error{
+	"migrate-storage": migrateStorage,
+}
+
+func runCommand(args []string) {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "unknown command `%s`, available commands: %v\n", args[0], names)
+		os.Exit(2)
+	}
+
+	if err := cmd(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// migrateStorage copies uploaded images and avatars between storage backends,
+// switch [storage] backend in app.ini after it succeeded.
+func migrateStorage(args []string) error {
+	flags := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	from := flags.String("from", "local", "storage backend copy from [local|qiniu|s3]")
+	to := flags.String("to", "", "storage backend copy to [local|qiniu|s3]")
+	flags.Parse(args)
+
+	src, err := storage.Open(*from)
+	if err != nil {
+		return err
+	}
+	dst, err := storage.Open(*to)
+	if err != nil {
+		return err
+	}
+
+	result, err := attachment.MigrateStorage(dst, src)
+	fmt.Printf("copied: %d, skipped: %d, failed: %d\n", result.Copied, result.Skipped, result.Failed)
+	if err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d files failed, see logs and run it again", result.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package account implements self-service account deletion and personal data export.
+package account
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+var (
+	ErrGhostUser      = errors.New("ghost user can't be deleted")
+	ErrGhostNameTaken = errors.New("name of ghost user is taken by a normal user, change ghost_user in [account]")
+)
+
+// password of ghost user, no password can match it
+const ghostPassword = "!"
+
+// get pending deletion of user
+func GetDeletion(deletion *models.AccountDeletion, user *models.User) bool {
+	return models.AccountDeletions().Filter("User", user.Id).Limit(1).One(deletion) == nil
+}
+
+// request deletion of user, account is deleted after grace days
+func RequestDeletion(user *models.User, reason string) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	if GetDeletion(&deletion, user) {
+		return &deletion, nil
+	}
+
+	deletion = models.AccountDeletion{
+		User:     user,
+		Reason:   reason,
+		Deadline: time.Now().AddDate(0, 0, setting.AccountDeleteGraceDays),
+	}
+	if err := deletion.Insert(); err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// cancel pending deletion of user
+func CancelDeletion(user *models.User) error {
+	_, err := models.AccountDeletions().Filter("User", user.Id).Delete()
+	return err
+}
+
+// get the ghost user owns content of deleted accounts, create it if not exist,
+// normal user with the ghost name is never used as the ghost
+func GhostUser() (*models.User, error) {
+	var user models.User
+	if err := models.Users().Filter("IsGhost", true).Limit(1).One(&user); err == nil {
+		return &user, nil
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	user = models.User{UserName: setting.AccountGhostUser}
+	if err := user.Read("UserName"); err == nil {
+		// ghost created before it is flagged has the unusable password
+		if user.Password != ghostPassword {
+			return nil, ErrGhostNameTaken
+		}
+		user.IsGhost = true
+		if err := user.Update("IsGhost"); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	user = models.User{
+		UserName: setting.AccountGhostUser,
+		NickName: setting.AccountGhostUser,
+		Email:    setting.AccountGhostUser + "@" + setting.AppHost,
+		Password: ghostPassword,
+		IsForbid: true,
+		IsGhost:  true,
+	}
+	if err := user.Insert(); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// fields of user content kept after deletion, reassigned to ghost user
+var ghostFields = []struct {
+	table  string
+	fields []string
+}{
+	{"post", []string{"User", "LastReply", "LastAuthor"}},
+	{"comment", []string{"User"}},
+	{"page", []string{"User", "LastAuthor"}},
+	{"image", []string{"User"}},
+	{"attachment", []string{"User"}},
+	{"notification", []string{"FromUser"}},
+	{"report", []string{"Reporter", "User", "Handler"}},
+	{"held_content", []string{"User", "Moderator"}},
+	{"ban", []string{"Operator", "Lifter"}},
+	{"audit_log", []string{"Actor"}},
+}
+
+// private data of user removed with the account
+var privateFields = []struct {
+	table string
+	field string
+}{
+	{"follow", "User"},
+	{"follow", "FollowUser"},
+	{"favorite_post", "User"},
+	{"follow_topic", "User"},
+	{"notification", "ToUser"},
+	{"access_token", "User"},
+	{"access_token", "App__User"},
+	{"o_auth_code", "User"},
+	{"o_auth_code", "App__User"},
+	{"o_auth_app", "User"},
+	{"two_factor", "User"},
+	{"recovery_code", "User"},
+	{"login_session", "User"},
+	{"role_grant", "User"},
+	{"content_hash", "User"},
+	{"ban", "User"},
+	{"account_deletion", "User"},
+	// registered by social-auth, connected social logins can be connected again
+	{"user_social", "Uid"},
+}
+
+// counters of other objects changed by removing the user's follows and favorites
+var counterQueries = []string{
+	"UPDATE user SET followers = followers - 1 WHERE id IN (SELECT follow_user_id FROM follow WHERE user_id = ?)",
+	"UPDATE user SET following = following - 1 WHERE id IN (SELECT user_id FROM follow WHERE follow_user_id = ?)",
+	"UPDATE topic SET followers = followers - 1 WHERE id IN (SELECT topic_id FROM follow_topic WHERE user_id = ?)",
+	"UPDATE post SET favorites = favorites - 1 WHERE id IN (SELECT post_id FROM favorite_post WHERE user_id = ? AND is_fav = 1)",
+}
+
+// delete account now, public content is kept as ghost user's
+func DeleteAccount(user *models.User) error {
+	ghost, err := GhostUser()
+	if err != nil {
+		return err
+	}
+	if ghost.Id == user.Id {
+		return ErrGhostUser
+	}
+
+	// counters are changed only once even if deletion failed and is retried
+	o := orm.NewOrm()
+	if err := o.Begin(); err != nil {
+		return err
+	}
+	if err := deleteAccount(o, user, ghost); err != nil {
+		o.Rollback()
+		return err
+	}
+	if err := o.Commit(); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(user.AvatarKey, "avatar/") {
+		attachment.DeleteAvatar(user.AvatarKey)
+	}
+	return nil
+}
+
+// run all queries of deleting account in transaction of o
+func deleteAccount(o orm.Ormer, user, ghost *models.User) error {
+	for _, query := range counterQueries {
+		if _, err := o.Raw(query, user.Id).Exec(); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range ghostFields {
+		for _, field := range g.fields {
+			if _, err := o.QueryTable(g.table).Filter(field, user.Id).Update(orm.Params{field: ghost.Id}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range privateFields {
+		if _, err := o.QueryTable(p.table).Filter(p.field, user.Id).Delete(); err != nil {
+			return err
+		}
+	}
+
+	_, err := o.Delete(user)
+	return err
+}
+
+// delete accounts passed grace period
+func Run() {
+	var deletions []models.AccountDeletion
+	if _, err := models.AccountDeletions().Filter("Deadline__lte", time.Now()).RelatedSel().All(&deletions); err != nil {
+		beego.Error("account.Run: ", err)
+		return
+	}
+
+	for _, deletion := range deletions {
+		if err := DeleteAccount(deletion.User); err != nil {
+			beego.Error("account.Run: delete user ", deletion.User.Id, " ", err)
+			continue
+		}
+		beego.Info("account.Run: deleted user ", deletion.User.Id)
+	}
+}
+
+// start background worker for deleting accounts, it is not started if the
+// ghost name is taken by a normal user
+func StartWorker() {
+	if _, err := GhostUser(); err != nil {
+		beego.Error("account.StartWorker: ", err)
+		return
+	}
+
+	go func() {
+		for {
+			Run()
+			time.Sleep(time.Hour)
+		}
+	}()
+}
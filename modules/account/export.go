@@ -0,0 +1,217 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/varding/wetalk/modules/models"
+)
+
+type exportProfile struct {
+	Id          int
+	UserName    string
+	NickName    string
+	Email       string
+	PublicEmail bool
+	Url         string
+	Company     string
+	Location    string
+	Info        string
+	Github      string
+	Twitter     string
+	Google      string
+	Weibo       string
+	Linkedin    string
+	Facebook    string
+	Followers   int
+	Following   int
+	Created     time.Time
+}
+
+type exportPost struct {
+	Id       int
+	Url      string
+	Title    string
+	Topic    string
+	Category string
+	Content  string
+	Created  time.Time
+	Updated  time.Time
+}
+
+type exportComment struct {
+	Id      int
+	Url     string
+	Post    string
+	Floor   int
+	Message string
+	Created time.Time
+}
+
+type exportFavorite struct {
+	Url     string
+	Title   string
+	Created time.Time
+}
+
+type exportFollows struct {
+	Following []string
+	Followers []string
+	Topics    []string
+}
+
+func writeJson(zw *zip.Writer, name string, data interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func writeMarkdown(zw *zip.Writer, name, title, content string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "# %s\n\n%s\n", title, content)
+	return err
+}
+
+// Export writes zip archive of user's personal data, json files with
+// markdown files of posts and comments
+func Export(user *models.User, out io.Writer) error {
+	zw := zip.NewWriter(out)
+
+	profile := exportProfile{
+		Id:          user.Id,
+		UserName:    user.UserName,
+		NickName:    user.NickName,
+		Email:       user.Email,
+		PublicEmail: user.PublicEmail,
+		Url:         user.Url,
+		Company:     user.Company,
+		Location:    user.Location,
+		Info:        user.Info,
+		Github:      user.Github,
+		Twitter:     user.Twitter,
+		Google:      user.Google,
+		Weibo:       user.Weibo,
+		Linkedin:    user.Linkedin,
+		Facebook:    user.Facebook,
+		Followers:   user.Followers,
+		Following:   user.Following,
+		Created:     user.Created,
+	}
+	if err := writeJson(zw, "profile.json", profile); err != nil {
+		return err
+	}
+
+	var posts []models.Post
+	if _, err := models.Posts().Filter("User", user.Id).OrderBy("Id").RelatedSel("Topic", "Category").Limit(-1).All(&posts); err != nil {
+		return err
+	}
+	data := make([]exportPost, 0, len(posts))
+	for _, post := range posts {
+		data = append(data, exportPost{
+			Id:       post.Id,
+			Url:      post.Link(),
+			Title:    post.Title,
+			Topic:    post.Topic.Name,
+			Category: post.Category.Name,
+			Content:  post.Content,
+			Created:  post.Created,
+			Updated:  post.Updated,
+		})
+		if err := writeMarkdown(zw, fmt.Sprintf("posts/%d.md", post.Id), post.Title, post.Content); err != nil {
+			return err
+		}
+	}
+	if err := writeJson(zw, "posts.json", data); err != nil {
+		return err
+	}
+
+	var comments []models.Comment
+	if _, err := models.Comments().Filter("User", user.Id).OrderBy("Id").RelatedSel("Post").Limit(-1).All(&comments); err != nil {
+		return err
+	}
+	cdata := make([]exportComment, 0, len(comments))
+	for _, comment := range comments {
+		cdata = append(cdata, exportComment{
+			Id:      comment.Id,
+			Url:     fmt.Sprintf("%s#reply%d", comment.Post.Link(), comment.Floor),
+			Post:    comment.Post.Title,
+			Floor:   comment.Floor,
+			Message: comment.Message,
+			Created: comment.Created,
+		})
+		title := fmt.Sprintf("%s #%d", comment.Post.Title, comment.Floor)
+		if err := writeMarkdown(zw, fmt.Sprintf("comments/%d.md", comment.Id), title, comment.Message); err != nil {
+			return err
+		}
+	}
+	if err := writeJson(zw, "comments.json", cdata); err != nil {
+		return err
+	}
+
+	var favorites []models.FavoritePost
+	if _, err := models.FavoritePosts().Filter("User", user.Id).Filter("IsFav", true).OrderBy("Id").RelatedSel("Post").Limit(-1).All(&favorites); err != nil {
+		return err
+	}
+	fdata := make([]exportFavorite, 0, len(favorites))
+	for _, fav := range favorites {
+		fdata = append(fdata, exportFavorite{Url: fav.Post.Link(), Title: fav.Post.Title, Created: fav.Created})
+	}
+	if err := writeJson(zw, "favorites.json", fdata); err != nil {
+		return err
+	}
+
+	follows := exportFollows{Following: []string{}, Followers: []string{}, Topics: []string{}}
+	var list []models.Follow
+	if _, err := models.Follows().Filter("User", user.Id).RelatedSel("FollowUser").Limit(-1).All(&list); err != nil {
+		return err
+	}
+	for _, f := range list {
+		follows.Following = append(follows.Following, f.FollowUser.UserName)
+	}
+	list = nil
+	if _, err := models.Follows().Filter("FollowUser", user.Id).RelatedSel("User").Limit(-1).All(&list); err != nil {
+		return err
+	}
+	for _, f := range list {
+		follows.Followers = append(follows.Followers, f.User.UserName)
+	}
+	var topics []models.FollowTopic
+	if _, err := models.FollowTopics().Filter("User", user.Id).RelatedSel("Topic").Limit(-1).All(&topics); err != nil {
+		return err
+	}
+	for _, t := range topics {
+		follows.Topics = append(follows.Topics, t.Topic.Name)
+	}
+	if err := writeJson(zw, "follows.json", follows); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,97 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/avatar"
+	"github.com/varding/wetalk/modules/storage"
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// AvatarSizeKey returns storage key of uploaded avatar cropped and resized to size.
+func AvatarSizeKey(key string, size int) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + utils.ToStr(size) + ext
+}
+
+// AvatarVariant returns storage key and mime type of uploaded avatar in size,
+// missing sizes are generated from the uploaded one.
+func AvatarVariant(key string, size int) (string, string, error) {
+	sizeKey := AvatarSizeKey(key, size)
+	ext := imageExtCode(path.Ext(key))
+	if imageExists(sizeKey) {
+		return sizeKey, ImageMime(ext), nil
+	}
+
+	generateLock.Lock()
+	defer generateLock.Unlock()
+
+	// generated while waiting
+	if imageExists(sizeKey) {
+		return sizeKey, ImageMime(ext), nil
+	}
+
+	store := storage.Current()
+	r, err := store.Get(key)
+	if err != nil {
+		return "", "", err
+	}
+	im, _, err := DecodeImage(r, ImageMime(ext), key)
+	r.Close()
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := encodeImage(avatar.Resize(im, size), ext)
+	if err != nil {
+		return "", "", err
+	}
+	if err := store.Put(sizeKey, bytes.NewReader(data), int64(len(data)), ImageMime(ext)); err != nil {
+		return "", "", err
+	}
+	return sizeKey, ImageMime(ext), nil
+}
+
+// SaveAvatarSizes generates all sizes of uploaded avatar.
+func SaveAvatarSizes(key string) error {
+	for _, size := range avatar.Sizes {
+		if _, _, err := AvatarVariant(key, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteAvatar removes uploaded avatar and its sizes from storage.
+func DeleteAvatar(key string) {
+	keys := []string{key}
+	for _, size := range avatar.Sizes {
+		keys = append(keys, AvatarSizeKey(key, size))
+	}
+
+	forgetImageKeys(keys)
+	store := storage.Current()
+	for _, k := range keys {
+		if err := store.Delete(k); err != nil {
+			beego.Error("DeleteAvatar: ", k, err)
+		}
+	}
+}
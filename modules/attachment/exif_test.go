@@ -0,0 +1,119 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	. "github.com/varding/wetalk/modules/utils"
+)
+
+// exifSegment builds APP1 segment with orientation tag in big endian tiff.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00*\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func TestJpegOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	ThrowFailNow(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	data := buf.Bytes()
+	ThrowFail(t, AssertIs(jpegOrientation(data), 1))
+
+	withExif := append(append(append([]byte{}, data[:2]...), exifSegment(6)...), data[2:]...)
+	ThrowFail(t, AssertIs(jpegOrientation(withExif), 6))
+
+	stripped := stripJpeg(withExif)
+	ThrowFail(t, AssertIs(jpegOrientation(stripped), 1))
+	ThrowFail(t, AssertIs(bytes.Equal(stripped, data), true))
+
+	_, err := jpeg.Decode(bytes.NewReader(stripped))
+	ThrowFail(t, err)
+}
+
+func TestJpegBadSegments(t *testing.T) {
+	var buf bytes.Buffer
+	ThrowFailNow(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	data := buf.Bytes()
+
+	// segment length below 2 must not panic
+	short := append(append([]byte{}, data[:2]...), 0xFF, 0xD0, 0x00, 0x00)
+	short = append(short, data[2:]...)
+	ThrowFail(t, AssertIs(jpegOrientation(short), 1))
+	ThrowFail(t, AssertIs(bytes.Equal(stripJpeg(short), short), true))
+
+	// fill bytes before markers are skipped
+	withExif := append(append([]byte{}, data[:2]...), 0xFF, 0xFF)
+	withExif = append(append(withExif, exifSegment(6)...), data[2:]...)
+	ThrowFail(t, AssertIs(jpegOrientation(withExif), 6))
+	ThrowFail(t, AssertIs(bytes.Equal(stripJpeg(withExif), data), true))
+}
+
+func TestOrientImage(t *testing.T) {
+	// 2x3 image with red top left pixel
+	img := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	red := color.RGBA{255, 0, 0, 255}
+	img.Set(0, 0, red)
+
+	// top left goes to top right after rotating 90 degrees clockwise
+	rotated := orientImage(img, 6)
+	ThrowFail(t, AssertIs(rotated.Bounds().Dx(), 3))
+	ThrowFail(t, AssertIs(rotated.Bounds().Dy(), 2))
+	ThrowFail(t, AssertIs(rotated.At(2, 0), red))
+
+	// top left goes to bottom left after rotating 90 degrees counterclockwise
+	ThrowFail(t, AssertIs(orientImage(img, 8).At(0, 1), red))
+
+	ThrowFail(t, AssertIs(orientImage(img, 3).At(1, 2), red))
+	ThrowFail(t, AssertIs(orientImage(img, 2).At(1, 0), red))
+}
+
+func TestStripPng(t *testing.T) {
+	var buf bytes.Buffer
+	ThrowFailNow(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))))
+	data := buf.Bytes()
+
+	// insert text chunk before IEND, crc is not checked here
+	text := []byte("Comment\x00secret location")
+	chunk := make([]byte, 8)
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(append(chunk, text...), 0, 0, 0, 0)
+	iend := len(data) - 12
+	withText := append(append(append([]byte{}, data[:iend]...), chunk...), data[iend:]...)
+
+	stripped := stripPng(withText)
+	ThrowFail(t, AssertIs(bytes.Equal(stripped, data), true))
+
+	_, err := png.Decode(bytes.NewReader(stripped))
+	ThrowFail(t, err)
+}
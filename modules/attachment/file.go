@@ -0,0 +1,214 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/storage"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+var (
+	ErrFileType = errors.New("file type is not allowed")
+	ErrFileSize = errors.New("file size is too large")
+)
+
+// links of attachments in markdown content
+var attachmentLinkRegexp = regexp.MustCompile(`/attachment/([0-9a-zA-Z]+)`)
+
+// DetectMime sniffs mime type of file content without parameters like charset.
+func DetectMime(r io.ReadSeeker) (string, error) {
+	var buf [512]byte
+	n, err := io.ReadFull(r, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := r.Seek(0, 0); err != nil {
+		return "", err
+	}
+
+	mime := http.DetectContentType(buf[:n])
+	if i := strings.IndexRune(mime, ';'); i != -1 {
+		mime = mime[:i]
+	}
+	return strings.TrimSpace(mime), nil
+}
+
+// AllowedSize returns max size of files of mime type, false if the type is not allowed.
+func AllowedSize(mime string) (int64, bool) {
+	if size, ok := setting.AttachmentAllow[mime]; ok {
+		return size, true
+	}
+	if i := strings.IndexRune(mime, '/'); i != -1 {
+		if size, ok := setting.AttachmentAllow[mime[:i]+"/*"]; ok {
+			return size, true
+		}
+	}
+	return 0, false
+}
+
+// cleanFileName keeps base name of uploaded file without control characters.
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.Replace(name, "\\", "/", -1))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[len(runes)-100:])
+	}
+	return name
+}
+
+// SaveAttachment checks type and size of uploaded file then saves it into storage,
+// the attachment is removed later if no post or comment links it.
+func SaveAttachment(m *models.Attachment, r io.ReadSeeker, filename string) error {
+	mime, err := DetectMime(r)
+	if err != nil {
+		return err
+	}
+
+	maxSize, ok := AllowedSize(mime)
+	if !ok {
+		return ErrFileType
+	}
+
+	size, err := r.Seek(0, 2)
+	if err != nil {
+		return err
+	}
+	if size > maxSize {
+		return ErrFileSize
+	}
+	if _, err := r.Seek(0, 0); err != nil {
+		return err
+	}
+
+	m.Token = utils.GetRandomString(20)
+	m.Name = cleanFileName(filename)
+	m.Mime = mime
+	m.Size = size
+	m.Created = time.Now()
+
+	if err := storage.Current().Put(m.Key(), r, size, mime); err != nil {
+		return err
+	}
+
+	if err := m.Insert(); err != nil {
+		storage.Current().Delete(m.Key())
+		return err
+	}
+	return nil
+}
+
+// DeleteAttachment removes file of the attachment from storage and the record.
+func DeleteAttachment(m *models.Attachment) error {
+	if err := storage.Current().Delete(m.Key()); err != nil {
+		return err
+	}
+	return m.Delete()
+}
+
+func attachmentTokens(content string) []string {
+	var tokens []string
+	for _, match := range attachmentLinkRegexp.FindAllStringSubmatch(content, -1) {
+		tokens = append(tokens, match[1])
+	}
+	return tokens
+}
+
+// linkAttachments sets field of attachments linked by content to object,
+// attachments of object not linked anymore become orphaned.
+func linkAttachments(field string, id int, content string) error {
+	qs := models.Attachments().Filter(field, id)
+	tokens := attachmentTokens(content)
+	if len(tokens) > 0 {
+		qs = qs.Exclude("Token__in", tokens)
+	}
+	if _, err := qs.Update(orm.Params{field: nil}); err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	// attachments already linked by other post or comment are not moved
+	_, err := models.Attachments().Filter("Token__in", tokens).
+		Filter("Post__isnull", true).Filter("Comment__isnull", true).
+		Update(orm.Params{field: id})
+	return err
+}
+
+// LinkPostAttachments links attachments in post content to the post.
+func LinkPostAttachments(post *models.Post) error {
+	return linkAttachments("Post", post.Id, post.Content)
+}
+
+// LinkCommentAttachments links attachments in comment message to the comment.
+func LinkCommentAttachments(comment *models.Comment) error {
+	return linkAttachments("Comment", comment.Id, comment.Message)
+}
+
+// CleanOrphans removes attachments not linked by any post or comment after
+// orphan hours, attachments in content held for moderation are kept.
+func CleanOrphans() {
+	deadline := time.Now().Add(-time.Duration(setting.AttachmentOrphanHours) * time.Hour)
+
+	var attachments []*models.Attachment
+	if _, err := models.Attachments().Filter("Post__isnull", true).Filter("Comment__isnull", true).
+		Filter("Created__lt", deadline).Limit(1000).All(&attachments); err != nil {
+		beego.Error("attachment.CleanOrphans: ", err)
+		return
+	}
+
+	for _, m := range attachments {
+		if models.HeldContents().Filter("Status", setting.HELD_PENDING).
+			Filter("Content__contains", "/attachment/"+m.Token).Exist() {
+			continue
+		}
+		if err := DeleteAttachment(m); err != nil {
+			beego.Error("attachment.CleanOrphans: ", m.Token, err)
+		}
+	}
+}
+
+// start background worker for removing orphaned attachments
+func StartWorker() {
+	go func() {
+		for {
+			CleanOrphans()
+			time.Sleep(time.Hour)
+		}
+	}()
+}
@@ -0,0 +1,312 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/nfnt/resize"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/storage"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// DecodeImage checks image format by mime type or file name and decodes the image,
+// ext is the format code used as models.Image.Ext. First frame of animated gif is decoded.
+func DecodeImage(r io.Reader, mime string, filename string) (img image.Image, ext int, err error) {
+	// test image mime type
+	switch mime {
+	case "image/jpeg":
+		ext = 1
+	case "image/png":
+		ext = 2
+	case "image/gif":
+		ext = 3
+	default:
+		switch filepath.Ext(filename) {
+		case ".jpg":
+			ext = 1
+		case ".png":
+			ext = 2
+		case ".gif":
+			ext = 3
+		default:
+			return nil, 0, fmt.Errorf("unsupport image format `%s`", filename)
+		}
+	}
+
+	// decode image
+	switch ext {
+	case 1:
+		img, err = jpeg.Decode(r)
+	case 2:
+		img, err = png.Decode(r)
+	case 3:
+		img, err = decodeGifFrame(r)
+	}
+	return img, ext, err
+}
+
+// decodeGifFrame draws first frame of gif on its canvas, frames can be smaller than canvas.
+func decodeGifFrame(r io.Reader) (image.Image, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := g.Image[0]
+	bounds := frame.Bounds().Union(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	return canvas, nil
+}
+
+// CleanImage rotates jpeg image by EXIF orientation and removes metadata like GPS
+// location in EXIF, returns cleaned data and the rotated image.
+func CleanImage(data []byte, img image.Image, ext int) ([]byte, image.Image, error) {
+	switch ext {
+	case 1:
+		if orientation := jpegOrientation(data); orientation > 1 {
+			img = orientImage(img, orientation)
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+				return nil, nil, err
+			}
+			return buf.Bytes(), img, nil
+		}
+		return stripJpeg(data), img, nil
+	case 2:
+		return stripPng(data), img, nil
+	}
+	return data, img, nil
+}
+
+// ImageMime returns mime type of image format code.
+func ImageMime(ext int) string {
+	switch ext {
+	case 1:
+		return "image/jpeg"
+	case 2:
+		return "image/png"
+	case 3:
+		return "image/gif"
+	}
+	return "application/octet-stream"
+}
+
+// SaveImage saves uploaded image and its resized images. Same content uploaded
+// by the user before is returned as m, content uploaded by others shares their files.
+func SaveImage(m *models.Image, r io.ReadSeeker, mime string, filename string, created time.Time) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if err := models.Images().Filter("User", m.User.Id).Filter("Hash", hash).One(m); err == nil {
+		return nil
+	}
+
+	if err := CheckImageQuota(m.User, int64(len(data))); err != nil {
+		return err
+	}
+
+	var same models.Image
+	if err := models.Images().Filter("Hash", hash).One(&same); err == nil {
+		m.Ext = same.Ext
+		m.Width = same.Width
+		m.Height = same.Height
+		m.Hash = hash
+		m.Size = same.Size
+		m.Path = GenImagePath(&same)
+		m.Created = created
+
+		if err := m.Insert(); err != nil || m.Id <= 0 {
+			return err
+		}
+		m.Token = m.GetToken()
+		return m.Update("Token")
+	}
+
+	img, ext, err := DecodeImage(bytes.NewReader(data), mime, filename)
+	if err != nil {
+		return err
+	}
+
+	data, img, err = CleanImage(data, img, ext)
+	if err != nil {
+		return err
+	}
+
+	m.Ext = ext
+	m.Width = img.Bounds().Dx()
+	m.Height = img.Bounds().Dy()
+	m.Size = int64(len(data))
+	m.Created = created
+
+	// hash is saved after all files are stored, uploads of same content
+	// never share a row without files
+	if err := m.Insert(); err != nil || m.Id <= 0 {
+		return err
+	}
+
+	if err := storeImage(m, img, data); err != nil {
+		if e := RemoveImage(m); e != nil {
+			beego.Error("SaveImage: ", e)
+		}
+		return err
+	}
+
+	m.Hash = hash
+	if err := m.Update("Hash"); err != nil {
+		if e := RemoveImage(m); e != nil {
+			beego.Error("SaveImage: ", e)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// storeImage saves full image and its resized images of inserted image.
+func storeImage(m *models.Image, img image.Image, data []byte) error {
+	m.Token = m.GetToken()
+	m.Path = GenImagePath(m)
+	if err := m.Update(); err != nil {
+		return err
+	}
+
+	store := storage.Current()
+	fullKey := GenImageKey(m, 0)
+	if err := store.Put(fullKey, bytes.NewReader(data), int64(len(data)), ImageMime(m.Ext)); err != nil {
+		return err
+	}
+
+	for _, width := range m.Sizes() {
+		if err := ImageResize(m, img, width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImageResize saves image resized to width into storage.
+func ImageResize(img *models.Image, im image.Image, width int) error {
+	return resizeImage(storage.Current(), img, im, width)
+}
+
+func resizeImage(store storage.Storage, img *models.Image, im image.Image, width int) error {
+	data, err := encodeImage(resize.Resize(uint(width), 0, im, resize.Lanczos3), img.Ext)
+	if err != nil {
+		return err
+	}
+	return store.Put(GenImageKey(img, width), bytes.NewReader(data), int64(len(data)), ImageMime(img.Ext))
+}
+
+// encodeImage encodes image in format of ext, gif is encoded as still image.
+func encodeImage(im image.Image, ext int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch ext {
+	case 1:
+		err = jpeg.Encode(&buf, im, &jpeg.Options{Quality: 90})
+	case 2:
+		err = png.Encode(&buf, im)
+	case 3:
+		err = gif.Encode(&buf, im, nil)
+	default:
+		return nil, fmt.Errorf("<encodeImage> unsupport image format")
+	}
+	return buf.Bytes(), err
+}
+
+// DeleteImage removes all sizes and formats of image from storage,
+// files shared with other images are kept.
+func DeleteImage(img *models.Image) error {
+	if img.Path != "" {
+		if cnt, _ := models.Images().Filter("Path", img.Path).Exclude("Id", img.Id).Count(); cnt > 0 {
+			return nil
+		}
+	}
+
+	store := storage.Current()
+	var keys []string
+	for _, width := range append([]int{0}, setting.ImageSizes...) {
+		keys = append(keys, GenImageKey(img, width))
+		for _, format := range modernFormats {
+			keys = append(keys, genImageFormatKey(img, width, format.name))
+		}
+	}
+
+	forgetImageKeys(keys)
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveImage deletes image and its files.
+func RemoveImage(img *models.Image) error {
+	if err := DeleteImage(img); err != nil {
+		return err
+	}
+	return img.Delete()
+}
+
+// GenImagePath returns storage key prefix of image files,
+// images of same content share files of the first uploaded one.
+func GenImagePath(img *models.Image) string {
+	if img.Path != "" {
+		return img.Path
+	}
+	return "img/" + beego.Date(img.Created, "y/m/d/s/") + utils.ToStr(img.Id) + "/"
+}
+
+func imageSizeName(width int) string {
+	if width == 0 {
+		return "full"
+	}
+	return utils.ToStr(width)
+}
+
+// GenImageKey returns storage key of image resized to width, width 0 is full size.
+func GenImageKey(img *models.Image, width int) string {
+	return GenImagePath(img) + imageSizeName(width) + img.GetExt()
+}
+
+// genImageFormatKey returns storage key of image converted to format like webp.
+func genImageFormatKey(img *models.Image, width int, format string) string {
+	return GenImagePath(img) + imageSizeName(width) + "." + format
+}
@@ -0,0 +1,147 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/storage"
+)
+
+// MigrateResult counts files of MigrateStorage.
+type MigrateResult struct {
+	Copied  int
+	Skipped int
+	Failed  int
+}
+
+// MigrateStorage copies images and avatars from src to dst, files exist in dst are skipped
+// so it can be run again after failures. Images uploaded to qiniu before storage backends
+// were saved by token without resized sizes, they are copied and resized.
+func MigrateStorage(dst, src storage.Storage) (*MigrateResult, error) {
+	result := new(MigrateResult)
+
+	var images []*models.Image
+	for offset := 0; ; offset += len(images) {
+		if _, err := models.Images().OrderBy("Id").Limit(100, offset).All(&images); err != nil {
+			return result, err
+		}
+		if len(images) == 0 {
+			break
+		}
+
+		for _, img := range images {
+			migrateImage(dst, src, img, result)
+		}
+	}
+
+	var users []*models.User
+	for offset := 0; ; offset += len(users) {
+		if _, err := models.Users().Filter("AvatarKey__startswith", "avatar/").OrderBy("Id").
+			Limit(100, offset).All(&users, "Id", "AvatarKey"); err != nil {
+			return result, err
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			migrateFile(dst, src, user.AvatarKey, result)
+		}
+	}
+
+	return result, nil
+}
+
+// migrateFile copies file of key if it doesn't exist in dst.
+func migrateFile(dst, src storage.Storage, key string, result *MigrateResult) {
+	if _, err := dst.Stat(key); err == nil {
+		result.Skipped++
+		return
+	}
+
+	if err := storage.Copy(dst, src, key); err != nil {
+		beego.Error("MigrateStorage: ", key, err)
+		result.Failed++
+		return
+	}
+	result.Copied++
+}
+
+func migrateImage(dst, src storage.Storage, img *models.Image, result *MigrateResult) {
+	fullKey := GenImageKey(img, 0)
+	if _, err := src.Stat(fullKey); err == storage.ErrNotExist {
+		if _, ok := src.(*storage.Qiniu); ok {
+			migrateLegacyImage(dst, src, img, result)
+			return
+		}
+	}
+
+	migrateFile(dst, src, fullKey, result)
+	for _, width := range img.Sizes() {
+		migrateFile(dst, src, GenImageKey(img, width), result)
+	}
+}
+
+// migrateLegacyImage copies qiniu image saved by token to full size key and resizes it.
+func migrateLegacyImage(dst, src storage.Storage, img *models.Image, result *MigrateResult) {
+	r, err := src.Get(img.Token)
+	if err != nil {
+		beego.Error("MigrateStorage: ", img.Token, err)
+		result.Failed++
+		return
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		beego.Error("MigrateStorage: ", img.Token, err)
+		result.Failed++
+		return
+	}
+
+	fullKey := GenImageKey(img, 0)
+	if err := dst.Put(fullKey, bytes.NewReader(data), int64(len(data)), ImageMime(img.Ext)); err != nil {
+		beego.Error("MigrateStorage: ", fullKey, err)
+		result.Failed++
+		return
+	}
+	result.Copied++
+
+	widths := img.Sizes()
+	if len(widths) == 0 {
+		return
+	}
+
+	im, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		beego.Error("MigrateStorage: ", fullKey, err)
+		result.Failed += len(widths)
+		return
+	}
+
+	for _, width := range widths {
+		if err := resizeImage(dst, img, im, width); err != nil {
+			beego.Error("MigrateStorage: ", GenImageKey(img, width), err)
+			result.Failed++
+			continue
+		}
+		result.Copied++
+	}
+}
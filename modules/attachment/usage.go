@@ -0,0 +1,156 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+var (
+	ErrImageQuota      = errors.New("image quota is exceeded")
+	ErrImageDailyLimit = errors.New("daily image uploads are exceeded")
+)
+
+// links of uploaded images in markdown content
+var imageLinkRegexp = regexp.MustCompile(`/img/([0-9a-zA-Z]+)\.`)
+
+// ImageUsage returns count and total size of images uploaded by user.
+func ImageUsage(user *models.User) (count int64, size int64, err error) {
+	err = orm.NewOrm().Raw("SELECT COUNT(*), COALESCE(SUM(size), 0) FROM image WHERE user_id = ?",
+		user.Id).QueryRow(&count, &size)
+	return
+}
+
+// TodayImageUploads returns count of images uploaded by user today.
+func TodayImageUploads(user *models.User) (int64, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return models.Images().Filter("User", user.Id).Filter("Created__gte", today).Count()
+}
+
+// CheckImageQuota checks whether user can upload an image of size, admin is not limited.
+func CheckImageQuota(user *models.User, size int64) error {
+	if user.IsAdmin {
+		return nil
+	}
+
+	if setting.ImageDailyUploads > 0 {
+		cnt, err := TodayImageUploads(user)
+		if err != nil {
+			return err
+		}
+		if cnt >= int64(setting.ImageDailyUploads) {
+			return ErrImageDailyLimit
+		}
+	}
+
+	if setting.ImageUserQuota > 0 {
+		_, used, err := ImageUsage(user)
+		if err != nil {
+			return err
+		}
+		if used+size > setting.ImageUserQuota {
+			return ErrImageQuota
+		}
+	}
+	return nil
+}
+
+// scanImageTokens collects tokens of image links in column of all rows.
+func scanImageTokens(tokens map[string]bool, qs orm.QuerySeter, column string) error {
+	for offset := 0; ; offset += 500 {
+		var contents orm.ParamsList
+		n, err := qs.Limit(500, offset).ValuesFlat(&contents, column)
+		if err != nil {
+			return err
+		}
+		for _, content := range contents {
+			str, _ := content.(string)
+			for _, match := range imageLinkRegexp.FindAllStringSubmatch(str, -1) {
+				tokens[match[1]] = true
+			}
+		}
+		if n < 500 {
+			return nil
+		}
+	}
+}
+
+// referencedImageTokens returns tokens of images linked by posts, comments, pages
+// and contents held for moderation.
+func referencedImageTokens() (map[string]bool, error) {
+	tokens := make(map[string]bool)
+	if err := scanImageTokens(tokens, models.Posts(), "Content"); err != nil {
+		return nil, err
+	}
+	if err := scanImageTokens(tokens, models.Comments(), "Message"); err != nil {
+		return nil, err
+	}
+	if err := scanImageTokens(tokens, models.Pages(), "Content"); err != nil {
+		return nil, err
+	}
+	if err := scanImageTokens(tokens, models.HeldContents().Filter("Status", setting.HELD_PENDING), "Content"); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// UnusedImages returns images uploaded before unused days and not linked by any content.
+func UnusedImages() ([]*models.Image, error) {
+	tokens, err := referencedImageTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().AddDate(0, 0, -setting.ImageUnusedDays)
+	var images []*models.Image
+	if _, err := models.Images().Filter("Created__lt", deadline).Limit(-1).RelatedSel().All(&images); err != nil {
+		return nil, err
+	}
+
+	var unused []*models.Image
+	for _, img := range images {
+		if !tokens[img.GetToken()] {
+			unused = append(unused, img)
+		}
+	}
+	return unused, nil
+}
+
+// CleanUnusedImages removes unused images and their files, returns count of removed images.
+func CleanUnusedImages() (int, error) {
+	images, err := UnusedImages()
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	for _, img := range images {
+		if err := RemoveImage(img); err != nil {
+			beego.Error("attachment.CleanUnusedImages: ", img.Id, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,290 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/astaxie/beego"
+	"github.com/nfnt/resize"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/storage"
+	"github.com/varding/wetalk/setting"
+)
+
+var ErrNoImageVariant = errors.New("image variant not found")
+
+type imageFormat struct {
+	name    string
+	mime    string
+	encoder *string
+}
+
+// modern image formats converted by external encoders, preferred in order
+var modernFormats = []imageFormat{
+	{"avif", "image/avif", &setting.ImageAvifEncoder},
+	{"webp", "image/webp", &setting.ImageWebpEncoder},
+}
+
+var (
+	// keys known exist in storage, saves stat requests of remote storages
+	existKeys     = make(map[string]bool)
+	existKeysLock sync.RWMutex
+
+	// requested keys resolved to other keys, like links of images sharing files
+	resolvedKeys = make(map[string][2]string)
+
+	// generating images is heavy, one at a time
+	generateLock sync.Mutex
+)
+
+func imageExists(key string) bool {
+	existKeysLock.RLock()
+	exist := existKeys[key]
+	existKeysLock.RUnlock()
+	if exist {
+		return true
+	}
+
+	if _, err := storage.Current().Stat(key); err != nil {
+		return false
+	}
+
+	existKeysLock.Lock()
+	if len(existKeys) >= 10000 {
+		existKeys = make(map[string]bool)
+	}
+	existKeys[key] = true
+	existKeysLock.Unlock()
+	return true
+}
+
+func forgetImageKeys(keys []string) {
+	existKeysLock.Lock()
+	for _, key := range keys {
+		delete(existKeys, key)
+	}
+	for requested, resolved := range resolvedKeys {
+		for _, key := range keys {
+			if resolved[0] == key {
+				delete(resolvedKeys, requested)
+			}
+		}
+	}
+	existKeysLock.Unlock()
+}
+
+func resolvedKey(requested string) (key string, mime string, ok bool) {
+	existKeysLock.RLock()
+	resolved, ok := resolvedKeys[requested]
+	existKeysLock.RUnlock()
+	return resolved[0], resolved[1], ok
+}
+
+func resolveKey(requested, key, mime string) {
+	existKeysLock.Lock()
+	if len(resolvedKeys) >= 10000 {
+		resolvedKeys = make(map[string][2]string)
+	}
+	resolvedKeys[requested] = [2]string{key, mime}
+	existKeysLock.Unlock()
+}
+
+func isImageSize(width int) bool {
+	for _, w := range setting.ImageSizes {
+		if w == width {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageVariant returns storage key and mime type of image file named `<size><ext>` in
+// image links, modern formats accepted by browser are preferred. Sizes and formats not
+// generated yet are generated from the full size image.
+func ImageVariant(img *models.Image, name string, accept string) (key string, mime string, err error) {
+	i := strings.IndexRune(name, '.')
+	if i == -1 {
+		return "", "", ErrNoImageVariant
+	}
+	size, ext := name[:i], name[i:]
+
+	var width int
+	if size != "full" {
+		if width, err = strconv.Atoi(size); err != nil || !isImageSize(width) {
+			return "", "", ErrNoImageVariant
+		}
+	}
+
+	var format *imageFormat
+	if ext != ".gif" {
+		for i, f := range modernFormats {
+			if *f.encoder != "" && strings.Contains(accept, f.mime) {
+				format = &modernFormats[i]
+				break
+			}
+		}
+	}
+
+	if format != nil {
+		key, mime = GenImagePath(img)+size+"."+format.name, format.mime
+	} else {
+		key, mime = GenImagePath(img)+size+ext, ImageMime(imageExtCode(ext))
+	}
+	if imageExists(key) {
+		return key, mime, nil
+	}
+	if key, mime, ok := resolvedKey(key); ok {
+		return key, mime, nil
+	}
+	requested := key
+
+	// find out size and format of the image, links of sizes larger than the image are full size
+	if err := img.Read(); err != nil || img.GetExt() != ext {
+		return "", "", ErrNoImageVariant
+	}
+	if width != 0 && !hasImageSize(img, width) {
+		width = 0
+	}
+
+	if format != nil {
+		key = genImageFormatKey(img, width, format.name)
+		err := generateImage(img, key, width, format)
+		if err == nil {
+			resolveKey(requested, key, mime)
+			return key, mime, nil
+		}
+		// fall back to original format
+		beego.Error("ImageVariant: ", key, err)
+	}
+
+	key, mime = GenImageKey(img, width), ImageMime(img.Ext)
+	if err := generateImage(img, key, width, nil); err != nil {
+		return "", "", err
+	}
+	resolveKey(requested, key, mime)
+	return key, mime, nil
+}
+
+func hasImageSize(img *models.Image, width int) bool {
+	for _, w := range img.Sizes() {
+		if w == width {
+			return true
+		}
+	}
+	return false
+}
+
+func imageExtCode(ext string) int {
+	switch ext {
+	case ".jpg":
+		return 1
+	case ".png":
+		return 2
+	case ".gif":
+		return 3
+	}
+	return 0
+}
+
+// generateImage resizes full size image to width and converts it to format if not exists.
+func generateImage(img *models.Image, key string, width int, format *imageFormat) error {
+	if imageExists(key) {
+		return nil
+	}
+
+	generateLock.Lock()
+	defer generateLock.Unlock()
+
+	// generated while waiting
+	if imageExists(key) {
+		return nil
+	}
+
+	store := storage.Current()
+	r, err := store.Get(GenImageKey(img, 0))
+	if err != nil {
+		return err
+	}
+	im, _, err := DecodeImage(r, ImageMime(img.Ext), "")
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	if width != 0 {
+		im = resize.Resize(uint(width), 0, im, resize.Lanczos3)
+	}
+
+	var data []byte
+	var mime string
+	if format != nil {
+		data, err = convertImage(im, *format.encoder)
+		mime = format.mime
+	} else {
+		data, err = encodeImage(im, img.Ext)
+		mime = ImageMime(img.Ext)
+	}
+	if err != nil {
+		return err
+	}
+
+	return store.Put(key, bytes.NewReader(data), int64(len(data)), mime)
+}
+
+// convertImage runs external encoder command on png of image, {in} and {out} in
+// command are replaced with paths of input and output files.
+func convertImage(im image.Image, command string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "wetalk-image")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out")
+
+	file, err := os.Create(in)
+	if err != nil {
+		return nil, err
+	}
+	err = png.Encode(file, im)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	args := strings.Fields(command)
+	for i, arg := range args {
+		args[i] = strings.Replace(strings.Replace(arg, "{in}", in, -1), "{out}", out, -1)
+	}
+
+	if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %s %s", args[0], err, output)
+	}
+	return ioutil.ReadFile(out)
+}
@@ -15,30 +15,39 @@
 package auth
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/astaxie/beego/context"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"io/ioutil"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/astaxie/beego"
 	"github.com/astaxie/beego/orm"
 	"github.com/astaxie/beego/session"
 	"github.com/beego/i18n"
 
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/ban"
 	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/storage"
 	"github.com/varding/wetalk/modules/utils"
 	"github.com/varding/wetalk/setting"
-	qio "github.com/qiniu/api.v6/io"
 )
 
-// CanRegistered checks if the username or e-mail is available.
-func CanRegistered(userName string, email string) (bool, bool, error) {
+var ErrRegisterBanned = errors.New("ip or email domain is banned")
+
+// CanRegistered checks if the username or e-mail is available,
+// ErrRegisterBanned is returned if the ip or e-mail domain is banned.
+func CanRegistered(userName string, email string, ip string) (bool, bool, error) {
+	if ban.MatchIp(ip) != nil || ban.MatchEmail(email) != nil {
+		return true, true, ErrRegisterBanned
+	}
+
 	cond := orm.NewCondition()
 	cond = cond.Or("UserName", userName).Or("Email", email)
 
@@ -85,15 +94,14 @@ func HasUser(user *models.User, username string) bool {
 
 // register create user
 func RegisterUser(user *models.User, username, email, password string, locale i18n.Locale) error {
-	// use random salt encode password
-	salt := models.GetUserSalt()
-	pwd := utils.EncodePassword(password, salt)
+	pwd, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
 
 	user.UserName = strings.ToLower(username)
 	user.Email = strings.ToLower(email)
-
-	// save salt and encode password, use $ as split char
-	user.Password = fmt.Sprintf("%s$%s", salt, pwd)
+	user.Password = pwd
 
 	// save md5 email value for gravatar
 	user.GrEmail = utils.EncodeMd5(user.Email)
@@ -115,8 +123,11 @@ func RegisterUser(user *models.User, username, email, password string, locale i1
 
 // set a new password to user
 func SaveNewPassword(user *models.User, password string) error {
-	salt := models.GetUserSalt()
-	user.Password = fmt.Sprintf("%s$%s", salt, utils.EncodePassword(password, salt))
+	pwd, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Password = pwd
 	return user.Update("Password", "Rands", "Updated")
 }
 
@@ -144,16 +155,62 @@ func LoginUser(user *models.User, ctx *context.Context, remember bool) {
 	ctx.Input.CruSession = beego.GlobalSessions.SessionRegenerateId(ctx.ResponseWriter, ctx.Request)
 	ctx.Input.CruSession.Set("auth_user_id", user.Id)
 
+	var ls models.LoginSession
+	if err := createLoginSession(&ls, user, ctx); err != nil {
+		beego.Error("LoginUser: ", err)
+	}
+
 	if remember {
 		WriteRememberCookie(user, ctx)
 	}
 }
 
+// remember cookie is signed with user salt and two-factor secret,
+// cookies issued before they changed can't login.
+// password changes revoke login sessions instead, rehash keeps them.
+func rememberSecret(user *models.User) string {
+	secret := user.Rands
+	var tf models.TwoFactor
+	if GetTwoFactor(&tf, user) {
+		secret += tf.Secret
+	}
+	return utils.EncodeHmac(setting.SecretKey, secret, sha256.New)
+}
+
+// remember current login session with a new token, value of cookie is "<id>:<token>"
 func WriteRememberCookie(user *models.User, ctx *context.Context) {
-	secret := utils.EncodeMd5(user.Rands + user.Password)
+	ls := models.LoginSession{Id: GetLoginSessionId(ctx.Input.CruSession)}
+	if ls.Id == 0 || ls.Read() != nil {
+		return
+	}
+	rotateRememberToken(&ls, user, ctx)
+}
+
+// replace the token of login session and keep the old one as previous token,
+// only the request still holding the current token rotates it
+func rotateRememberToken(ls *models.LoginSession, user *models.User, ctx *context.Context) {
+	raw := utils.GetRandomString(40)
 	days := 86400 * setting.LoginRememberDays
+
+	num, err := models.LoginSessions().Filter("Id", ls.Id).Filter("Token", ls.Token).Update(orm.Params{
+		"Token":      HashToken(raw),
+		"PrevToken":  ls.Token,
+		"Rotated":    time.Now(),
+		"IsRemember": true,
+		"Expires":    time.Now().Add(time.Duration(days) * time.Second),
+	})
+	if err != nil {
+		beego.Error("WriteRememberCookie: ", err)
+		return
+	}
+	if num == 0 {
+		// rotated by a concurrent request, its response sets the new cookie
+		return
+	}
+
+	secret := rememberSecret(user)
 	ctx.SetCookie(setting.CookieUserName, user.UserName, days)
-	ctx.SetSecureCookie(secret, setting.CookieRememberName, user.UserName, days)
+	ctx.SetSecureCookie(secret, setting.CookieRememberName, utils.ToStr(ls.Id)+":"+raw, days)
 }
 
 func DeleteRememberCookie(ctx *context.Context) {
@@ -161,6 +218,7 @@ func DeleteRememberCookie(ctx *context.Context) {
 	ctx.SetCookie(setting.CookieRememberName, "", -1)
 }
 
+// login by remember cookie continues the login session of the device
 func LoginUserFromRememberCookie(user *models.User, ctx *context.Context) (success bool) {
 	userName := ctx.GetCookie(setting.CookieUserName)
 	if len(userName) == 0 {
@@ -178,19 +236,52 @@ func LoginUserFromRememberCookie(user *models.User, ctx *context.Context) (succe
 		return false
 	}
 
-	secret := utils.EncodeMd5(user.Rands + user.Password)
+	secret := rememberSecret(user)
 	value, _ := ctx.GetSecureCookie(secret, setting.CookieRememberName)
-	if value != userName {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
 		return false
 	}
 
-	LoginUser(user, ctx, true)
+	ls := models.LoginSession{}
+	ls.Id, _ = utils.StrTo(parts[0]).Int()
+	if ls.Id <= 0 || ls.Read() != nil {
+		return false
+	}
+	if ls.User.Id != user.Id || !ls.IsRemember || !IsLoginSessionAlive(&ls) {
+		return false
+	}
+
+	// the token replaced a moment ago is accepted once more without rotating
+	token := HashToken(parts[1])
+	isPrev := len(ls.PrevToken) > 0 && ls.PrevToken == token &&
+		time.Since(ls.Rotated) < rememberGraceSeconds*time.Second
+	if ls.Token != token && !isPrev {
+		return false
+	}
+
+	ctx.Input.CruSession.SessionRelease(ctx.ResponseWriter)
+	ctx.Input.CruSession = beego.GlobalSessions.SessionRegenerateId(ctx.ResponseWriter, ctx.Request)
+	ctx.Input.CruSession.Set("auth_user_id", user.Id)
+	ctx.Input.CruSession.Set("auth_login_session", ls.Id)
+
+	ls.Ip = ctx.Input.IP()
+	ls.LastSeen = time.Now()
+	if err := ls.Update("Ip", "LastSeen"); err != nil {
+		beego.Error("LoginUserFromRememberCookie: ", err)
+	}
+
+	// token is renewed each time it is used
+	if !isPrev {
+		rotateRememberToken(&ls, user, ctx)
+	}
 
 	return true
 }
 
 // logout user
 func LogoutUser(ctx *context.Context) {
+	revokeCurrentLoginSession(ctx)
 	DeleteRememberCookie(ctx)
 	ctx.Input.CruSession.Delete("auth_user_id")
 	ctx.Input.CruSession.Flush()
@@ -229,36 +320,16 @@ func VerifyUser(user *models.User, username, password string) (success bool) {
 		// success
 		success = true
 
-		// re-save discuz password
-		if len(user.Password) == 39 {
-			if err := SaveNewPassword(user, password); err != nil {
-				beego.Error("SaveNewPassword err: ", err.Error())
+		// re-save legacy or outdated password with current hasher
+		if NeedsRehash(user.Password) {
+			if err := upgradePassword(user, password); err != nil {
+				beego.Error("upgradePassword err: ", err.Error())
 			}
 		}
 	}
 	return
 }
 
-// compare raw password and encoded password
-func VerifyPassword(rawPwd, encodedPwd string) bool {
-
-	// for discuz accounts
-	if len(encodedPwd) == 39 {
-		salt := encodedPwd[:6]
-		encoded := encodedPwd[7:]
-		return encoded == utils.EncodeMd5(utils.EncodeMd5(rawPwd)+salt)
-	}
-
-	// split
-	var salt, encoded string
-	if len(encodedPwd) > 11 {
-		salt = encodedPwd[:10]
-		encoded = encodedPwd[11:]
-	}
-
-	return utils.EncodePassword(rawPwd, salt) == encoded
-}
-
 // get user by erify code
 func getVerifyUser(user *models.User, code string) bool {
 	if len(code) <= utils.TimeLimitCodeLength {
@@ -329,79 +400,61 @@ func CreateUserResetPwdCode(user *models.User, startInf interface{}) string {
 	return code
 }
 
-//upload user avatar
-func UploadUserAvatarToQiniu(r io.ReadSeeker, filename string, mime string, bucketName string, user *models.User) error {
-	var ext string
-
-	// test image mime type
-	switch mime {
-	case "image/jpeg":
-		ext = ".jpg"
-
-	case "image/png":
-		ext = ".png"
-
-	case "image/gif":
-		ext = ".gif"
+// UploadUserAvatar saves uploaded avatar image into storage,
+// previous avatar file of the user is removed.
+func UploadUserAvatar(r io.ReadSeeker, filename string, mime string, user *models.User) error {
+	size, err := r.Seek(0, 2)
+	if err != nil {
+		return err
+	}
 
-	default:
-		ext = filepath.Ext(filename)
-		switch ext {
-		case ".jpg", ".png", ".gif":
-		default:
-			return fmt.Errorf("unsupport image format `%s`", filename)
-		}
+	if size > int64(setting.AvatarImageMaxLength) {
+		return fmt.Errorf("avatar image `%s` size too large", filename)
 	}
 
-	// decode image
-	var err error
-	switch ext {
-	case ".jpg":
-		_, err = jpeg.Decode(r)
-	case ".png":
-		_, err = png.Decode(r)
-	case ".gif":
-		_, err = gif.Decode(r)
+	if _, err := r.Seek(0, 0); err != nil {
+		return err
 	}
 
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
 
-	//reset reader pointer
-	if _, err := r.Seek(0, 0); err != nil {
+	img, ext, err := attachment.DecodeImage(bytes.NewReader(data), mime, filename)
+	if err != nil {
 		return err
 	}
-	var data []byte
-	if data, err = ioutil.ReadAll(r); err != nil {
+
+	// remove location and other metadata in photos
+	if data, _, err = attachment.CleanImage(data, img, ext); err != nil {
 		return err
 	}
 
-	if len(data) > setting.AvatarImageMaxLength {
-		return fmt.Errorf("avatar image size too large", filename)
-	}
+	image := models.Image{Ext: ext}
+	key := fmt.Sprintf("avatar/%d/%d%s", user.Id, time.Now().Unix(), image.GetExt())
 
-	//reset reader pointer again
-	if _, err := r.Seek(0, 0); err != nil {
+	store := storage.Current()
+	if err := store.Put(key, bytes.NewReader(data), int64(len(data)), attachment.ImageMime(ext)); err != nil {
 		return err
 	}
 
-	//save to qiniu
-	var uptoken = utils.GetQiniuUptoken(bucketName)
-	var putRet qio.PutRet
-	var putExtra = &qio.PutExtra{
-		MimeType: mime,
-	}
-
-	err = qio.PutWithoutKey(nil, &putRet, uptoken, r, putExtra)
-	if err != nil {
+	// crop and resize to sizes of avatar links
+	if err := attachment.SaveAvatarSizes(key); err != nil {
+		attachment.DeleteAvatar(key)
 		return err
 	}
 
+	oldKey := user.AvatarKey
+
 	//update user
-	user.AvatarKey = putRet.Key
+	user.AvatarKey = key
 	if err := user.Update("AvatarKey", "Updated"); err != nil {
 		return err
 	}
+
+	if strings.HasPrefix(oldKey, "avatar/") {
+		attachment.DeleteAvatar(oldKey)
+	}
 	return nil
 }
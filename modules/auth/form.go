@@ -29,10 +29,11 @@ import (
 type RegisterForm struct {
 	UserName   string      `valid:"Required;AlphaDash;MinSize(3);MaxSize(30)"`
 	Email      string      `valid:"Required;Email;MaxSize(80)"`
-	Password   string      `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
-	PasswordRe string      `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
+	Password   string      `form:"type(password)" valid:"Required;MaxSize(72)"`
+	PasswordRe string      `form:"type(password)" valid:"Required;MaxSize(72)"`
 	Captcha    string      `form:"type(captcha)" valid:"Required"`
 	CaptchaId  string      `form:"type(empty)"`
+	Ip         string      `form:"-"`
 	Locale     i18n.Locale `form:"-"`
 }
 
@@ -44,7 +45,14 @@ func (form *RegisterForm) Valid(v *validation.Validation) {
 		return
 	}
 
-	e1, e2, _ := CanRegistered(form.UserName, form.Email)
+	ValidPasswordPolicy(v, "Password", form.Password, form.UserName, form.Email)
+
+	e1, e2, err := CanRegistered(form.UserName, form.Email, form.Ip)
+
+	if err == ErrRegisterBanned {
+		v.SetError("Email", "auth.register_banned")
+		return
+	}
 
 	if !e1 {
 		v.SetError("UserName", "auth.username_already_taken")
@@ -117,8 +125,8 @@ func (form *ForgotForm) Valid(v *validation.Validation) {
 
 // Reset password form
 type ResetPwdForm struct {
-	Password   string `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
-	PasswordRe string `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
+	Password   string `form:"type(password)" valid:"Required;MaxSize(72)"`
+	PasswordRe string `form:"type(password)" valid:"Required;MaxSize(72)"`
 }
 
 func (form *ResetPwdForm) Valid(v *validation.Validation) {
@@ -127,6 +135,8 @@ func (form *ResetPwdForm) Valid(v *validation.Validation) {
 		v.SetError("PasswordRe", "auth.repassword_not_match")
 		return
 	}
+
+	ValidPasswordPolicy(v, "Password", form.Password)
 }
 
 func (form *ResetPwdForm) Labels() map[string]string {
@@ -232,8 +242,8 @@ func (form *ProfileForm) Placeholders() map[string]string {
 // Change password form
 type PasswordForm struct {
 	PasswordOld string       `form:"type(password)" valid:"Required"`
-	Password    string       `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
-	PasswordRe  string       `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
+	Password    string       `form:"type(password)" valid:"Required;MaxSize(72)"`
+	PasswordRe  string       `form:"type(password)" valid:"Required;MaxSize(72)"`
 	User        *models.User `form:"-"`
 }
 
@@ -247,6 +257,8 @@ func (form *PasswordForm) Valid(v *validation.Validation) {
 	if VerifyPassword(form.PasswordOld, form.User.Password) == false {
 		v.SetError("PasswordOld", "auth.old_password_wrong")
 	}
+
+	ValidPasswordPolicy(v, "Password", form.Password, form.User.UserName, form.User.Email)
 }
 
 func (form *PasswordForm) Labels() map[string]string {
@@ -272,7 +284,11 @@ type UserAvatarForm struct {
 
 func (form *UserAvatarForm) AvatarTypeSelectData() [][]string {
 	var data = make([][]string, 0, 2)
-	data = append(data, []string{"auth.user_avatar_use_gravatar", utils.ToStr(setting.AvatarTypeGravatar)})
+	if setting.AvatarURL != "" {
+		data = append(data, []string{"auth.user_avatar_use_gravatar", utils.ToStr(setting.AvatarTypeGravatar)})
+	} else {
+		data = append(data, []string{"auth.user_avatar_use_default", utils.ToStr(setting.AvatarTypeGravatar)})
+	}
 	data = append(data, []string{"auth.user_avatar_use_personal", utils.ToStr(setting.AvatarTypePersonalized)})
 
 	return data
@@ -366,3 +382,23 @@ func (form *UserAdminForm) SetToUser(user *models.User) {
 
 	utils.SetFormValues(form, user)
 }
+
+// Account deletion form, password confirms the request
+type AccountDeleteForm struct {
+	Password string       `form:"type(password)" valid:"Required"`
+	Reason   string       `form:"type(textarea)" valid:"MaxSize(255)"`
+	User     *models.User `form:"-"`
+}
+
+func (form *AccountDeleteForm) Valid(v *validation.Validation) {
+	if !VerifyPassword(form.Password, form.User.Password) {
+		v.SetError("Password", "auth.old_password_wrong")
+	}
+}
+
+func (form *AccountDeleteForm) Labels() map[string]string {
+	return map[string]string{
+		"Password": "auth.account_delete_password",
+		"Reason":   "auth.account_delete_reason",
+	}
+}
@@ -23,8 +23,9 @@ import (
 type OAuthRegisterForm struct {
 	UserName   string      `valid:"Required;AlphaDash;MinSize(5);MaxSize(30)"`
 	Email      string      `valid:"Required;Email;MaxSize(80)"`
-	Password   string      `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
-	PasswordRe string      `form:"type(password)" valid:"Required;MinSize(4);MaxSize(30)"`
+	Password   string      `form:"type(password)" valid:"Required;MaxSize(72)"`
+	PasswordRe string      `form:"type(password)" valid:"Required;MaxSize(72)"`
+	Ip         string      `form:"-"`
 	Locale     i18n.Locale `form:"-"`
 }
 
@@ -36,7 +37,14 @@ func (form *OAuthRegisterForm) Valid(v *validation.Validation) {
 		return
 	}
 
-	e1, e2, _ := CanRegistered(form.UserName, form.Email)
+	ValidPasswordPolicy(v, "Password", form.Password, form.UserName, form.Email)
+
+	e1, e2, err := CanRegistered(form.UserName, form.Email, form.Ip)
+
+	if err == ErrRegisterBanned {
+		v.SetError("Email", "auth.register_banned")
+		return
+	}
 
 	if !e1 {
 		v.SetError("UserName", "auth.username_already_taken")
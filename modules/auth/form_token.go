@@ -0,0 +1,95 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"net/url"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+// Personal access token form
+type AccessTokenForm struct {
+	Name   string       `valid:"Required;MaxSize(50)"`
+	Scopes []string     `form:"type(select);attr(multiple,multiple);attr(rel,select2)"`
+	User   *models.User `form:"-"`
+}
+
+func (form *AccessTokenForm) ScopesSelectData() [][]string {
+	data := make([][]string, 0, len(TokenScopes))
+	for _, scope := range TokenScopes {
+		data = append(data, []string{"auth.token_scope_" + scope, scope})
+	}
+	return data
+}
+
+func (form *AccessTokenForm) Valid(v *validation.Validation) {
+	if len(CleanScopes(form.Scopes)) == 0 {
+		v.SetError("Scopes", "Can not be empty")
+	}
+
+	if CountUserTokens(form.User) >= int64(setting.TokenMaxPerUser) {
+		v.SetError("Name", "auth.token_max_reached")
+	}
+}
+
+func (form *AccessTokenForm) Labels() map[string]string {
+	return map[string]string{
+		"Name":   "auth.token_name",
+		"Scopes": "auth.token_scopes",
+	}
+}
+
+func (form *AccessTokenForm) Helps() map[string]string {
+	return map[string]string{
+		"Scopes": "auth.token_scopes_help",
+	}
+}
+
+// OAuth application register form
+type OAuthAppForm struct {
+	Name        string `valid:"Required;MaxSize(50)"`
+	Url         string `valid:"Required;MaxSize(100)"`
+	RedirectUri string `valid:"Required;MaxSize(200)"`
+}
+
+func (form *OAuthAppForm) Valid(v *validation.Validation) {
+	if u, err := url.Parse(form.RedirectUri); err != nil || !u.IsAbs() {
+		v.SetError("RedirectUri", "auth.oauth_app_invalid_redirect")
+	}
+}
+
+func (form *OAuthAppForm) SetToApp(app *models.OAuthApp) {
+	app.Name = form.Name
+	app.Url = form.Url
+	app.RedirectUri = form.RedirectUri
+}
+
+func (form *OAuthAppForm) Labels() map[string]string {
+	return map[string]string{
+		"Name":        "auth.oauth_app_name",
+		"Url":         "auth.oauth_app_url",
+		"RedirectUri": "auth.oauth_app_redirect_uri",
+	}
+}
+
+func (form *OAuthAppForm) Helps() map[string]string {
+	return map[string]string{
+		"RedirectUri": "auth.oauth_app_redirect_uri_help",
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"github.com/astaxie/beego/validation"
+
+	"github.com/varding/wetalk/modules/models"
+)
+
+// Enable two-factor form, code is checked with secret shown to user
+type TwoFactorEnableForm struct {
+	Code   string `valid:"Required;MaxSize(10)"`
+	Secret string `form:"-"`
+}
+
+func (form *TwoFactorEnableForm) Valid(v *validation.Validation) {
+	if !CheckTotpCode(form.Secret, form.Code) {
+		v.SetError("Code", "auth.twofactor_code_wrong")
+	}
+}
+
+func (form *TwoFactorEnableForm) Labels() map[string]string {
+	return map[string]string{
+		"Code": "auth.twofactor_code",
+	}
+}
+
+func (form *TwoFactorEnableForm) Helps() map[string]string {
+	return map[string]string{
+		"Code": "auth.twofactor_enable_help",
+	}
+}
+
+// Second step of login, totp code or recovery code
+type TwoFactorLoginForm struct {
+	Code string `valid:"Required;MaxSize(20)"`
+}
+
+func (form *TwoFactorLoginForm) Labels() map[string]string {
+	return map[string]string{
+		"Code": "auth.twofactor_code",
+	}
+}
+
+func (form *TwoFactorLoginForm) Helps() map[string]string {
+	return map[string]string{
+		"Code": "auth.twofactor_login_help",
+	}
+}
+
+// Password confirm of disable two-factor or regenerate recovery codes
+type TwoFactorPasswordForm struct {
+	Password string       `form:"type(password)" valid:"Required"`
+	User     *models.User `form:"-"`
+}
+
+func (form *TwoFactorPasswordForm) Valid(v *validation.Validation) {
+	if !VerifyPassword(form.Password, form.User.Password) {
+		v.SetError("Password", "auth.old_password_wrong")
+	}
+}
+
+func (form *TwoFactorPasswordForm) Labels() map[string]string {
+	return map[string]string{
+		"Password": "auth.twofactor_password",
+	}
+}
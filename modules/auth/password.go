@@ -0,0 +1,227 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/validation"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// encoded passwords are versioned by prefix, "$2a$" is bcrypt,
+// "$argon2id$v=19$m=..,t=..,p=..$<salt>$<hash>" is argon2id in raw base64,
+// others are legacy "<salt>$<hex>" of pbkdf2 and 39 length discuz accounts
+const (
+	HasherBcrypt   = "bcrypt"
+	HasherArgon2id = "argon2id"
+
+	argon2Prefix  = "$argon2id$"
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+
+	// bcrypt ignores bytes after 72, MaxSize of forms counts runes
+	passwordMaxBytes = 72
+)
+
+// encode raw password with configured hasher
+func HashPassword(rawPwd string) (string, error) {
+	if setting.PasswordHasher == HasherArgon2id {
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		t, m, p := uint32(setting.PasswordArgon2Time), uint32(setting.PasswordArgon2Memory), uint8(setting.PasswordArgon2Threads)
+		key := argon2.IDKey([]byte(rawPwd), salt, t, m, p, argon2KeyLen)
+		return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, m, t, p,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(rawPwd), setting.PasswordBcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func isBcrypt(encodedPwd string) bool {
+	return strings.HasPrefix(encodedPwd, "$2a$") || strings.HasPrefix(encodedPwd, "$2b$") || strings.HasPrefix(encodedPwd, "$2y$")
+}
+
+type argon2Params struct {
+	version int
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2(encodedPwd string) (*argon2Params, bool) {
+	parts := strings.Split(strings.TrimPrefix(encodedPwd, argon2Prefix), "$")
+	if len(parts) != 4 {
+		return nil, false
+	}
+
+	var p argon2Params
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &p.version); err != nil {
+		return nil, false
+	}
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return nil, false
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return nil, false
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(p.key) == 0 {
+		return nil, false
+	}
+	return &p, true
+}
+
+// compare raw password and encoded password
+func VerifyPassword(rawPwd, encodedPwd string) bool {
+	switch {
+	case isBcrypt(encodedPwd):
+		return bcrypt.CompareHashAndPassword([]byte(encodedPwd), []byte(rawPwd)) == nil
+
+	case strings.HasPrefix(encodedPwd, argon2Prefix):
+		p, ok := parseArgon2(encodedPwd)
+		if !ok {
+			return false
+		}
+		key := argon2.IDKey([]byte(rawPwd), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		return subtle.ConstantTimeCompare(key, p.key) == 1
+
+	// for discuz accounts
+	case len(encodedPwd) == 39:
+		salt := encodedPwd[:6]
+		encoded := encodedPwd[7:]
+		return encoded == utils.EncodeMd5(utils.EncodeMd5(rawPwd)+salt)
+	}
+
+	// legacy pbkdf2, split
+	var salt, encoded string
+	if len(encodedPwd) > 11 {
+		salt = encodedPwd[:10]
+		encoded = encodedPwd[11:]
+	}
+
+	return subtle.ConstantTimeCompare([]byte(utils.EncodePassword(rawPwd, salt)), []byte(encoded)) == 1
+}
+
+// check encoded password is not made by configured hasher and cost
+func NeedsRehash(encodedPwd string) bool {
+	switch setting.PasswordHasher {
+	case HasherArgon2id:
+		p, ok := parseArgon2(encodedPwd)
+		return !ok || p.version != argon2.Version || p.time != uint32(setting.PasswordArgon2Time) ||
+			p.memory != uint32(setting.PasswordArgon2Memory) || p.threads != uint8(setting.PasswordArgon2Threads)
+	default:
+		if !isBcrypt(encodedPwd) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encodedPwd))
+		return err != nil || cost != setting.PasswordBcryptCost
+	}
+}
+
+// rehash verified password of user with current hasher,
+// remember cookies and login sessions are kept
+func upgradePassword(user *models.User, rawPwd string) error {
+	encoded, err := HashPassword(rawPwd)
+	if err != nil {
+		return err
+	}
+	user.Password = encoded
+	return user.Update("Password")
+}
+
+var (
+	breachedOnce      sync.Once
+	breachedPasswords map[string]bool
+)
+
+// load local list of breached passwords, one per line, # for comment
+func loadBreachedPasswords() {
+	breachedPasswords = make(map[string]bool)
+	if setting.PasswordBreachedList == "" {
+		return
+	}
+
+	f, err := os.Open(setting.PasswordBreachedList)
+	if err != nil {
+		beego.Error("BreachedPasswords: ", err)
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breachedPasswords[strings.ToLower(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		beego.Error("BreachedPasswords: ", err)
+	}
+}
+
+// check password is in the list of breached passwords
+func IsBreachedPassword(rawPwd string) bool {
+	breachedOnce.Do(loadBreachedPasswords)
+	return breachedPasswords[strings.ToLower(rawPwd)]
+}
+
+// check new password against password policy, errors are set to field
+func ValidPasswordPolicy(v *validation.Validation, field, rawPwd string, names ...string) {
+	if len([]rune(rawPwd)) < setting.PasswordMinLength {
+		v.SetError(field, "auth.password_too_short")
+		return
+	}
+
+	if len(rawPwd) > passwordMaxBytes {
+		v.SetError(field, "auth.password_too_long")
+		return
+	}
+
+	for _, name := range names {
+		if name != "" && strings.EqualFold(rawPwd, name) {
+			v.SetError(field, "auth.password_same_as_name")
+			return
+		}
+	}
+
+	if IsBreachedPassword(rawPwd) {
+		v.SetError(field, "auth.password_breached")
+	}
+}
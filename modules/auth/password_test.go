@@ -0,0 +1,71 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/validation"
+
+	. "github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+func TestHashPassword(t *testing.T) {
+	setting.PasswordBcryptCost = 4
+	setting.PasswordArgon2Time = 1
+	setting.PasswordArgon2Memory = 1024
+	setting.PasswordArgon2Threads = 1
+
+	for _, hasher := range []string{HasherBcrypt, HasherArgon2id} {
+		setting.PasswordHasher = hasher
+
+		encoded, err := HashPassword("wetalk")
+		ThrowFailNow(t, err)
+
+		ThrowFail(t, AssertIs(VerifyPassword("wetalk", encoded), true))
+		ThrowFail(t, AssertIs(VerifyPassword("fake", encoded), false))
+		ThrowFail(t, AssertIs(NeedsRehash(encoded), false))
+	}
+
+	// changed cost is rehashed
+	setting.PasswordArgon2Time = 2
+	encoded, _ := HashPassword("wetalk")
+	setting.PasswordArgon2Time = 1
+	ThrowFail(t, AssertIs(NeedsRehash(encoded), true))
+}
+
+func TestLegacyPassword(t *testing.T) {
+	salt := "0123456789"
+	encoded := salt + "$" + EncodePassword("wetalk", salt)
+
+	ThrowFail(t, AssertIs(VerifyPassword("wetalk", encoded), true))
+	ThrowFail(t, AssertIs(VerifyPassword("fake", encoded), false))
+
+	setting.PasswordHasher = HasherBcrypt
+	ThrowFail(t, AssertIs(NeedsRehash(encoded), true))
+}
+
+func TestPasswordMaxBytes(t *testing.T) {
+	setting.PasswordMinLength = 8
+
+	// 3 bytes of each rune, 24 runes fill the 72 bytes of bcrypt
+	for size, valid := range map[int]bool{24: true, 25: false} {
+		v := validation.Validation{}
+		ValidPasswordPolicy(&v, "Password", strings.Repeat("密", size))
+		ThrowFail(t, AssertIs(v.HasErrors(), !valid), size)
+	}
+}
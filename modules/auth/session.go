@@ -0,0 +1,191 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+	"github.com/astaxie/beego/orm"
+	"github.com/astaxie/beego/session"
+
+	"github.com/varding/wetalk/modules/models"
+)
+
+// last seen time and ip of login session are updated in this interval
+const loginSessionTouchSeconds = 300
+
+// previous remember token is still accepted in this interval after rotation,
+// concurrent requests of a device carry the same old cookie
+const rememberGraceSeconds = 60
+
+// keywords in user agent, first matched is the name
+var (
+	uaBrowsers = [][2]string{
+		{"Edge/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Chrome/", "Chrome"},
+		{"Firefox/", "Firefox"},
+		{"MSIE ", "Internet Explorer"},
+		{"Trident/", "Internet Explorer"},
+		{"Safari/", "Safari"},
+	}
+	uaSystems = [][2]string{
+		{"Windows", "Windows"},
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Mac OS X", "Mac OS X"},
+		{"Linux", "Linux"},
+	}
+)
+
+// short device name from user agent, as "Chrome on Windows"
+func DeviceName(ua string) string {
+	find := func(names [][2]string) string {
+		for _, name := range names {
+			if strings.Contains(ua, name[0]) {
+				return name[1]
+			}
+		}
+		return ""
+	}
+
+	browser, system := find(uaBrowsers), find(uaSystems)
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return "Unknown"
+}
+
+func GetLoginSessionId(sess session.SessionStore) int {
+	if id, ok := sess.Get("auth_login_session").(int); ok && id > 0 {
+		return id
+	}
+	return 0
+}
+
+// record login of user from current request, and bind it to browser session
+func createLoginSession(ls *models.LoginSession, user *models.User, ctx *context.Context) error {
+	ua := ctx.Input.UserAgent()
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+
+	*ls = models.LoginSession{
+		User:      user,
+		Device:    DeviceName(ua),
+		UserAgent: ua,
+		Ip:        ctx.Input.IP(),
+		LastSeen:  time.Now(),
+	}
+	if err := ls.Insert(); err != nil {
+		return err
+	}
+
+	ctx.Input.CruSession.Set("auth_login_session", ls.Id)
+	return nil
+}
+
+// check login session is not revoked or expired
+func IsLoginSessionAlive(ls *models.LoginSession) bool {
+	if ls.IsRevoked {
+		return false
+	}
+	if ls.IsRemember {
+		return ls.Expires.After(time.Now())
+	}
+	// browser session is destroyed after gc time
+	return time.Since(ls.LastSeen) < time.Duration(beego.SessionGCMaxLifetime)*time.Second
+}
+
+// check login session of logined browser session, update its last seen.
+// browser sessions logined before have no login session, create it.
+func CheckLoginSession(user *models.User, ctx *context.Context) bool {
+	var ls models.LoginSession
+
+	id := GetLoginSessionId(ctx.Input.CruSession)
+	if id == 0 {
+		if err := createLoginSession(&ls, user, ctx); err != nil {
+			beego.Error("CheckLoginSession: ", err)
+		}
+		return true
+	}
+
+	ls.Id = id
+	if err := ls.Read(); err != nil || ls.User.Id != user.Id || ls.IsRevoked {
+		return false
+	}
+
+	ip := ctx.Input.IP()
+	if ip != ls.Ip || time.Since(ls.LastSeen) > loginSessionTouchSeconds*time.Second {
+		ls.Ip = ip
+		ls.LastSeen = time.Now()
+		if err := ls.Update("Ip", "LastSeen"); err != nil {
+			beego.Error("CheckLoginSession: ", err)
+		}
+	}
+	return true
+}
+
+// alive login sessions of user, recently used first
+func UserLoginSessions(user *models.User) []models.LoginSession {
+	var list []models.LoginSession
+	models.LoginSessions().Filter("User", user.Id).Filter("IsRevoked", false).All(&list)
+
+	sessions := make([]models.LoginSession, 0, len(list))
+	for _, ls := range list {
+		if IsLoginSessionAlive(&ls) {
+			sessions = append(sessions, ls)
+		}
+	}
+	return sessions
+}
+
+// revoke a login session of user, the device is logged out
+func RevokeLoginSession(user *models.User, id int) error {
+	_, err := models.LoginSessions().Filter("Id", id).Filter("User", user.Id).Update(orm.Params{
+		"IsRevoked": true,
+	})
+	return err
+}
+
+// revoke all login sessions of user except one, zero except none
+func RevokeLoginSessions(user *models.User, except int) error {
+	qs := models.LoginSessions().Filter("User", user.Id).Filter("IsRevoked", false)
+	if except > 0 {
+		qs = qs.Exclude("Id", except)
+	}
+	_, err := qs.Update(orm.Params{
+		"IsRevoked": true,
+	})
+	return err
+}
+
+func revokeCurrentLoginSession(ctx *context.Context) {
+	if id := GetLoginSessionId(ctx.Input.CruSession); id > 0 {
+		ls := models.LoginSession{Id: id, IsRevoked: true}
+		if err := ls.Update("IsRevoked"); err != nil {
+			beego.Error("LogoutUser: ", err)
+		}
+	}
+}
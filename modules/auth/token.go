@@ -0,0 +1,247 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/context"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// scopes in grant order, a scope implies all scopes before it
+var TokenScopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+const tokenPrefix = "wt_"
+
+// hash a raw token or client secret for storage
+func HashToken(raw string) string {
+	return utils.EncodeHmac(setting.SecretKey, raw, sha256.New)
+}
+
+// filter unknown scopes and return them in a comma separated string
+func CleanScopes(scopes []string) string {
+	clean := make([]string, 0, len(TokenScopes))
+	for _, s := range TokenScopes {
+		for _, v := range scopes {
+			if strings.TrimSpace(v) == s {
+				clean = append(clean, s)
+				break
+			}
+		}
+	}
+	return strings.Join(clean, ",")
+}
+
+// check token grants scope, admin implies write and write implies read
+func TokenAllows(token *models.AccessToken, scope string) bool {
+	need := -1
+	for i, s := range TokenScopes {
+		if s == scope {
+			need = i
+		}
+	}
+	if need == -1 {
+		return false
+	}
+	for i := need; i < len(TokenScopes); i++ {
+		if token.HasScope(TokenScopes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// create a new access token, return the raw token which only can be shown once
+func CreateAccessToken(token *models.AccessToken, user *models.User, name string, scopes []string, liveDays int) (string, error) {
+	raw := tokenPrefix + utils.GetRandomString(40)
+
+	token.User = user
+	token.Name = name
+	token.Token = HashToken(raw)
+	token.Scopes = CleanScopes(scopes)
+	if liveDays > 0 {
+		token.Expires = time.Now().AddDate(0, 0, liveDays)
+	}
+
+	if err := token.Insert(); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// revoke access token of user
+func RevokeAccessToken(user *models.User, id int) error {
+	token := models.AccessToken{Id: id}
+	if err := token.Read(); err != nil {
+		return err
+	}
+	if token.User.Id != user.Id {
+		return fmt.Errorf("token %d not belong to user %d", id, user.Id)
+	}
+	token.IsRevoked = true
+	return token.Update("IsRevoked")
+}
+
+// count user's alive personal access tokens
+func CountUserTokens(user *models.User) int64 {
+	cnt, _ := models.AccessTokens().Filter("User", user.Id).Filter("App__isnull", true).Filter("IsRevoked", false).Count()
+	return cnt
+}
+
+// get raw token from "Authorization: Bearer <token>" header
+func GetBearerToken(ctx *context.Context) string {
+	value := ctx.Input.Header("Authorization")
+	if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+	return ""
+}
+
+// login user by bearer token, no session and cookie will be written
+func LoginUserFromToken(user *models.User, token *models.AccessToken, ctx *context.Context) bool {
+	raw := GetBearerToken(ctx)
+	if len(raw) == 0 {
+		return false
+	}
+
+	token.Token = HashToken(raw)
+	if err := token.Read("Token"); err != nil {
+		return false
+	}
+
+	if token.IsRevoked || token.IsExpired() {
+		return false
+	}
+
+	u := models.User{Id: token.User.Id}
+	if err := u.Read(); err != nil {
+		return false
+	}
+	*user = u
+
+	// only record last used once a minute to avoid write on every request
+	if time.Since(token.LastUsed) > time.Minute || token.LastIp != ctx.Input.IP() {
+		token.LastUsed = time.Now()
+		token.LastIp = ctx.Input.IP()
+		token.Update("LastUsed", "LastIp")
+	}
+
+	return true
+}
+
+// register a new oauth app, return the raw client secret which only can be shown once
+func CreateOAuthApp(app *models.OAuthApp, user *models.User) (string, error) {
+	secret := utils.GetRandomString(40)
+
+	app.User = user
+	app.ClientId = utils.GetRandomString(20)
+	app.ClientSecret = HashToken(secret)
+
+	if err := app.Insert(); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// verify oauth app client id and secret
+func VerifyOAuthClient(app *models.OAuthApp, clientId, secret string) bool {
+	if len(clientId) == 0 || len(secret) == 0 {
+		return false
+	}
+	app.ClientId = clientId
+	if err := app.Read("ClientId"); err != nil {
+		return false
+	}
+	return app.ClientSecret == HashToken(secret)
+}
+
+// redirect uri must exactly match the registered one, codes are never sent
+// to other paths or queries on the same host
+func IsValidRedirectUri(app *models.OAuthApp, uri string) bool {
+	if len(uri) == 0 {
+		return true
+	}
+	u, err := url.Parse(uri)
+	if err != nil || u.User != nil || u.Fragment != "" {
+		return false
+	}
+	r, err := url.Parse(app.RedirectUri)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == r.Scheme && strings.EqualFold(u.Host, r.Host) &&
+		u.EscapedPath() == r.EscapedPath() && u.RawQuery == r.RawQuery
+}
+
+// create an authorization code after user approved the app
+func CreateOAuthCode(app *models.OAuthApp, user *models.User, scopes []string, redirectUri string) (string, error) {
+	raw := utils.GetRandomString(30)
+
+	code := models.OAuthCode{
+		App:         app,
+		User:        user,
+		Code:        HashToken(raw),
+		Scopes:      CleanScopes(scopes),
+		RedirectUri: redirectUri,
+		Expires:     time.Now().Add(time.Minute * time.Duration(setting.OAuthCodeLives)),
+	}
+
+	if err := code.Insert(); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// exchange authorization code to an access token, the code can only be used once
+func ExchangeOAuthCode(token *models.AccessToken, app *models.OAuthApp, raw, redirectUri string) (string, error) {
+	code := models.OAuthCode{Code: HashToken(raw)}
+	if err := code.Read("Code"); err != nil {
+		return "", fmt.Errorf("invalid code")
+	}
+
+	// code is single use, only the exchange which deleted it gets a token
+	if num, err := models.OAuthCodes().Filter("Id", code.Id).Delete(); err != nil || num != 1 {
+		return "", fmt.Errorf("invalid code")
+	}
+
+	if code.App.Id != app.Id {
+		return "", fmt.Errorf("code not issued to client %s", app.ClientId)
+	}
+
+	if code.Expires.Before(time.Now()) {
+		return "", fmt.Errorf("code expired")
+	}
+
+	if code.RedirectUri != redirectUri {
+		return "", fmt.Errorf("redirect_uri mismatch")
+	}
+
+	token.App = app
+	return CreateAccessToken(token, code.User, app.Name, strings.Split(code.Scopes, ","), setting.OAuthTokenLiveDays)
+}
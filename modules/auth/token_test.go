@@ -0,0 +1,44 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/varding/wetalk/modules/models"
+	. "github.com/varding/wetalk/modules/utils"
+)
+
+func TestIsValidRedirectUri(t *testing.T) {
+	app := &models.OAuthApp{RedirectUri: "https://example.com/cb?app=1"}
+
+	cases := map[string]bool{
+		"":                                  true,
+		"https://example.com/cb?app=1":      true,
+		"https://EXAMPLE.com/cb?app=1":      true,
+		"https://example.com/cbevil?app=1":  false,
+		"https://example.com/cb/../x?app=1": false,
+		"https://example.com/cb/sub?app=1":  false,
+		"https://example.com/cb":            false,
+		"https://example.com/cb?app=2":      false,
+		"https://example.com/cb?app=1#x":    false,
+		"https://u@example.com/cb?app=1":    false,
+		"http://example.com/cb?app=1":       false,
+		"https://example.com.evil/cb?app=1": false,
+	}
+	for uri, valid := range cases {
+		ThrowFail(t, AssertIs(IsValidRedirectUri(app, uri), valid), uri)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/astaxie/beego/session"
+	"rsc.io/qr"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+const (
+	// totp of rfc 6238, same as authenticator apps
+	totpDigits = 6
+	totpPeriod = 30
+
+	// accepted clock drift in periods
+	totpSkew = 1
+
+	RecoveryCodeCount = 10
+
+	// password checked login waits for the second factor in seconds
+	twoFactorPendingLives = 300
+)
+
+// create a random base32 secret for totp
+func GenerateTotpSecret() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return base32.StdEncoding.EncodeToString(b)
+}
+
+// code of secret at time step
+func totpCode(secret string, step int64) string {
+	key, err := base32.StdEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return ""
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	h := hmac.New(sha1.New, key)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// time step the code matched, -1 if not matched
+func matchTotp(secret, code string, t time.Time) int64 {
+	code = strings.Replace(strings.TrimSpace(code), " ", "", -1)
+	if len(code) != totpDigits {
+		return -1
+	}
+
+	now := t.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		step := now + int64(i)
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step
+		}
+	}
+	return -1
+}
+
+// check code of a secret not saved yet
+func CheckTotpCode(secret, code string) bool {
+	return matchTotp(secret, code, time.Now()) >= 0
+}
+
+// verify totp code of user, accepted code can't be used again
+func VerifyTotp(tf *models.TwoFactor, code string) bool {
+	step := matchTotp(tf.Secret, code, time.Now())
+	if step < 0 || step <= tf.LastStep {
+		return false
+	}
+
+	// only one of concurrent requests with the same code moves last step
+	num, err := models.TwoFactors().Filter("Id", tf.Id).Filter("LastStep__lt", step).Update(orm.Params{
+		"LastStep": step,
+	})
+	if err != nil || num != 1 {
+		return false
+	}
+	tf.LastStep = step
+	return true
+}
+
+// otpauth uri for authenticator apps
+func TotpUri(user *models.User, secret string) string {
+	escape := func(s string) string {
+		return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+	}
+	return fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s&digits=%d&period=%d",
+		escape(setting.AppName), escape(user.UserName), secret, escape(setting.AppName), totpDigits, totpPeriod)
+}
+
+// qr code png of totp uri as data uri, can be src of img
+func TotpQRCode(uri string) (string, error) {
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()), nil
+}
+
+// get enabled two-factor of user
+func GetTwoFactor(tf *models.TwoFactor, user *models.User) bool {
+	err := models.TwoFactors().Filter("User", user.Id).Filter("IsEnabled", true).Limit(1).One(tf)
+	return err == nil
+}
+
+// check user need second factor when login
+func HasTwoFactor(user *models.User) bool {
+	return models.TwoFactors().Filter("User", user.Id).Filter("IsEnabled", true).Exist()
+}
+
+// enable two-factor with checked secret, return new recovery codes
+func EnableTwoFactor(user *models.User, secret string) ([]string, error) {
+	var tf models.TwoFactor
+	if err := models.TwoFactors().Filter("User", user.Id).Limit(1).One(&tf); err != nil {
+		tf = models.TwoFactor{User: user}
+	}
+
+	tf.Secret = secret
+	tf.IsEnabled = true
+	// code used when enable can't login
+	tf.LastStep = time.Now().Unix()/totpPeriod + totpSkew
+
+	var err error
+	if tf.Id > 0 {
+		err = tf.Update("Secret", "IsEnabled", "LastStep", "Updated")
+	} else {
+		err = tf.Insert()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateRecoveryCodes(user)
+}
+
+// disable two-factor and remove recovery codes
+func DisableTwoFactor(user *models.User) error {
+	if _, err := models.TwoFactors().Filter("User", user.Id).Delete(); err != nil {
+		return err
+	}
+	_, err := models.RecoveryCodes().Filter("User", user.Id).Delete()
+	return err
+}
+
+// replace recovery codes of user, return raw codes which only can be shown once
+func CreateRecoveryCodes(user *models.User) ([]string, error) {
+	if _, err := models.RecoveryCodes().Filter("User", user.Id).Delete(); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := strings.ToLower(utils.GetRandomString(10))
+		raw = raw[:5] + "-" + raw[5:]
+
+		code := models.RecoveryCode{User: user, Code: HashToken(raw)}
+		if err := code.Insert(); err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw)
+	}
+	return codes, nil
+}
+
+// login with recovery code, the code is used up
+func UseRecoveryCode(user *models.User, raw string) bool {
+	raw = strings.ToLower(strings.Replace(strings.TrimSpace(raw), " ", "", -1))
+	if len(raw) == 10 {
+		raw = raw[:5] + "-" + raw[5:]
+	}
+
+	var code models.RecoveryCode
+	err := models.RecoveryCodes().Filter("User", user.Id).Filter("Code", HashToken(raw)).Filter("IsUsed", false).Limit(1).One(&code)
+	if err != nil {
+		return false
+	}
+
+	code.IsUsed = true
+	code.Used = time.Now()
+	return code.Update("IsUsed", "Used") == nil
+}
+
+// count recovery codes not used
+func CountRecoveryCodes(user *models.User) int64 {
+	cnt, _ := models.RecoveryCodes().Filter("User", user.Id).Filter("IsUsed", false).Count()
+	return cnt
+}
+
+// password checked, keep user in session until the second factor passed
+func SetTwoFactorPending(sess session.SessionStore, user *models.User, remember bool) {
+	sess.Set("auth_2fa_user_id", user.Id)
+	sess.Set("auth_2fa_remember", remember)
+	sess.Set("auth_2fa_time", time.Now().Unix())
+}
+
+// get user waiting for the second factor
+func GetTwoFactorPending(user *models.User, sess session.SessionStore) (remember bool, ok bool) {
+	id, _ := sess.Get("auth_2fa_user_id").(int)
+	created, _ := sess.Get("auth_2fa_time").(int64)
+	if id <= 0 || time.Now().Unix()-created > twoFactorPendingLives {
+		return false, false
+	}
+
+	*user = models.User{Id: id}
+	if err := user.Read(); err != nil {
+		return false, false
+	}
+
+	remember, _ = sess.Get("auth_2fa_remember").(bool)
+	return remember, true
+}
+
+func ClearTwoFactorPending(sess session.SessionStore) {
+	sess.Delete("auth_2fa_user_id")
+	sess.Delete("auth_2fa_remember")
+	sess.Delete("auth_2fa_time")
+}
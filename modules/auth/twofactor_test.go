@@ -0,0 +1,45 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	. "github.com/varding/wetalk/modules/utils"
+)
+
+// test vectors of rfc 6238, sha1, last 6 digits
+func TestTotpCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, code := range vectors {
+		ThrowFail(t, AssertIs(totpCode(secret, unix/totpPeriod), code))
+		ThrowFail(t, AssertIs(matchTotp(secret, code, time.Unix(unix, 0)), unix/totpPeriod))
+	}
+
+	// previous and next period are accepted
+	ThrowFail(t, AssertIs(matchTotp(secret, "287082", time.Unix(59+totpPeriod, 0)), int64(1)))
+	ThrowFail(t, AssertIs(matchTotp(secret, "287082", time.Unix(59+totpPeriod*3, 0)), int64(-1)))
+	ThrowFail(t, AssertIs(matchTotp(secret, "28708", time.Unix(59, 0)), int64(-1)))
+}
@@ -0,0 +1,157 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package avatar crops uploaded avatars and generates default avatars of users.
+package avatar
+
+import (
+	"bytes"
+	"crypto/md5"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"unicode"
+
+	"github.com/nfnt/resize"
+)
+
+// Sizes are widths of square avatars used by pages.
+var Sizes = []int{24, 48, 64, 100, 200}
+
+// styles of generated avatars
+const (
+	StyleIdenticon = "identicon"
+	StyleInitials  = "initials"
+)
+
+// IsSize checks whether size is one of avatar sizes.
+func IsSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Crop cuts the center square of image.
+func Crop(im image.Image) image.Image {
+	b := im.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	min := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), im, min, draw.Src)
+	return square
+}
+
+// Resize crops image to square and resizes it to size.
+func Resize(im image.Image, size int) image.Image {
+	return resize.Resize(uint(size), uint(size), Crop(im), resize.Lanczos3)
+}
+
+// Generate renders default avatar of style for name as png.
+func Generate(style string, name string, size int) ([]byte, error) {
+	var im image.Image
+	if style == StyleInitials {
+		im = Initials(name, size)
+	} else {
+		im = Identicon(name, size)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, im); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// foreground color picked from hash, saturated enough to read on white
+func hashColor(sum [md5.Size]byte) color.RGBA {
+	c := color.RGBA{sum[0], sum[1], sum[2], 0xff}
+	max := c.R
+	if c.G > max {
+		max = c.G
+	}
+	if c.B > max {
+		max = c.B
+	}
+	// darken light colors
+	if max > 0xc0 {
+		c.R = uint8(int(c.R) * 0xc0 / int(max))
+		c.G = uint8(int(c.G) * 0xc0 / int(max))
+		c.B = uint8(int(c.B) * 0xc0 / int(max))
+	}
+	return c
+}
+
+// Identicon renders symmetric 5x5 blocks from hash of seed.
+func Identicon(seed string, size int) image.Image {
+	sum := md5.Sum([]byte(seed))
+	fg := image.NewUniform(hashColor(sum))
+
+	im := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(im, im.Bounds(), image.NewUniform(color.RGBA{0xf0, 0xf0, 0xf0, 0xff}), image.ZP, draw.Src)
+
+	cell := size / 6
+	margin := (size - cell*5) / 2
+	for i := 0; i < 15; i++ {
+		// bits of hash after color bytes decide blocks of left three columns
+		if sum[3+i/8]>>uint(i%8)&1 == 0 {
+			continue
+		}
+		x, y := i/5, i%5
+		for _, col := range []int{x, 4 - x} {
+			r := image.Rect(col*cell, y*cell, (col+1)*cell, (y+1)*cell).Add(image.Pt(margin, margin))
+			draw.Draw(im, r, fg, image.ZP, draw.Src)
+		}
+	}
+	return im
+}
+
+// Initials renders first letter of name on color from hash of name,
+// names not starting with latin letter or digit fall back to identicon.
+func Initials(name string, size int) image.Image {
+	var glyph string
+	for _, r := range name {
+		glyph = glyphs[unicode.ToUpper(r)]
+		break
+	}
+	if glyph == "" {
+		return Identicon(name, size)
+	}
+
+	sum := md5.Sum([]byte(name))
+	im := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(im, im.Bounds(), image.NewUniform(hashColor(sum)), image.ZP, draw.Src)
+
+	// glyph is about half of avatar height
+	scale := size / 14
+	if scale < 1 {
+		scale = 1
+	}
+	x0, y0 := (size-5*scale)/2, (size-7*scale)/2
+	for i, c := range glyph {
+		if c != '#' {
+			continue
+		}
+		x, y := i%5, i/5
+		r := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale).Add(image.Pt(x0, y0))
+		draw.Draw(im, r, image.White, image.ZP, draw.Src)
+	}
+	return im
+}
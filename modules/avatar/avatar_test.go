@@ -0,0 +1,76 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	. "github.com/varding/wetalk/modules/utils"
+)
+
+func TestCrop(t *testing.T) {
+	im := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	im.Set(15, 5, color.White)
+
+	square := Crop(im)
+	ThrowFail(t, AssertIs(square.Bounds(), image.Rect(0, 0, 10, 10)))
+	ThrowFail(t, AssertIs(color.RGBAModel.Convert(square.At(5, 5)), color.RGBA{0xff, 0xff, 0xff, 0xff}))
+
+	for _, size := range Sizes {
+		ThrowFail(t, AssertIs(Resize(im, size).Bounds(), image.Rect(0, 0, size, size)))
+	}
+}
+
+func TestIdenticon(t *testing.T) {
+	a := Identicon("slene", 48)
+	ThrowFail(t, AssertIs(a.Bounds(), image.Rect(0, 0, 48, 48)))
+
+	// same seed same image, left and right columns are symmetric
+	b := Identicon("slene", 48)
+	var other bool
+	c := Identicon("astaxie", 48)
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 48; x++ {
+			ThrowFail(t, AssertIs(a.At(x, y), b.At(x, y)))
+			ThrowFail(t, AssertIs(a.At(x, y), a.At(47-x, y)))
+			if a.At(x, y) != c.At(x, y) {
+				other = true
+			}
+		}
+	}
+	ThrowFail(t, AssertIs(other, true))
+}
+
+func TestInitials(t *testing.T) {
+	for _, size := range Sizes {
+		data, err := Generate(StyleInitials, "wetalk", size)
+		ThrowFailNow(t, err)
+		im, err := png.Decode(bytes.NewReader(data))
+		ThrowFailNow(t, err)
+		ThrowFail(t, AssertIs(im.Bounds(), image.Rect(0, 0, size, size)))
+	}
+
+	// glyph scaled 5 times at center
+	im := Initials("w", 70)
+	ThrowFail(t, AssertIs(color.RGBAModel.Convert(im.At(24, 19)), color.RGBA{0xff, 0xff, 0xff, 0xff}))
+	ThrowFail(t, AssertIs(im.At(29, 19), im.At(0, 0)))
+
+	// no glyph for the name
+	ThrowFail(t, AssertIs(Initials("小明", 24).Bounds(), Identicon("小明", 24).Bounds()))
+}
@@ -0,0 +1,55 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package avatar
+
+// glyphs of 5x7 bitmap font for initials, rows from top to bottom, `#` is set
+var glyphs = map[rune]string{
+	'A': ".###.#...##...#######...##...##...#",
+	'B': "####.#...##...#####.#...##...#####.",
+	'C': ".###.#...##....#....#....#...#.###.",
+	'D': "####.#...##...##...##...##...#####.",
+	'E': "######....#....####.#....#....#####",
+	'F': "######....#....####.#....#....#....",
+	'G': ".###.#...##....#.####...##...#.####",
+	'H': "#...##...##...#######...##...##...#",
+	'I': ".###...#....#....#....#....#...###.",
+	'J': "..###...#....#....#....#.#..#..##..",
+	'K': "#...##..#.#.#..##...#.#..#..#.#...#",
+	'L': "#....#....#....#....#....#....#####",
+	'M': "#...###.###.#.##.#.##...##...##...#",
+	'N': "#...##...###..##.#.##..###...##...#",
+	'O': ".###.#...##...##...##...##...#.###.",
+	'P': "####.#...##...#####.#....#....#....",
+	'Q': ".###.#...##...##...##.#.##..#..##.#",
+	'R': "####.#...##...#####.#.#..#..#.#...#",
+	'S': ".#####....#.....###.....#....#####.",
+	'T': "#####..#....#....#....#....#....#..",
+	'U': "#...##...##...##...##...##...#.###.",
+	'V': "#...##...##...##...##...#.#.#...#..",
+	'W': "#...##...##...##.#.##.#.##.#.#.#.#.",
+	'X': "#...##...#.#.#...#...#.#.#...##...#",
+	'Y': "#...##...#.#.#...#....#....#....#..",
+	'Z': "#####....#...#...#...#...#....#####",
+	'0': ".###.#...##..###.#.###..##...#.###.",
+	'1': "..#...##....#....#....#....#...###.",
+	'2': ".###.#...#....#...#...#...#...#####",
+	'3': "####.....#....#.###.....#....#####.",
+	'4': "...#...##..#.#.#..#.#####...#....#.",
+	'5': "######....####.....#....##...#.###.",
+	'6': "..##..#...#....####.#...##...#.###.",
+	'7': "#####....#...#...#...#....#....#...",
+	'8': ".###.#...##...#.###.#...##...#.###.",
+	'9': ".###.#...##...#.####....#...#..##..",
+}
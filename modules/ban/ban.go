@@ -0,0 +1,166 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package ban implemented user suspensions, mutes and ip or email domain bans.
+package ban
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+const (
+	KindUser  = "user"
+	KindIp    = "ip"
+	KindEmail = "email"
+)
+
+var Kinds = []string{KindUser, KindIp, KindEmail}
+
+// deactivate an expired ban, it will be kept for audit
+func expire(b *models.Ban) {
+	b.IsActive = false
+	b.Lifted = b.Expires
+	if err := b.Update("IsActive", "Lifted"); err != nil {
+		beego.Error("Ban: expire ", err)
+	}
+}
+
+// load active bans of kind, expired ones are deactivated and skipped
+func activeBans(kind string, filter func(*models.Ban) bool) []*models.Ban {
+	var bans []*models.Ban
+	qs := models.Bans().Filter("Kind", kind).Filter("IsActive", true)
+	if _, err := qs.All(&bans); err != nil {
+		beego.Error("Ban: load ", err)
+		return nil
+	}
+
+	active := make([]*models.Ban, 0, len(bans))
+	for _, b := range bans {
+		if b.IsExpired() {
+			expire(b)
+			continue
+		}
+		if filter == nil || filter(b) {
+			active = append(active, b)
+		}
+	}
+	return active
+}
+
+// active ban of user, suspension takes precedence over mute
+func UserBan(user *models.User) *models.Ban {
+	if user == nil || user.Id == 0 {
+		return nil
+	}
+
+	var bans []*models.Ban
+	qs := models.Bans().Filter("Kind", KindUser).Filter("User", user.Id).Filter("IsActive", true)
+	if _, err := qs.All(&bans); err != nil {
+		beego.Error("Ban: load ", err)
+		return nil
+	}
+
+	var found *models.Ban
+	for _, b := range bans {
+		if b.IsExpired() {
+			expire(b)
+			continue
+		}
+		if found == nil || found.IsMute() && !b.IsMute() {
+			found = b
+		}
+	}
+	return found
+}
+
+// active ban matching ip, value can be a single ip or a CIDR
+func MatchIp(ip string) *models.Ban {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil
+	}
+	bans := activeBans(KindIp, func(b *models.Ban) bool {
+		if strings.Contains(b.Value, "/") {
+			_, network, err := net.ParseCIDR(b.Value)
+			return err == nil && network.Contains(addr)
+		}
+		return addr.Equal(net.ParseIP(b.Value))
+	})
+	if len(bans) > 0 {
+		return bans[0]
+	}
+	return nil
+}
+
+// active ban matching domain of email, subdomains are also matched
+func MatchEmail(email string) *models.Ban {
+	i := strings.LastIndex(email, "@")
+	if i == -1 {
+		return nil
+	}
+	domain := strings.ToLower(email[i+1:])
+	bans := activeBans(KindEmail, func(b *models.Ban) bool {
+		value := strings.ToLower(strings.TrimPrefix(b.Value, "@"))
+		return domain == value || strings.HasSuffix(domain, "."+value)
+	})
+	if len(bans) > 0 {
+		return bans[0]
+	}
+	return nil
+}
+
+// ban user by operator, zero duration is permanent
+func BanUser(user, operator *models.User, mode int, duration time.Duration, reason string) (*models.Ban, error) {
+	if user.IsAdmin {
+		return nil, fmt.Errorf("can not ban admin %s", user.UserName)
+	}
+	if mode != setting.BAN_SUSPEND && mode != setting.BAN_MUTE {
+		return nil, fmt.Errorf("unknown ban mode %d", mode)
+	}
+
+	b := &models.Ban{
+		Kind:     KindUser,
+		User:     user,
+		Mode:     mode,
+		Reason:   reason,
+		IsActive: true,
+		Operator: operator,
+	}
+	if duration > 0 {
+		b.Expires = time.Now().Add(duration)
+	}
+	if err := b.Insert(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// lift ban before it expires
+func Lift(b *models.Ban, lifter *models.User) error {
+	if !b.IsActive {
+		return fmt.Errorf("ban %d already inactive", b.Id)
+	}
+	b.IsActive = false
+	b.Lifter = lifter
+	b.Lifted = time.Now()
+	return b.Update("IsActive", "Lifter", "Lifted")
+}
@@ -0,0 +1,145 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package ban
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+var modes = [][]string{
+	{"model.ban_mode_suspend", utils.ToStr(setting.BAN_SUSPEND)},
+	{"model.ban_mode_mute", utils.ToStr(setting.BAN_MUTE)},
+}
+
+type BanAdminForm struct {
+	Create bool   `form:"-"`
+	Id     int    `form:"-"`
+	Kind   string `form:"type(select);attr(rel,select2)" valid:"Required"`
+	User   int    `form:"attr(rel,select2-admin-model);attr(data-model,User)"`
+	Value  string `valid:"MaxSize(100)"`
+	Mode   int    `form:"type(select);attr(rel,select2)"`
+	Reason string `form:"type(textarea)" valid:"Required;MaxSize(255)"`
+	Days   int    ``
+}
+
+func (form *BanAdminForm) KindSelectData() [][]string {
+	data := make([][]string, 0, len(Kinds))
+	for _, kind := range Kinds {
+		data = append(data, []string{"model.ban_kind_" + kind, kind})
+	}
+	return data
+}
+
+func (form *BanAdminForm) ModeSelectData() [][]string {
+	return modes
+}
+
+func (form *BanAdminForm) Valid(v *validation.Validation) {
+	switch form.Kind {
+	case KindUser:
+		user := models.User{Id: form.User}
+		if user.Read() != nil {
+			v.SetError("User", "admin.not_found_by_id")
+		} else if user.IsAdmin {
+			v.SetError("User", "admin.ban_admin")
+		}
+		if form.Mode != setting.BAN_SUSPEND && form.Mode != setting.BAN_MUTE {
+			v.SetError("Mode", "Not Found")
+		}
+	case KindIp:
+		if net.ParseIP(form.Value) == nil {
+			if _, _, err := net.ParseCIDR(form.Value); err != nil {
+				v.SetError("Value", "admin.ban_invalid_ip")
+			}
+		}
+	case KindEmail:
+		if len(form.Value) == 0 || strings.ContainsAny(form.Value, " /") {
+			v.SetError("Value", "admin.ban_invalid_domain")
+		}
+	default:
+		v.SetError("Kind", "Not Found")
+	}
+
+	if form.Days < 0 {
+		v.SetError("Days", "admin.ban_invalid_days")
+	}
+}
+
+func (form *BanAdminForm) Labels() map[string]string {
+	return map[string]string{
+		"Kind":   "model.ban_kind",
+		"User":   "model.user",
+		"Value":  "model.ban_value",
+		"Mode":   "model.ban_mode",
+		"Reason": "model.ban_reason",
+		"Days":   "model.ban_days",
+	}
+}
+
+func (form *BanAdminForm) Helps() map[string]string {
+	return map[string]string{
+		"Value": "model.ban_value_help",
+		"Mode":  "model.ban_mode_help",
+		"Days":  "model.ban_days_help",
+	}
+}
+
+func (form *BanAdminForm) SetFromBan(b *models.Ban) {
+	form.Kind = b.Kind
+	if b.User != nil {
+		form.User = b.User.Id
+	}
+	form.Value = b.Value
+	form.Mode = b.Mode
+	form.Reason = b.Reason
+	if !b.IsPermanent() {
+		form.Days = int(b.Expires.Sub(b.Created).Hours()+12) / 24
+	}
+}
+
+// days count from created time, zero is permanent
+func (form *BanAdminForm) SetToBan(b *models.Ban) {
+	b.Kind = form.Kind
+	b.User = nil
+	b.Value = ""
+	b.Mode = setting.BAN_SUSPEND
+	switch form.Kind {
+	case KindUser:
+		b.User = &models.User{Id: form.User}
+		b.Mode = form.Mode
+	case KindEmail:
+		b.Value = strings.ToLower(strings.TrimPrefix(form.Value, "@"))
+	default:
+		b.Value = form.Value
+	}
+	b.Reason = form.Reason
+
+	created := b.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+	b.Expires = time.Time{}
+	if form.Days > 0 {
+		b.Expires = created.Add(time.Duration(form.Days) * 24 * time.Hour)
+	}
+}
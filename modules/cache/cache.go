@@ -0,0 +1,94 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package cache implements cache adapters shared by app instances, they are
+// used as setting.Cache with the same methods as beego memory cache.
+//
+// Values are saved with their types, int, int64, bool, string and []byte are
+// read back as they are put, other values are read back as decoded json.
+// Ints are saved as decimal text so Incr and Decr work on them.
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+var ErrNotNumber = errors.New("cache: value is not a number")
+
+// max idle connections to each server kept for reuse
+const maxIdleConns = 8
+
+// encode value with its type
+func encode(val interface{}) ([]byte, error) {
+	switch v := val.(type) {
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case int64:
+		return append([]byte{'l'}, strconv.FormatInt(v, 10)...), nil
+	case bool:
+		if v {
+			return []byte{'t'}, nil
+		}
+		return []byte{'f'}, nil
+	case string:
+		return append([]byte{'s'}, v...), nil
+	case []byte:
+		return append([]byte{'b'}, v...), nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{'j'}, data...), nil
+}
+
+// decode value encoded by encode, nil if it is invalid
+func decode(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	switch c := data[0]; {
+	case c == '-' || c >= '0' && c <= '9':
+		if n, err := strconv.Atoi(string(data)); err == nil {
+			return n
+		}
+	case c == 'l':
+		if n, err := strconv.ParseInt(string(data[1:]), 10, 64); err == nil {
+			return n
+		}
+	case c == 't':
+		return true
+	case c == 'f':
+		return false
+	case c == 's':
+		return string(data[1:])
+	case c == 'b':
+		return append([]byte{}, data[1:]...)
+	case c == 'j':
+		var v interface{}
+		if json.Unmarshal(data[1:], &v) == nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func hashKey(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
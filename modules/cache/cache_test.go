@@ -0,0 +1,307 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tests can't use modules/utils, it imports setting which imports this package
+
+func expectEqual(t *testing.T, got, expected interface{}) {
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, got %#v", expected, got)
+	}
+}
+
+func mustEqual(t *testing.T, got, expected interface{}) {
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, got)
+	}
+}
+
+// methods of setting.Cache
+type testCache interface {
+	Get(key string) interface{}
+	Put(key string, val interface{}, timeout int64) error
+	Delete(key string) error
+	Incr(key string) error
+	Decr(key string) error
+	IsExist(key string) bool
+	ClearAll() error
+}
+
+// values of fake server, locked when running commands
+type fakeData struct {
+	sync.Mutex
+	values map[string]string
+}
+
+// local fake server, handle reads command from conn and writes reply
+func fakeServer(t *testing.T, handle func(r *bufio.Reader, w io.Writer, data *fakeData) error) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	mustEqual(t, err, nil)
+
+	data := &fakeData{values: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for handle(r, conn, data) == nil {
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func fakeRedis(r *bufio.Reader, w io.Writer, fake *fakeData) error {
+	reply, err := readRedisReply(r)
+	if err != nil {
+		return err
+	}
+	fake.Lock()
+	defer fake.Unlock()
+	data := fake.values
+
+	var args []string
+	for _, arg := range reply.([]interface{}) {
+		args = append(args, string(arg.([]byte)))
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING", "SELECT":
+		io.WriteString(w, "+OK\r\n")
+	case "AUTH":
+		if args[1] != "secret" {
+			io.WriteString(w, "-ERR invalid password\r\n")
+		} else {
+			io.WriteString(w, "+OK\r\n")
+		}
+	case "GET":
+		if v, ok := data[args[1]]; ok {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+		} else {
+			io.WriteString(w, "$-1\r\n")
+		}
+	case "SET":
+		data[args[1]] = args[2]
+		io.WriteString(w, "+OK\r\n")
+	case "DEL":
+		for _, key := range args[1:] {
+			delete(data, key)
+		}
+		fmt.Fprintf(w, ":%d\r\n", len(args)-1)
+	case "INCR", "DECR":
+		n, err := strconv.Atoi(data[args[1]])
+		if _, ok := data[args[1]]; ok && err != nil {
+			io.WriteString(w, "-ERR value is not an integer\r\n")
+			break
+		}
+		if args[0] == "INCR" {
+			n++
+		} else {
+			n--
+		}
+		data[args[1]] = strconv.Itoa(n)
+		fmt.Fprintf(w, ":%d\r\n", n)
+	case "EXISTS":
+		if _, ok := data[args[1]]; ok {
+			io.WriteString(w, ":1\r\n")
+		} else {
+			io.WriteString(w, ":0\r\n")
+		}
+	case "SCAN":
+		var keys []string
+		for key := range data {
+			if ok, _ := path.Match(args[3], key); ok {
+				keys = append(keys, key)
+			}
+		}
+		fmt.Fprintf(w, "*2\r\n$1\r\n0\r\n*%d\r\n", len(keys))
+		for _, key := range keys {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(key), key)
+		}
+	default:
+		io.WriteString(w, "-ERR unknown command\r\n")
+	}
+	return nil
+}
+
+func fakeMemcache(r *bufio.Reader, w io.Writer, fake *fakeData) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	fake.Lock()
+	defer fake.Unlock()
+	data := fake.values
+
+	fields := strings.Fields(line)
+
+	switch fields[0] {
+	case "get":
+		if v, ok := data[fields[1]]; ok {
+			fmt.Fprintf(w, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(v), v)
+		}
+		io.WriteString(w, "END\r\n")
+	case "set":
+		n, _ := strconv.Atoi(fields[4])
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return err
+		}
+		data[fields[1]] = string(value[:n])
+		io.WriteString(w, "STORED\r\n")
+	case "delete":
+		if _, ok := data[fields[1]]; ok {
+			delete(data, fields[1])
+			io.WriteString(w, "DELETED\r\n")
+		} else {
+			io.WriteString(w, "NOT_FOUND\r\n")
+		}
+	case "incr", "decr":
+		v, ok := data[fields[1]]
+		if !ok {
+			io.WriteString(w, "NOT_FOUND\r\n")
+			break
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			io.WriteString(w, "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			break
+		}
+		if fields[0] == "incr" {
+			n++
+		} else if n > 0 {
+			n--
+		}
+		data[fields[1]] = strconv.FormatUint(n, 10)
+		fmt.Fprintf(w, "%d\r\n", n)
+	case "flush_all":
+		for key := range data {
+			delete(data, key)
+		}
+		io.WriteString(w, "OK\r\n")
+	default:
+		io.WriteString(w, "ERROR\r\n")
+	}
+	return nil
+}
+
+func testAdapter(t *testing.T, c testCache) {
+	values := []interface{}{1, -2, int64(3), true, false, "text", "42", []byte("chars")}
+	for i, v := range values {
+		key := "key" + strconv.Itoa(i)
+		mustEqual(t, c.Put(key, v, 60), nil)
+		if got := c.Get(key); !reflect.DeepEqual(got, v) {
+			t.Errorf("%s: expected %#v, got %#v", key, v, got)
+		}
+	}
+
+	expectEqual(t, c.Get("missing"), nil)
+	expectEqual(t, c.IsExist("missing"), false)
+	expectEqual(t, c.IsExist("key0"), true)
+
+	expectEqual(t, c.Incr("key0"), nil)
+	expectEqual(t, c.Get("key0"), 2)
+	expectEqual(t, c.Decr("key0"), nil)
+	expectEqual(t, c.Get("key0"), 1)
+	expectEqual(t, c.Incr("key5") != nil, true)
+
+	expectEqual(t, c.Delete("key0"), nil)
+	expectEqual(t, c.Get("key0"), nil)
+
+	expectEqual(t, c.ClearAll(), nil)
+	expectEqual(t, c.Get("key1"), nil)
+}
+
+func TestCodec(t *testing.T) {
+	for _, v := range []interface{}{0, 123, -5, int64(-7), true, false, "", "-x", []byte{}, []byte{0, 1}} {
+		data, err := encode(v)
+		mustEqual(t, err, nil)
+		expectEqual(t, decode(data), v)
+	}
+
+	data, err := encode(map[string]int{"a": 1})
+	mustEqual(t, err, nil)
+	expectEqual(t, decode(data), map[string]interface{}{"a": float64(1)})
+	expectEqual(t, decode(nil), nil)
+	expectEqual(t, decode([]byte("x")), nil)
+}
+
+func TestRedisCache(t *testing.T) {
+	addr := fakeServer(t, fakeRedis)
+
+	_, err := NewRedisCache(addr, "wrong", 1, "wetalk:", time.Second)
+	expectEqual(t, err, redisError("ERR invalid password"))
+
+	c, err := NewRedisCache(addr, "secret", 1, "wetalk:", time.Second)
+	mustEqual(t, err, nil)
+	testAdapter(t, c)
+
+	// keys out of namespace are kept
+	other, err := NewRedisCache(addr, "secret", 1, "other:", time.Second)
+	mustEqual(t, err, nil)
+	other.Put("key", "value", 0)
+	c.Put("key", "value", 0)
+	expectEqual(t, c.ClearAll(), nil)
+	expectEqual(t, other.Get("key"), "value")
+}
+
+func TestMemcacheCache(t *testing.T) {
+	c, err := NewMemcacheCache([]string{fakeServer(t, fakeMemcache), fakeServer(t, fakeMemcache)}, "wetalk:", time.Second)
+	mustEqual(t, err, nil)
+	testAdapter(t, c)
+
+	// long keys and keys with spaces are hashed
+	key := strings.Repeat("k", 300) + " x"
+	expectEqual(t, c.Put(key, 1, 0), nil)
+	expectEqual(t, c.Get(key), 1)
+}
+
+func TestFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wetalk-cache")
+	mustEqual(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	c, err := NewFileCache(dir, "wetalk:", 0)
+	mustEqual(t, err, nil)
+	testAdapter(t, c)
+
+	// expired values are removed when read
+	expectEqual(t, c.Put("expired", 1, 1), nil)
+	expectEqual(t, c.write(c.path("expired"), []byte("1"), time.Now().Unix()-1), nil)
+	expectEqual(t, c.Get("expired"), nil)
+	_, err = os.Stat(c.path("expired"))
+	expectEqual(t, os.IsNotExist(err), true)
+}
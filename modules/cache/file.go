@@ -0,0 +1,188 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FileCache saves values in files of directory, which can be shared by app
+// instances on the same host or on network file system.
+type FileCache struct {
+	dir    string
+	prefix string
+	lock   sync.Mutex
+}
+
+// NewFileCache creates cache in dir, expired files are removed every gc interval.
+func NewFileCache(dir, prefix string, gcInterval time.Duration) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &FileCache{dir: dir, prefix: prefix}
+	if gcInterval > 0 {
+		go func() {
+			for {
+				time.Sleep(gcInterval)
+				c.gc()
+			}
+		}()
+	}
+	return c, nil
+}
+
+func (c *FileCache) path(key string) string {
+	hash := hashKey(c.prefix + key)
+	return filepath.Join(c.dir, hash[:2], hash)
+}
+
+// file content is "<expires unix time>\n<encoded value>", 0 is never expire
+func (c *FileCache) read(path string) (data []byte, expires int64, ok bool) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, false
+	}
+	i := bytes.IndexByte(content, '\n')
+	if i == -1 {
+		return nil, 0, false
+	}
+	expires, err = strconv.ParseInt(string(content[:i]), 10, 64)
+	if err != nil {
+		return nil, 0, false
+	}
+	if expires > 0 && expires <= time.Now().Unix() {
+		os.Remove(path)
+		return nil, 0, false
+	}
+	return content[i+1:], expires, true
+}
+
+func (c *FileCache) write(path string, data []byte, expires int64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content := append([]byte(strconv.FormatInt(expires, 10)+"\n"), data...)
+
+	// write to temp file then rename, readers never see partial content
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	tmp.Close()
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *FileCache) Get(key string) interface{} {
+	if data, _, ok := c.read(c.path(key)); ok {
+		return decode(data)
+	}
+	return nil
+}
+
+func (c *FileCache) GetMulti(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = c.Get(key)
+	}
+	return values
+}
+
+// Put saves value for timeout seconds, 0 is never expire.
+func (c *FileCache) Put(key string, val interface{}, timeout int64) error {
+	data, err := encode(val)
+	if err != nil {
+		return err
+	}
+	var expires int64
+	if timeout > 0 {
+		expires = time.Now().Unix() + timeout
+	}
+	return c.write(c.path(key), data, expires)
+}
+
+func (c *FileCache) Delete(key string) error {
+	if err := os.Remove(c.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// add n to number value, expiration of value is kept
+func (c *FileCache) add(key string, n int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	path := c.path(key)
+	data, expires, _ := c.read(path)
+	num, ok := decode(data).(int)
+	if !ok {
+		return ErrNotNumber
+	}
+	data, _ = encode(num + n)
+	return c.write(path, data, expires)
+}
+
+func (c *FileCache) Incr(key string) error {
+	return c.add(key, 1)
+}
+
+func (c *FileCache) Decr(key string) error {
+	return c.add(key, -1)
+}
+
+func (c *FileCache) IsExist(key string) bool {
+	_, _, ok := c.read(c.path(key))
+	return ok
+}
+
+// ClearAll removes all files in directory of cache.
+func (c *FileCache) ClearAll() error {
+	dirs, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(filepath.Join(c.dir, dir.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove expired files
+func (c *FileCache) gc() {
+	filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			c.read(path)
+		}
+		return nil
+	})
+}
+
+// StartAndGC does nothing, gc is started by NewFileCache.
+func (c *FileCache) StartAndGC(config string) error {
+	return nil
+}
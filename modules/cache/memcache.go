@@ -0,0 +1,265 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errCacheMiss = errors.New("memcache: cache miss")
+
+type memcacheConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// MemcacheCache saves values in memcache servers, keys are prefixed with
+// namespace and distributed to servers by hash.
+type MemcacheCache struct {
+	servers []string
+	prefix  string
+	timeout time.Duration
+
+	lock sync.Mutex
+	idle map[string][]*memcacheConn
+}
+
+func NewMemcacheCache(servers []string, prefix string, timeout time.Duration) (*MemcacheCache, error) {
+	if len(servers) == 0 {
+		return nil, errors.New("memcache: no servers")
+	}
+	return &MemcacheCache{
+		servers: servers,
+		prefix:  prefix,
+		timeout: timeout,
+		idle:    make(map[string][]*memcacheConn),
+	}, nil
+}
+
+// key of memcache can't be longer than 250 or contain spaces, hash it if so
+func (c *MemcacheCache) key(key string) string {
+	key = c.prefix + key
+	if len(key) > 200 || strings.IndexFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f }) != -1 {
+		key = c.prefix + "h:" + hashKey(key)
+	}
+	return key
+}
+
+func (c *MemcacheCache) server(key string) string {
+	return c.servers[crc32.ChecksumIEEE([]byte(key))%uint32(len(c.servers))]
+}
+
+// do runs fn on connection to server, connections are reused if fn succeeded
+// or failed with replied errors.
+func (c *MemcacheCache) do(server string, fn func(rw *bufio.ReadWriter) error) error {
+	c.lock.Lock()
+	var mc *memcacheConn
+	if idle := c.idle[server]; len(idle) > 0 {
+		mc = idle[len(idle)-1]
+		c.idle[server] = idle[:len(idle)-1]
+	}
+	c.lock.Unlock()
+
+	if mc == nil {
+		conn, err := net.DialTimeout("tcp", server, c.timeout)
+		if err != nil {
+			return err
+		}
+		mc = &memcacheConn{conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))}
+	}
+
+	mc.conn.SetDeadline(time.Now().Add(c.timeout))
+	err := fn(mc.rw)
+	if _, ok := err.(memcacheError); err != nil && err != errCacheMiss && !ok {
+		mc.conn.Close()
+		return err
+	}
+
+	c.lock.Lock()
+	if len(c.idle[server]) < maxIdleConns {
+		c.idle[server] = append(c.idle[server], mc)
+		mc = nil
+	}
+	c.lock.Unlock()
+	if mc != nil {
+		mc.conn.Close()
+	}
+	return err
+}
+
+// error replied by memcache server
+type memcacheError string
+
+func (e memcacheError) Error() string {
+	return "memcache: " + string(e)
+}
+
+// command writes line of command and optional data, then reads first line of reply.
+func command(rw *bufio.ReadWriter, line string, data []byte) (string, error) {
+	rw.WriteString(line + "\r\n")
+	if data != nil {
+		rw.Write(data)
+		rw.WriteString("\r\n")
+	}
+	if err := rw.Flush(); err != nil {
+		return "", err
+	}
+	reply, err := rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimRight(reply, "\r\n")
+	if reply == "ERROR" || strings.HasPrefix(reply, "CLIENT_ERROR") || strings.HasPrefix(reply, "SERVER_ERROR") {
+		return "", memcacheError(reply)
+	}
+	return reply, nil
+}
+
+func (c *MemcacheCache) Get(key string) interface{} {
+	key = c.key(key)
+	var value interface{}
+	c.do(c.server(key), func(rw *bufio.ReadWriter) error {
+		reply, err := command(rw, "get "+key, nil)
+		if err != nil {
+			return err
+		}
+		if reply == "END" {
+			return errCacheMiss
+		}
+
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(reply)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return fmt.Errorf("memcache: bad reply %q", reply)
+		}
+		n, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rw, data); err != nil {
+			return err
+		}
+		if end, err := rw.ReadString('\n'); err != nil || end != "END\r\n" {
+			return fmt.Errorf("memcache: bad reply end %q", end)
+		}
+		value = decode(data[:n])
+		return nil
+	})
+	return value
+}
+
+func (c *MemcacheCache) GetMulti(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = c.Get(key)
+	}
+	return values
+}
+
+// Put saves value for timeout seconds, 0 is never expire.
+func (c *MemcacheCache) Put(key string, val interface{}, timeout int64) error {
+	data, err := encode(val)
+	if err != nil {
+		return err
+	}
+	// expiration longer than 30 days is unix time for memcache
+	if timeout > 30*24*3600 {
+		timeout += time.Now().Unix()
+	}
+
+	key = c.key(key)
+	return c.do(c.server(key), func(rw *bufio.ReadWriter) error {
+		reply, err := command(rw, fmt.Sprintf("set %s 0 %d %d", key, timeout, len(data)), data)
+		if err == nil && reply != "STORED" {
+			err = memcacheError(reply)
+		}
+		return err
+	})
+}
+
+func (c *MemcacheCache) Delete(key string) error {
+	key = c.key(key)
+	return c.do(c.server(key), func(rw *bufio.ReadWriter) error {
+		reply, err := command(rw, "delete "+key, nil)
+		if err == nil && reply != "DELETED" && reply != "NOT_FOUND" {
+			err = memcacheError(reply)
+		}
+		return err
+	})
+}
+
+func (c *MemcacheCache) incr(cmd string, key string) error {
+	key = c.key(key)
+	return c.do(c.server(key), func(rw *bufio.ReadWriter) error {
+		reply, err := command(rw, cmd+" "+key+" 1", nil)
+		if err != nil {
+			return err
+		}
+		if reply == "NOT_FOUND" {
+			return errCacheMiss
+		}
+		if _, err := strconv.ParseUint(reply, 10, 64); err != nil {
+			return ErrNotNumber
+		}
+		return nil
+	})
+}
+
+// Incr increases number value, memcache only supports non-negative numbers.
+func (c *MemcacheCache) Incr(key string) error {
+	return c.incr("incr", key)
+}
+
+// Decr decreases number value, memcache doesn't decrease it below 0.
+func (c *MemcacheCache) Decr(key string) error {
+	return c.incr("decr", key)
+}
+
+func (c *MemcacheCache) IsExist(key string) bool {
+	return c.Get(key) != nil
+}
+
+// ClearAll flushes all servers, memcache can't list keys of namespace,
+// so don't share the servers with other apps if it is used.
+func (c *MemcacheCache) ClearAll() error {
+	for _, server := range c.servers {
+		err := c.do(server, func(rw *bufio.ReadWriter) error {
+			reply, err := command(rw, "flush_all", nil)
+			if err == nil && reply != "OK" {
+				err = memcacheError(reply)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartAndGC does nothing, memcache server removes expired keys.
+func (c *MemcacheCache) StartAndGC(config string) error {
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// error replied by redis server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// RedisCache saves values in redis server, keys are prefixed with namespace.
+type RedisCache struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+
+	lock sync.Mutex
+	idle []*redisConn
+}
+
+func NewRedisCache(addr, password string, db int, prefix string, timeout time.Duration) (*RedisCache, error) {
+	c := &RedisCache{
+		addr:     addr,
+		password: password,
+		db:       db,
+		prefix:   prefix,
+		timeout:  timeout,
+	}
+	if _, err := c.do("PING"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *RedisCache) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))}
+	if c.password != "" {
+		if _, err := rc.do(c.timeout, "AUTH", c.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := rc.do(c.timeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// do runs command on an idle or new connection, connections with network
+// errors are closed instead of reused.
+func (c *RedisCache) do(args ...string) (interface{}, error) {
+	c.lock.Lock()
+	var rc *redisConn
+	if n := len(c.idle); n > 0 {
+		rc = c.idle[n-1]
+		c.idle = c.idle[:n-1]
+	}
+	c.lock.Unlock()
+
+	if rc == nil {
+		var err error
+		if rc, err = c.dial(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := rc.do(c.timeout, args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		rc.conn.Close()
+		return nil, err
+	}
+
+	c.lock.Lock()
+	if len(c.idle) < maxIdleConns {
+		c.idle = append(c.idle, rc)
+		rc = nil
+	}
+	c.lock.Unlock()
+	if rc != nil {
+		rc.conn.Close()
+	}
+	return reply, err
+}
+
+func (rc *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(timeout))
+
+	fmt.Fprintf(rc.rw, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(rc.rw, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := rc.rw.Flush(); err != nil {
+		return nil, err
+	}
+	return readRedisReply(rc.rw.Reader)
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", errors.New("redis: bad line ending")
+	}
+	return line[:len(line)-2], nil
+}
+
+// read one reply, bulk strings are []byte, integers are int64 and
+// nil bulk strings or arrays are nil
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply %q", line)
+}
+
+func (c *RedisCache) Get(key string) interface{} {
+	reply, err := c.do("GET", c.prefix+key)
+	if data, ok := reply.([]byte); ok && err == nil {
+		return decode(data)
+	}
+	return nil
+}
+
+func (c *RedisCache) GetMulti(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = c.Get(key)
+	}
+	return values
+}
+
+// Put saves value for timeout seconds, 0 is never expire.
+func (c *RedisCache) Put(key string, val interface{}, timeout int64) error {
+	data, err := encode(val)
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		_, err = c.do("SET", c.prefix+key, string(data), "EX", strconv.FormatInt(timeout, 10))
+	} else {
+		_, err = c.do("SET", c.prefix+key, string(data))
+	}
+	return err
+}
+
+func (c *RedisCache) Delete(key string) error {
+	_, err := c.do("DEL", c.prefix+key)
+	return err
+}
+
+func (c *RedisCache) Incr(key string) error {
+	_, err := c.do("INCR", c.prefix+key)
+	return err
+}
+
+func (c *RedisCache) Decr(key string) error {
+	_, err := c.do("DECR", c.prefix+key)
+	return err
+}
+
+func (c *RedisCache) IsExist(key string) bool {
+	reply, err := c.do("EXISTS", c.prefix+key)
+	n, _ := reply.(int64)
+	return err == nil && n > 0
+}
+
+// ClearAll deletes keys in namespace of cache.
+func (c *RedisCache) ClearAll() error {
+	pattern := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(c.prefix) + "*"
+	cursor := "0"
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", pattern, "COUNT", "1000")
+		if err != nil {
+			return err
+		}
+		items, _ := reply.([]interface{})
+		if len(items) != 2 {
+			return errors.New("redis: bad scan reply")
+		}
+		next, _ := items[0].([]byte)
+		keys, _ := items[1].([]interface{})
+
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, key := range keys {
+				if k, ok := key.([]byte); ok {
+					args = append(args, string(k))
+				}
+			}
+			if _, err := c.do(args...); err != nil {
+				return err
+			}
+		}
+
+		if cursor = string(next); cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// StartAndGC does nothing, redis server removes expired keys.
+func (c *RedisCache) StartAndGC(config string) error {
+	return nil
+}
@@ -18,6 +18,8 @@ import (
 	"fmt"
 	"net/smtp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/astaxie/beego"
 
@@ -35,6 +37,44 @@ type Message struct {
 	Info    string
 }
 
+// counters of mails since app start
+type MailStats struct {
+	Pending    int
+	Sent       int
+	Failed     int
+	LastError  string
+	LastFailed time.Time
+}
+
+var (
+	stats     MailStats
+	statsLock sync.Mutex
+)
+
+// Stats returns a copy of mail counters
+func Stats() MailStats {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	return stats
+}
+
+func countSend(num int, err error) {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	stats.Sent += num
+	if err != nil {
+		stats.Failed++
+		stats.LastError = err.Error()
+		stats.LastFailed = time.Now()
+	}
+}
+
+func countPending(n int) {
+	statsLock.Lock()
+	stats.Pending += n
+	statsLock.Unlock()
+}
+
 // create mail content
 func (m Message) Content() string {
 	// set mail type
@@ -51,6 +91,12 @@ func (m Message) Content() string {
 
 // Direct Send mail message
 func Send(msg Message) (int, error) {
+	num, err := send(msg)
+	countSend(num, err)
+	return num, err
+}
+
+func send(msg Message) (int, error) {
 	host := strings.Split(setting.MailHost, ":")
 
 	// get message body
@@ -94,7 +140,9 @@ func Send(msg Message) (int, error) {
 // Async Send mail message
 func SendAsync(msg Message) {
 	// TODO may be need pools limit concurrent nums
+	countPending(1)
 	go func() {
+		defer countPending(-1)
 		if num, err := Send(msg); err != nil {
 			tos := strings.Join(msg.To, "; ")
 			info := ""
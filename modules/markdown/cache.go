@@ -0,0 +1,154 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package markdown
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/varding/wetalk/setting"
+)
+
+// bump it when output of renderer changed, html rendered before are rendered again
+const rendererVersion = "1"
+
+// Version returns version of renderer in context of profile, it changes when
+// renderer, features of profile or registered extensions changed.
+func Version(profile string) string {
+	extensionsLock.RLock()
+	names := make([]string, 0, len(extensions))
+	for _, name := range profileFeatures(profile) {
+		if _, ok := extensions[name]; ok {
+			names = append(names, "+"+name)
+		} else {
+			names = append(names, name)
+		}
+	}
+	extensionsLock.RUnlock()
+
+	sum := sha1.Sum([]byte(rendererVersion + "|" + setting.AppUrl + "|" + strings.Join(names, ",")))
+	return hex.EncodeToString(sum[:4])
+}
+
+type cacheEntry struct {
+	key     string
+	html    string
+	expires time.Time
+}
+
+// lru of rendered html
+type lruCache struct {
+	lock  sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+func newLRUCache() *lruCache {
+	return &lruCache{
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (c *lruCache) Get(key string) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.html, true
+}
+
+func (c *lruCache) Put(key, html string, ttl time.Duration, size int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.html = html
+		entry.expires = time.Now().Add(ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheEntry{key, html, time.Now().Add(ttl)})
+	for c.order.Len() > size {
+		elem := c.order.Back()
+		c.order.Remove(elem)
+		delete(c.items, elem.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *lruCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+func (c *lruCache) Clear() {
+	c.lock.Lock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.lock.Unlock()
+}
+
+var renderCache = newLRUCache()
+
+// ClearCache removes all rendered html in process.
+func ClearCache() {
+	renderCache.Clear()
+}
+
+// RenderCached renders content like Render, html are cached by profile, renderer
+// version and hash of content, in process and in setting.Cache if shared is enabled.
+func RenderCached(profile string, content string) string {
+	if setting.MarkdownCacheSize <= 0 {
+		return Render(profile, content)
+	}
+
+	sum := sha1.Sum([]byte(content))
+	key := "md:" + profile + ":" + Version(profile) + ":" + hex.EncodeToString(sum[:])
+	ttl := time.Duration(setting.MarkdownCacheTTL) * time.Second
+
+	if html, ok := renderCache.Get(key); ok {
+		return html
+	}
+	if setting.MarkdownCacheShared {
+		if html, ok := setting.Cache.Get(key).(string); ok {
+			renderCache.Put(key, html, ttl, setting.MarkdownCacheSize)
+			return html
+		}
+	}
+
+	html := Render(profile, content)
+	renderCache.Put(key, html, ttl, setting.MarkdownCacheSize)
+	if setting.MarkdownCacheShared {
+		setting.Cache.Put(key, html, int64(setting.MarkdownCacheTTL))
+	}
+	return html
+}
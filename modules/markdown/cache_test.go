@@ -0,0 +1,87 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package markdown
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache()
+	c.Put("a", "A", time.Minute, 2)
+	c.Put("b", "B", time.Minute, 2)
+
+	// a is recently used, b is evicted
+	html, ok := c.Get("a")
+	ThrowFail(t, AssertIs(ok, true))
+	ThrowFail(t, AssertIs(html, "A"))
+	c.Put("c", "C", time.Minute, 2)
+	_, ok = c.Get("b")
+	ThrowFail(t, AssertIs(ok, false))
+	ThrowFail(t, AssertIs(c.Len(), 2))
+
+	c.Put("d", "D", -time.Second, 2)
+	_, ok = c.Get("d")
+	ThrowFail(t, AssertIs(ok, false))
+
+	c.Clear()
+	ThrowFail(t, AssertIs(c.Len(), 0))
+}
+
+func TestRenderCached(t *testing.T) {
+	setting.MarkdownProfiles = map[string][]string{ProfilePost: {"counter"}}
+	setting.MarkdownCacheSize = 10
+	setting.MarkdownCacheTTL = 60
+	setting.MarkdownCacheShared = false
+	ClearCache()
+
+	version := Version(ProfilePost)
+
+	renders := 0
+	RegisterExtension("counter", func(html string) string {
+		renders++
+		return html
+	})
+	defer func() {
+		extensionsLock.Lock()
+		delete(extensions, "counter")
+		extensionsLock.Unlock()
+	}()
+
+	// registered extensions change version
+	ThrowFail(t, AssertIs(Version(ProfilePost) != version, true))
+	version = Version(ProfilePost)
+
+	html := RenderCached(ProfilePost, "hello")
+	ThrowFail(t, AssertIs(RenderCached(ProfilePost, "hello"), html))
+	ThrowFail(t, AssertIs(renders, 1))
+
+	RenderCached(ProfilePost, "world")
+	ThrowFail(t, AssertIs(renders, 2))
+
+	// changed features render again
+	setting.MarkdownProfiles[ProfilePost] = []string{"counter", FeatureEmoji}
+	ThrowFail(t, AssertIs(Version(ProfilePost) != version, true))
+	RenderCached(ProfilePost, "hello")
+	ThrowFail(t, AssertIs(renders, 3))
+
+	setting.MarkdownCacheSize = 0
+	RenderCached(ProfilePost, "hello")
+	ThrowFail(t, AssertIs(renders, 4))
+}
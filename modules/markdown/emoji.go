@@ -0,0 +1,130 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package markdown
+
+import (
+	"regexp"
+)
+
+var emojiRegexp = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+
+// shortcodes of common emoji
+var emojis = map[string]string{
+	"smile":                    "\U0001F604",
+	"smiley":                   "\U0001F603",
+	"grin":                     "\U0001F601",
+	"laughing":                 "\U0001F606",
+	"joy":                      "\U0001F602",
+	"blush":                    "\U0001F60A",
+	"wink":                     "\U0001F609",
+	"heart_eyes":               "\U0001F60D",
+	"kissing_heart":            "\U0001F618",
+	"stuck_out_tongue":         "\U0001F61B",
+	"sunglasses":               "\U0001F60E",
+	"smirk":                    "\U0001F60F",
+	"neutral_face":             "\U0001F610",
+	"expressionless":           "\U0001F611",
+	"unamused":                 "\U0001F612",
+	"sweat_smile":              "\U0001F605",
+	"sweat":                    "\U0001F613",
+	"pensive":                  "\U0001F614",
+	"confused":                 "\U0001F615",
+	"thinking":                 "\U0001F914",
+	"cry":                      "\U0001F622",
+	"sob":                      "\U0001F62D",
+	"angry":                    "\U0001F620",
+	"rage":                     "\U0001F621",
+	"scream":                   "\U0001F631",
+	"astonished":               "\U0001F632",
+	"flushed":                  "\U0001F633",
+	"sleeping":                 "\U0001F634",
+	"mask":                     "\U0001F637",
+	"innocent":                 "\U0001F607",
+	"upside_down_face":         "\U0001F643",
+	"slightly_smiling_face":    "\U0001F642",
+	"+1":                       "\U0001F44D",
+	"thumbsup":                 "\U0001F44D",
+	"-1":                       "\U0001F44E",
+	"thumbsdown":               "\U0001F44E",
+	"ok_hand":                  "\U0001F44C",
+	"clap":                     "\U0001F44F",
+	"wave":                     "\U0001F44B",
+	"pray":                     "\U0001F64F",
+	"muscle":                   "\U0001F4AA",
+	"point_up":                 "☝️",
+	"point_right":              "\U0001F449",
+	"raised_hands":             "\U0001F64C",
+	"v":                        "✌️",
+	"eyes":                     "\U0001F440",
+	"heart":                    "❤️",
+	"broken_heart":             "\U0001F494",
+	"star":                     "⭐",
+	"sparkles":                 "✨",
+	"fire":                     "\U0001F525",
+	"boom":                     "\U0001F4A5",
+	"zap":                      "⚡",
+	"100":                      "\U0001F4AF",
+	"tada":                     "\U0001F389",
+	"rocket":                   "\U0001F680",
+	"bug":                      "\U0001F41B",
+	"beer":                     "\U0001F37A",
+	"coffee":                   "☕",
+	"pizza":                    "\U0001F355",
+	"cake":                     "\U0001F370",
+	"gift":                     "\U0001F381",
+	"trophy":                   "\U0001F3C6",
+	"warning":                  "⚠️",
+	"x":                        "❌",
+	"white_check_mark":         "✅",
+	"heavy_check_mark":         "✔️",
+	"question":                 "❓",
+	"exclamation":              "❗",
+	"bulb":                     "\U0001F4A1",
+	"memo":                     "\U0001F4DD",
+	"book":                     "\U0001F4D6",
+	"link":                     "\U0001F517",
+	"lock":                     "\U0001F512",
+	"key":                      "\U0001F511",
+	"hammer":                   "\U0001F528",
+	"wrench":                   "\U0001F527",
+	"gear":                     "⚙️",
+	"computer":                 "\U0001F4BB",
+	"email":                    "\U0001F4E7",
+	"mag":                      "\U0001F50D",
+	"hourglass":                "⌛",
+	"calendar":                 "\U0001F4C6",
+	"chart_with_upwards_trend": "\U0001F4C8",
+	"sun_with_face":            "\U0001F31E",
+	"cloud":                    "☁️",
+	"snowflake":                "❄️",
+	"rainbow":                  "\U0001F308",
+	"dog":                      "\U0001F436",
+	"cat":                      "\U0001F431",
+	"panda_face":               "\U0001F43C",
+	"see_no_evil":              "\U0001F648",
+	"poop":                     "\U0001F4A9",
+	"ghost":                    "\U0001F47B",
+	"robot":                    "\U0001F916",
+}
+
+// replaceEmoji replaces shortcodes like :smile: in text with emoji.
+func replaceEmoji(text string) string {
+	return emojiRegexp.ReplaceAllStringFunc(text, func(code string) string {
+		if emoji, ok := emojis[code[1:len(code)-1]]; ok {
+			return emoji
+		}
+		return code
+	})
+}
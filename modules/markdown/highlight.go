@@ -0,0 +1,311 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// lexical rules of a language for highlighting
+type language struct {
+	keywords      map[string]bool
+	types         map[string]bool
+	lineComments  []string
+	blockComments [2]string
+	quotes        string
+	// quotes can't be escaped with backslash, like raw strings of go
+	rawQuotes string
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cKeywords = "auto break case const continue default do else enum extern for goto if inline register " +
+		"restrict return sizeof static struct switch typedef union volatile while NULL true false"
+	cTypes = "char double float int long short signed unsigned void bool size_t int8_t int16_t int32_t " +
+		"int64_t uint8_t uint16_t uint32_t uint64_t"
+	jsKeywords = "break case catch class const continue debugger default delete do else export extends " +
+		"finally for function if import in instanceof let new return super switch this throw try typeof " +
+		"var void while with yield async await of static get set null undefined true false NaN"
+)
+
+var languages = map[string]*language{
+	"go": {
+		keywords: words("break case chan const continue default defer else fallthrough for func go goto if " +
+			"import interface map package range return select struct switch type var true false nil iota"),
+		types: words("bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune " +
+			"string uint uint8 uint16 uint32 uint64 uintptr append cap close complex copy delete imag len " +
+			"make new panic print println real recover"),
+		lineComments:  []string{"//"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'",
+		rawQuotes:     "`",
+	},
+	"c": {
+		keywords:      words(cKeywords + " #include #define #ifdef #ifndef #endif #if #else #pragma"),
+		types:         words(cTypes),
+		lineComments:  []string{"//"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'",
+	},
+	"cpp": {
+		keywords: words(cKeywords + " #include #define #ifdef #ifndef #endif #if #else #pragma catch class " +
+			"constexpr delete explicit friend mutable namespace new noexcept nullptr operator private " +
+			"protected public template this throw try typename using virtual override final"),
+		types:         words(cTypes + " auto string vector map std wchar_t"),
+		lineComments:  []string{"//"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'",
+	},
+	"java": {
+		keywords: words("abstract assert break case catch class const continue default do else enum extends " +
+			"final finally for goto if implements import instanceof interface native new package private " +
+			"protected public return static strictfp super switch synchronized this throw throws transient " +
+			"try volatile while true false null var"),
+		types:         words("boolean byte char double float int long short void String Object Integer List Map"),
+		lineComments:  []string{"//"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'",
+	},
+	"javascript": {
+		keywords:      words(jsKeywords),
+		types:         words("Array Boolean Date Error Function JSON Math Number Object Promise RegExp String console window document"),
+		lineComments:  []string{"//"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'`",
+	},
+	"typescript": {
+		keywords:      words(jsKeywords + " interface type enum implements declare namespace module public private protected readonly abstract as"),
+		types:         words("any boolean number string void never unknown object Array Promise Record"),
+		lineComments:  []string{"//"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'`",
+	},
+	"python": {
+		keywords: words("and as assert async await break class continue def del elif else except finally for " +
+			"from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self"),
+		types:        words("bool bytes dict float int list object set str tuple len print range open isinstance super"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"ruby": {
+		keywords: words("alias and begin break case class def defined? do else elsif end ensure false for if in " +
+			"module next nil not or redo rescue retry return self super then true undef unless until when while yield require"),
+		types:        words("Array Hash String Integer Float Symbol puts print attr_accessor attr_reader"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"php": {
+		keywords: words("abstract and array as break case catch class const continue declare default do echo else " +
+			"elseif empty extends final finally for foreach function global if implements include interface isset " +
+			"namespace new or private protected public require return static switch throw trait try use var while null true false"),
+		types:         words("int float string bool void mixed self"),
+		lineComments:  []string{"//", "#"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'",
+	},
+	"rust": {
+		keywords: words("as break const continue crate else enum extern false fn for if impl in let loop match mod " +
+			"move mut pub ref return self Self static struct super trait true type unsafe use where while async await dyn"),
+		types:         words("bool char f32 f64 i8 i16 i32 i64 i128 isize str u8 u16 u32 u64 u128 usize String Vec Option Result Box Some None Ok Err"),
+		lineComments:  []string{"//"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"",
+	},
+	"shell": {
+		keywords: words("if then else elif fi case esac for while until do done in function return exit export " +
+			"local readonly set unset source"),
+		types:        words("echo cd ls cat grep sed awk mkdir rm cp mv git go make sudo curl"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"sql": {
+		keywords: words("SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER " +
+			"INDEX PRIMARY KEY FOREIGN REFERENCES JOIN LEFT RIGHT INNER OUTER ON AS GROUP BY ORDER HAVING LIMIT " +
+			"OFFSET DISTINCT UNION NULL IS IN LIKE BETWEEN DEFAULT " +
+			"select from where and or not insert into values update set delete create table drop alter " +
+			"index primary key foreign references join left right inner outer on as group by order having limit " +
+			"offset distinct union null is in like between default"),
+		types: words("INT INTEGER BIGINT VARCHAR CHAR TEXT DATE DATETIME TIMESTAMP BOOLEAN FLOAT DOUBLE DECIMAL " +
+			"COUNT SUM AVG MIN MAX int integer bigint varchar char text date datetime timestamp boolean float double decimal"),
+		lineComments:  []string{"--"},
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "'\"",
+	},
+	"json": {
+		keywords: words("true false null"),
+		quotes:   "\"",
+	},
+	"yaml": {
+		keywords:     words("true false null yes no on off"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"css": {
+		keywords:      words("important inherit initial none auto"),
+		blockComments: [2]string{"/*", "*/"},
+		quotes:        "\"'",
+	},
+}
+
+// other names of languages in code blocks
+var languageAliases = map[string]string{
+	"golang":  "go",
+	"h":       "c",
+	"c++":     "cpp",
+	"cc":      "cpp",
+	"js":      "javascript",
+	"ts":      "typescript",
+	"py":      "python",
+	"rb":      "ruby",
+	"rs":      "rust",
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"console": "shell",
+	"yml":     "yaml",
+}
+
+func lookupLanguage(name string) *language {
+	name = strings.ToLower(name)
+	if alias, ok := languageAliases[name]; ok {
+		name = alias
+	}
+	return languages[name]
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// highlight wraps tokens of code in spans with classes of prettify styles,
+// code is plain text and result is escaped html.
+func highlight(lang *language, code string) string {
+	var buf bytes.Buffer
+	span := func(class, text string) {
+		buf.WriteString(`<span class="`)
+		buf.WriteString(class)
+		buf.WriteString(`">`)
+		buf.WriteString(html.EscapeString(text))
+		buf.WriteString(`</span>`)
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		// comments
+		if open := lang.blockComments[0]; open != "" && strings.HasPrefix(rest, open) {
+			end := strings.Index(rest[len(open):], lang.blockComments[1])
+			if end == -1 {
+				end = len(rest)
+			} else {
+				end += len(open) + len(lang.blockComments[1])
+			}
+			span("com", rest[:end])
+			i += end
+			continue
+		}
+		comment := false
+		for _, prefix := range lang.lineComments {
+			if strings.HasPrefix(rest, prefix) {
+				comment = true
+				break
+			}
+		}
+		if comment {
+			end := strings.IndexByte(rest, '\n')
+			if end == -1 {
+				end = len(rest)
+			}
+			span("com", rest[:end])
+			i += end
+			continue
+		}
+
+		c := code[i]
+
+		// strings
+		if strings.IndexByte(lang.quotes, c) != -1 || strings.IndexByte(lang.rawQuotes, c) != -1 {
+			raw := strings.IndexByte(lang.rawQuotes, c) != -1
+			end := 1
+			for end < len(rest) {
+				if rest[end] == '\\' && !raw {
+					end += 2
+					continue
+				}
+				if rest[end] == c {
+					end++
+					break
+				}
+				// quoted strings end at line end
+				if rest[end] == '\n' && !raw && c != '`' {
+					break
+				}
+				end++
+			}
+			if end > len(rest) {
+				end = len(rest)
+			}
+			span("str", rest[:end])
+			i += end
+			continue
+		}
+
+		// numbers
+		if c >= '0' && c <= '9' {
+			end := 1
+			for end < len(rest) && (isIdentByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span("lit", rest[:end])
+			i += end
+			continue
+		}
+
+		// identifiers, keywords of c preprocessor start with #
+		if isIdentByte(c) || c == '#' && lang.keywords["#include"] {
+			end := 1
+			for end < len(rest) && isIdentByte(rest[end]) {
+				end++
+			}
+			if end < len(rest) && rest[end] == '?' && lang.keywords[rest[:end+1]] {
+				end++
+			}
+			word := rest[:end]
+			switch {
+			case lang.keywords[word]:
+				span("kwd", word)
+			case lang.types[word]:
+				span("typ", word)
+			default:
+				buf.WriteString(html.EscapeString(word))
+			}
+			i += end
+			continue
+		}
+
+		buf.WriteString(html.EscapeString(code[i : i+1]))
+		i++
+	}
+	return buf.String()
+}
@@ -0,0 +1,110 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package markdown renders markdown of posts, comments, pages and notifications
+// into sanitized html, features are configured per context in profiles.
+package markdown
+
+import (
+	"sync"
+
+	"github.com/slene/blackfriday"
+
+	"github.com/varding/wetalk/setting"
+)
+
+// profiles of rendering contexts, features of them are set in [markdown] of config
+const (
+	ProfilePost         = "post"
+	ProfileComment      = "comment"
+	ProfilePage         = "page"
+	ProfileNotification = "notification"
+)
+
+// built-in features of profiles
+const (
+	FeatureHTML      = "html"
+	FeatureHighlight = "highlight"
+	FeatureEmoji     = "emoji"
+	FeatureTaskList  = "tasklist"
+	FeatureAnchor    = "anchor"
+	FeatureFootnote  = "footnote"
+)
+
+// Extension transforms sanitized html, it is enabled by adding its name to features of profiles.
+type Extension func(html string) string
+
+var (
+	extensions     = make(map[string]Extension)
+	extensionsLock sync.RWMutex
+)
+
+// RegisterExtension adds extension of name, extensions run in order of features in profile.
+func RegisterExtension(name string, ext Extension) {
+	extensionsLock.Lock()
+	extensions[name] = ext
+	extensionsLock.Unlock()
+}
+
+func profileFeatures(profile string) []string {
+	if features, ok := setting.MarkdownProfiles[profile]; ok {
+		return features
+	}
+	return setting.MarkdownProfiles[ProfilePost]
+}
+
+// Render renders markdown content in context of profile.
+func Render(profile string, content string) string {
+	names := profileFeatures(profile)
+	features := make(map[string]bool, len(names))
+	for _, name := range names {
+		features[name] = true
+	}
+
+	htmlFlags := 0
+	htmlFlags |= blackfriday.HTML_USE_XHTML
+	htmlFlags |= blackfriday.HTML_SKIP_STYLE
+	htmlFlags |= blackfriday.HTML_SKIP_SCRIPT
+	htmlFlags |= blackfriday.HTML_GITHUB_BLOCKCODE
+	if !features[FeatureHTML] {
+		htmlFlags |= blackfriday.HTML_SKIP_HTML
+	}
+	renderer := blackfriday.HtmlRenderer(htmlFlags, "", "")
+
+	// set up the parser
+	exts := 0
+	exts |= blackfriday.EXTENSION_NO_INTRA_EMPHASIS
+	exts |= blackfriday.EXTENSION_TABLES
+	exts |= blackfriday.EXTENSION_FENCED_CODE
+	exts |= blackfriday.EXTENSION_AUTOLINK
+	exts |= blackfriday.EXTENSION_STRIKETHROUGH
+	exts |= blackfriday.EXTENSION_HARD_LINE_BREAK
+	exts |= blackfriday.EXTENSION_SPACE_HEADERS
+	exts |= blackfriday.EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK
+	if features[FeatureFootnote] {
+		exts |= blackfriday.EXTENSION_FOOTNOTES
+	}
+
+	body := blackfriday.Markdown([]byte(content), renderer, exts)
+	html := Sanitize(string(body), features)
+
+	extensionsLock.RLock()
+	defer extensionsLock.RUnlock()
+	for _, name := range names {
+		if ext, ok := extensions[name]; ok {
+			html = ext(html)
+		}
+	}
+	return html
+}
@@ -0,0 +1,91 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package markdown
+
+import (
+	"testing"
+
+	. "github.com/varding/wetalk/modules/utils"
+)
+
+var allFeatures = map[string]bool{
+	FeatureHTML: true, FeatureHighlight: true, FeatureEmoji: true,
+	FeatureTaskList: true, FeatureAnchor: true, FeatureFootnote: true,
+}
+
+func TestSanitize(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{`<p>hello <b>world</b></p>`, `<p>hello <b>world</b></p>`},
+		{`<script>alert(1)</script><p>ok</p>`, `<p>ok</p>`},
+		{`<p onclick="alert(1)" title="t">x</p>`, `<p title="t">x</p>`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href=" java&#x09;script:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="/topic/go">x</a>`, `<a href="/topic/go">x</a>`},
+		{`<a href="https://golang.org">x</a>`, `<a href="https://golang.org" rel="nofollow">x</a>`},
+		{`<img src="/img/a.full.png" onerror="x" alt="a"/>`, `<img src="/img/a.full.png" alt="a" />`},
+		{`<img src="data:image/png;base64,xx">`, `<img />`},
+		{`<div><p>unclosed`, `<div><p>unclosed</p></div>`},
+		{`</div>stray<em>a</strong>b</em>`, `stray<em>ab</em>`},
+		{`<font color="red">red</font>`, `red`},
+		{`a <!-- comment --> b`, `a  b`},
+		{`1 < 2 && 3 > 2 &amp; &copy;`, `1 &lt; 2 &amp;&amp; 3 &gt; 2 &amp; &copy;`},
+		{`<iframe src="x">inner</iframe>after`, `after`},
+		{`<p title="a&quot;b">x</p>`, `<p title="a&#34;b">x</p>`},
+	}
+	for _, c := range cases {
+		ThrowFail(t, AssertIs(Sanitize(c.in, nil), c.out))
+	}
+}
+
+func TestSanitizeFeatures(t *testing.T) {
+	ThrowFail(t, AssertIs(Sanitize(`<h2>Get Started</h2><h2>Get Started</h2>`, allFeatures),
+		`<h2 id="h-get-started"><a class="anchor" href="#h-get-started">#</a>Get Started</h2>`+
+			`<h2 id="h-get-started-1"><a class="anchor" href="#h-get-started-1">#</a>Get Started</h2>`))
+
+	ThrowFail(t, AssertIs(Sanitize("<ul>\n<li>[ ] todo</li>\n<li>[x] done</li>\n<li>[y] no</li>\n</ul>", allFeatures),
+		"<ul>\n<li class=\"task-list-item\"><input type=\"checkbox\" disabled /> todo</li>\n"+
+			"<li class=\"task-list-item\"><input type=\"checkbox\" checked disabled /> done</li>\n<li>[y] no</li>\n</ul>"))
+
+	// task with formatted text
+	ThrowFail(t, AssertIs(Sanitize("<ul>\n<li>[ ] <strong>todo</strong></li>\n</ul>", allFeatures),
+		"<ul>\n<li class=\"task-list-item\"><input type=\"checkbox\" disabled /> <strong>todo</strong></li>\n</ul>"))
+
+	ThrowFail(t, AssertIs(Sanitize(`<p>nice :+1: :unknown:</p><code>:+1:</code>`, allFeatures),
+		"<p>nice \U0001F44D :unknown:</p><code>:+1:</code>"))
+
+	ThrowFail(t, AssertIs(Sanitize(`<pre lang="go"><code>x := &quot;a&lt;b&quot; // c</code></pre>`, allFeatures),
+		`<pre class="highlight"><code class="language-go">x := <span class="str">&#34;a&lt;b&#34;</span> <span class="com">// c</span></code></pre>`))
+
+	ThrowFail(t, AssertIs(Sanitize(`<pre><code class="language-brainfuck">+&lt;</code></pre>`, allFeatures),
+		`<pre><code class="language-brainfuck">+&lt;</code></pre>`))
+
+	ThrowFail(t, AssertIs(Sanitize(`<pre lang="go"><code>x</code></pre>`, nil),
+		`<pre><code class="language-go">x</code></pre>`))
+
+	ThrowFail(t, AssertIs(Sanitize(`<sup class="footnote-ref" id="fnref:1"><a rel="footnote" href="#fn:1">1</a></sup>`, allFeatures),
+		`<sup class="footnote-ref" id="fnref:1"><a rel="footnote" href="#fn:1">1</a></sup>`))
+}
+
+func TestHighlight(t *testing.T) {
+	ThrowFail(t, AssertIs(highlight(lookupLanguage("golang"), "func main() { return 42 }"),
+		`<span class="kwd">func</span> main() { <span class="kwd">return</span> <span class="lit">42</span> }`))
+	ThrowFail(t, AssertIs(highlight(lookupLanguage("py"), "# c\nprint('x')"),
+		`<span class="com"># c</span>`+"\n"+`<span class="typ">print</span>(<span class="str">&#39;x&#39;</span>)`))
+	ThrowFail(t, AssertIs(highlight(lookupLanguage("js"), "/* a */`b`"),
+		"<span class=\"com\">/* a */</span><span class=\"str\">`b`</span>"))
+	ThrowFail(t, AssertIs(lookupLanguage("cobol") == nil, true))
+}
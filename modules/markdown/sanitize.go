@@ -0,0 +1,474 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/varding/wetalk/setting"
+)
+
+// allowed tags of rendered html, value is true if the tag is void
+var allowedTags = map[string]bool{
+	"a": false, "abbr": false, "b": false, "blockquote": false, "br": true, "caption": false,
+	"code": false, "dd": false, "del": false, "details": false, "div": false, "dl": false,
+	"dt": false, "em": false, "h1": false, "h2": false, "h3": false, "h4": false, "h5": false,
+	"h6": false, "hr": true, "i": false, "img": true, "ins": false, "kbd": false, "li": false,
+	"mark": false, "ol": false, "p": false, "pre": false, "s": false, "samp": false, "small": false,
+	"span": false, "strike": false, "strong": false, "sub": false, "summary": false, "sup": false,
+	"table": false, "tbody": false, "td": false, "tfoot": false, "th": false, "thead": false,
+	"tr": false, "u": false, "ul": false,
+}
+
+// tags removed with their content
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "textarea": true,
+	"title": true, "noscript": true, "template": true, "svg": true, "math": true, "select": true,
+}
+
+func anyValue(string) bool { return true }
+
+func isNumber(v string) bool {
+	_, err := strconv.Atoi(v)
+	return err == nil
+}
+
+func oneOf(values ...string) func(string) bool {
+	return func(v string) bool {
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+var footnoteIdRegexp = regexp.MustCompile(`^fn(ref)?:[\w-]+$`)
+
+// isSafeURL allows relative urls and urls of http, https and mailto schemes,
+// control and space characters ignored by browsers are ignored.
+func isSafeURL(v string) bool {
+	v = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, v))
+	i := strings.IndexAny(v, ":/?#")
+	if i == -1 || v[i] != ':' {
+		return true
+	}
+	switch v[:i] {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// allowed attributes `<tag>.<attr>` or `*.<attr>` with validators of their values,
+// lang of pre and class of code are handled by sanitizer.
+var allowedAttrs = map[string]func(string) bool{
+	"*.title":    anyValue,
+	"a.href":     isSafeURL,
+	"a.id":       footnoteIdRegexp.MatchString,
+	"a.rel":      oneOf("footnote"),
+	"a.class":    oneOf("footnote-return"),
+	"sup.id":     footnoteIdRegexp.MatchString,
+	"sup.class":  oneOf("footnote-ref"),
+	"li.id":      footnoteIdRegexp.MatchString,
+	"div.class":  oneOf("footnotes"),
+	"img.src":    isSafeURL,
+	"img.alt":    anyValue,
+	"img.width":  isNumber,
+	"img.height": isNumber,
+	"ol.start":   isNumber,
+	"th.align":   oneOf("left", "center", "right"),
+	"td.align":   oneOf("left", "center", "right"),
+	"th.colspan": isNumber,
+	"th.rowspan": isNumber,
+	"td.colspan": isNumber,
+	"td.rowspan": isNumber,
+}
+
+var (
+	tagRegexp = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^\s"'<>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'<>=` +
+		"`" + `]+))?)*)\s*/?>`)
+	attrRegexp = regexp.MustCompile(`([^\s"'<>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'<>=` + "`" + `]+)))?`)
+
+	// comments, doctypes and processing instructions are removed
+	commentRegexp = regexp.MustCompile(`^(?:<!--[\s\S]*?-->|<![^>]*>|<\?[^>]*>)`)
+
+	entityRegexp   = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	codeLangRegexp = regexp.MustCompile(`^[\w+#.-]+$`)
+)
+
+type attribute struct {
+	name, value string
+}
+
+func parseAttrs(s string) []attribute {
+	var attrs []attribute
+	for _, m := range attrRegexp.FindAllStringSubmatch(s, -1) {
+		attrs = append(attrs, attribute{strings.ToLower(m[1]), html.UnescapeString(m[2] + m[3] + m[4])})
+	}
+	return attrs
+}
+
+// escapeText escapes text of html, entities are kept.
+func escapeText(s string) string {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '<':
+			out = append(out, "&lt;"...)
+		case '>':
+			out = append(out, "&gt;"...)
+		case '&':
+			if entityRegexp.MatchString(s[i:]) {
+				out = append(out, c)
+			} else {
+				out = append(out, "&amp;"...)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
+
+// sanitizer rewrites html with allowed tags and attributes only, all tags are
+// closed. Enabled features are applied on the way.
+type sanitizer struct {
+	features map[string]bool
+	out      []byte
+	stack    []string
+
+	// positions in out to insert attributes of open tags, -1 is none
+	headingAt int
+	liAt      int
+	preAt     int
+
+	heading []byte
+	slugs   map[string]int
+
+	// li is open and task checkbox may follow
+	taskItem bool
+
+	preLang string
+	inCode  int
+	code    []byte
+	codeHl  *language
+}
+
+// Sanitize cleans html rendered from markdown, features of profile like heading
+// anchors and highlighting are applied.
+func Sanitize(s string, features map[string]bool) string {
+	z := &sanitizer{
+		features:  features,
+		headingAt: -1,
+		liAt:      -1,
+		preAt:     -1,
+		slugs:     make(map[string]int),
+	}
+	z.run(s)
+	return string(z.out)
+}
+
+func (z *sanitizer) run(s string) {
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i == -1 {
+			z.text(s)
+			break
+		}
+		if i > 0 {
+			z.text(s[:i])
+			s = s[i:]
+		}
+
+		if m := commentRegexp.FindString(s); m != "" {
+			s = s[len(m):]
+			continue
+		}
+
+		m := tagRegexp.FindStringSubmatch(s)
+		if m == nil {
+			z.text("<")
+			s = s[1:]
+			continue
+		}
+		s = s[len(m[0]):]
+
+		name := strings.ToLower(m[2])
+		if m[1] == "/" {
+			z.closeTag(name)
+			continue
+		}
+
+		if droppedTags[name] {
+			// skip content until close tag
+			if !strings.HasSuffix(m[0], "/>") {
+				re := regexp.MustCompile(`(?i)</` + name + `\s*>`)
+				if loc := re.FindStringIndex(s); loc != nil {
+					s = s[loc[1]:]
+				} else {
+					s = ""
+				}
+			}
+			continue
+		}
+		z.openTag(name, parseAttrs(m[3]))
+	}
+	for len(z.stack) > 0 {
+		z.closeTag(z.stack[len(z.stack)-1])
+	}
+}
+
+func (z *sanitizer) write(s string) {
+	z.out = append(z.out, s...)
+}
+
+// insert writes s at position of out, positions after it are moved.
+func (z *sanitizer) insert(at int, s string) {
+	z.out = append(z.out[:at], append([]byte(s), z.out[at:]...)...)
+	for _, p := range []*int{&z.headingAt, &z.liAt, &z.preAt} {
+		if *p > at {
+			*p += len(s)
+		}
+	}
+}
+
+func (z *sanitizer) text(s string) {
+	if z.codeHl != nil {
+		z.code = append(z.code, html.UnescapeString(s)...)
+		return
+	}
+
+	t := escapeText(s)
+	if z.headingAt != -1 {
+		z.heading = append(z.heading, html.UnescapeString(s)...)
+	}
+
+	if z.taskItem && strings.TrimSpace(t) != "" {
+		z.taskItem = false
+		if z.features["tasklist"] && len(t) >= 4 && t[0] == '[' && t[2] == ']' && t[3] == ' ' &&
+			strings.IndexByte(" xX", t[1]) != -1 {
+			if z.liAt != -1 {
+				z.insert(z.liAt, ` class="task-list-item"`)
+			}
+			if t[1] == ' ' {
+				z.write(`<input type="checkbox" disabled /> `)
+			} else {
+				z.write(`<input type="checkbox" checked disabled /> `)
+			}
+			t = t[4:]
+		}
+		z.liAt = -1
+	}
+
+	if z.features["emoji"] && z.inCode == 0 {
+		t = replaceEmoji(t)
+	}
+	z.write(t)
+}
+
+func (z *sanitizer) openTag(name string, attrs []attribute) {
+	void, ok := allowedTags[name]
+	if !ok {
+		return
+	}
+	if z.codeHl != nil {
+		// no tags in highlighted code
+		return
+	}
+
+	if name != "p" {
+		z.taskItem = false
+		z.liAt = -1
+	}
+
+	if name == "code" {
+		z.openCode(attrs)
+		return
+	}
+
+	z.write("<" + name)
+	if name == "li" {
+		z.liAt = len(z.out)
+		z.taskItem = true
+	}
+	if name == "pre" {
+		z.preAt = len(z.out)
+		z.preLang = ""
+		z.inCode++
+	}
+
+	var href string
+	for _, attr := range attrs {
+		if name == "pre" && attr.name == "lang" {
+			z.preLang = attr.value
+			continue
+		}
+		valid := allowedAttrs[name+"."+attr.name]
+		if valid == nil {
+			valid = allowedAttrs["*."+attr.name]
+		}
+		if valid == nil || !valid(attr.value) {
+			continue
+		}
+		if name == "a" && attr.name == "href" {
+			href = attr.value
+		}
+		z.write(" " + attr.name + `="` + html.EscapeString(attr.value) + `"`)
+	}
+
+	// no follow external links
+	if href != "" && strings.Contains(href, "//") && !strings.HasPrefix(href, setting.AppUrl) {
+		z.write(` rel="nofollow"`)
+	}
+
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' &&
+		z.features["anchor"] && z.headingAt == -1 {
+		z.headingAt = len(z.out)
+		z.heading = z.heading[:0]
+	}
+
+	if void {
+		z.write(" />")
+		return
+	}
+	z.write(">")
+	z.stack = append(z.stack, name)
+}
+
+// openCode opens code, language of code block in pre is from class of code
+// or lang of pre.
+func (z *sanitizer) openCode(attrs []attribute) {
+	z.inCode++
+	z.stack = append(z.stack, "code")
+
+	inPre := len(z.stack) > 1 && z.stack[len(z.stack)-2] == "pre"
+	if !inPre {
+		z.write("<code>")
+		return
+	}
+
+	lang := z.preLang
+	for _, attr := range attrs {
+		if attr.name == "class" && attr.value != "" {
+			lang = strings.Fields(attr.value)[0]
+		}
+	}
+	lang = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(lang), "language-"), "lang-")
+	if !codeLangRegexp.MatchString(lang) {
+		z.write("<code>")
+		return
+	}
+
+	z.write(`<code class="language-` + lang + `">`)
+	if z.features["highlight"] {
+		if hl := lookupLanguage(lang); hl != nil {
+			z.codeHl = hl
+			z.code = z.code[:0]
+		}
+	}
+}
+
+func (z *sanitizer) closeTag(name string) {
+	if void, ok := allowedTags[name]; !ok || void {
+		return
+	}
+
+	i := len(z.stack) - 1
+	for i >= 0 && z.stack[i] != name {
+		i--
+	}
+	if i == -1 {
+		return
+	}
+	if z.codeHl != nil && name != "code" && name != "pre" {
+		return
+	}
+
+	// close tags not closed in the tag
+	for j := len(z.stack) - 1; j >= i; j-- {
+		z.endTag(z.stack[j])
+	}
+	z.stack = z.stack[:i]
+}
+
+func (z *sanitizer) endTag(name string) {
+	switch name {
+	case "code":
+		z.inCode--
+		if z.codeHl != nil {
+			z.write(highlight(z.codeHl, string(z.code)))
+			z.codeHl = nil
+			if z.preAt != -1 {
+				z.insert(z.preAt, ` class="highlight"`)
+				z.preAt = -1
+			}
+		}
+	case "pre":
+		z.inCode--
+		z.preAt = -1
+		z.preLang = ""
+	case "li":
+		z.taskItem = false
+		z.liAt = -1
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if z.headingAt != -1 {
+			if slug := z.slug(string(z.heading)); slug != "" {
+				z.insert(z.headingAt, ` id="`+slug+`"><a class="anchor" href="#`+slug+`">#</a`)
+			}
+			z.headingAt = -1
+		}
+	}
+	z.write("</" + name + ">")
+}
+
+// slug makes unique id of heading text in document.
+func (z *sanitizer) slug(text string) string {
+	var b []rune
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && len(b) > 0 {
+				b = append(b, '-')
+			}
+			dash = false
+			b = append(b, r)
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			dash = true
+		}
+	}
+	if len(b) == 0 {
+		return ""
+	}
+
+	slug := "h-" + string(b)
+	n := z.slugs[slug]
+	z.slugs[slug] = n + 1
+	if n > 0 {
+		slug += "-" + strconv.Itoa(n)
+	}
+	return slug
+}
@@ -0,0 +1,73 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// account deletion requested by user, the account is deleted after Deadline
+// unless the user cancels it
+type AccountDeletion struct {
+	Id       int
+	User     *User     `orm:"rel(one)"`
+	Reason   string    `orm:"size(255)"`
+	Deadline time.Time `orm:"index"`
+	Created  time.Time `orm:"auto_now_add"`
+}
+
+func (m *AccountDeletion) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccountDeletion) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccountDeletion) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccountDeletion) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccountDeletion) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func AccountDeletions() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("account_deletion").OrderBy("Deadline")
+}
+
+func init() {
+	orm.RegisterModel(new(AccountDeletion))
+}
@@ -16,6 +16,8 @@ package models
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/astaxie/beego"
@@ -31,7 +33,10 @@ type Image struct {
 	Token   string `orm:"size(10)"`
 	Width   int
 	Height  int
-	Ext     int `orm:"index"`
+	Ext     int    `orm:"index"`
+	Hash    string `orm:"size(64);index"`
+	Size    int64
+	Path    string `orm:"size(100)"`
 	Created time.Time
 }
 
@@ -86,20 +91,28 @@ func (m *Image) LinkMiddle() string {
 }
 
 func (m *Image) LinkSize(width int) string {
-	if m.Ext == 3 {
-		// if image is gif then return full size
-		width = 0
-	}
-	var size string
-	switch width {
-	case setting.ImageSizeSmall, setting.ImageSizeMiddle:
-		size = utils.ToStr(width)
-	default:
-		size = "full"
+	size := "full"
+	for _, w := range m.Sizes() {
+		if w == width {
+			size = utils.ToStr(width)
+		}
 	}
 	return "/img/" + m.GetToken() + "." + size + m.GetExt()
 }
 
+// Sizes returns widths of resized images smaller than the image,
+// gif images only have still thumbnails no larger than small size.
+func (m *Image) Sizes() []int {
+	var sizes []int
+	for _, width := range setting.ImageSizes {
+		if width >= m.Width || m.Ext == 3 && width > setting.ImageSizeSmall {
+			continue
+		}
+		sizes = append(sizes, width)
+	}
+	return sizes
+}
+
 func (m *Image) GetExt() string {
 	var ext string
 	switch m.Ext {
@@ -139,6 +152,78 @@ func (m *Image) DecodeToken(token string) error {
 	return nil
 }
 
+func Images() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("image").OrderBy("-Id")
+}
+
+// Attachment is an uploaded file, it belongs to the post or comment links it.
+type Attachment struct {
+	Id        int
+	User      *User    `orm:"rel(fk)"`
+	Token     string   `orm:"size(20);unique"`
+	Name      string   `orm:"size(255)"`
+	Mime      string   `orm:"size(100)"`
+	Size      int64    ``
+	Post      *Post    `orm:"rel(fk);null;on_delete(set_null)"`
+	Comment   *Comment `orm:"rel(fk);null;on_delete(set_null)"`
+	Downloads int
+	Created   time.Time `orm:"index"`
+}
+
+func (m *Attachment) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Attachment) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Attachment) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Attachment) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Attachment) String() string {
+	return m.Name
+}
+
+// Key is storage key of the file.
+func (m *Attachment) Key() string {
+	return "file/" + beego.Date(m.Created, "y/m/d/") + m.Token
+}
+
+func (m *Attachment) Link() string {
+	return "/attachment/" + m.Token + "/" + strings.Replace(url.QueryEscape(m.Name), "+", "%20", -1)
+}
+
+// IsImage reports whether the file can be shown in browsers as image.
+func (m *Attachment) IsImage() bool {
+	switch m.Mime {
+	case "image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp":
+		return true
+	}
+	return false
+}
+
+func Attachments() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("attachment").OrderBy("-Id")
+}
+
 func init() {
-	orm.RegisterModel(new(Image))
+	orm.RegisterModel(new(Image), new(Attachment))
 }
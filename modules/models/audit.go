@@ -0,0 +1,95 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// one changed field of an audited object
+type AuditChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// record of an action done in admin pages
+// Model, ObjectId: the changed object, ObjectId is 0 if unknown
+// Changes: json list of AuditChange
+type AuditLog struct {
+	Id       int
+	Actor    *User     `orm:"rel(fk)"`
+	Action   string    `orm:"size(20);index"`
+	Model    string    `orm:"size(20);index"`
+	ObjectId int       `orm:"index"`
+	Changes  string    `orm:"type(text)"`
+	Ip       string    `orm:"size(40)"`
+	Path     string    `orm:"size(255)"`
+	Created  time.Time `orm:"auto_now_add;index"`
+}
+
+func (m *AuditLog) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AuditLog) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AuditLog) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AuditLog) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func (m *AuditLog) SetChanges(changes []AuditChange) {
+	if len(changes) == 0 {
+		m.Changes = ""
+		return
+	}
+	data, _ := json.Marshal(changes)
+	m.Changes = string(data)
+}
+
+func (m *AuditLog) ChangeList() (changes []AuditChange) {
+	if m.Changes != "" {
+		json.Unmarshal([]byte(m.Changes), &changes)
+	}
+	return
+}
+
+func AuditLogs() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("audit_log").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(AuditLog))
+}
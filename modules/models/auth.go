@@ -16,6 +16,8 @@ package models
 
 import (
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/astaxie/beego/orm"
@@ -63,6 +65,7 @@ type User struct {
 	IsAdmin     bool      `orm:"index"`
 	IsActive    bool      `orm:"index"`
 	IsForbid    bool      `orm:"index"`
+	IsGhost     bool      `orm:"index"`
 	Lang        int       `orm:"index"`
 	Rands       string    `orm:"size(10)"`
 	Created     time.Time `orm:"auto_now_add"`
@@ -115,16 +118,27 @@ func (m *User) Link() string {
 	return fmt.Sprintf("%suser/%s", setting.AppUrl, m.UserName)
 }
 
+// AvatarVersion changes when avatar of user changes, links of avatars
+// with version can be cached long by browsers.
+func (m *User) AvatarVersion() string {
+	if m.AvatarType == setting.AvatarTypePersonalized && strings.HasPrefix(m.AvatarKey, "avatar/") {
+		name := path.Base(m.AvatarKey)
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+	return utils.EncodeMd5(setting.AvatarStyle + m.UserName)[:8]
+}
+
 func (m *User) avatarLink(size int) string {
 	if m.AvatarType == setting.AvatarTypePersonalized {
-		if m.AvatarKey != "" {
+		if m.AvatarKey != "" && !strings.HasPrefix(m.AvatarKey, "avatar/") && setting.QiniuServiceEnabled {
+			// avatars uploaded before storage backends are in qiniu avatar bucket
 			return fmt.Sprintf("%s", utils.GetQiniuZoomViewUrl(utils.GetQiniuPublicDownloadUrl(setting.QiniuAvatarDomain, m.AvatarKey), size, size))
-		} else {
-			return fmt.Sprintf("http://golanghome-public.qiniudn.com/golang_avatar.png?imageView/0/w/%s/h/%s/q/100", utils.ToStr(size), utils.ToStr(size))
 		}
-	} else {
+	} else if setting.AvatarURL != "" && m.GrEmail != "" {
 		return fmt.Sprintf("%s%s?size=%s", setting.AvatarURL, m.GrEmail, utils.ToStr(size))
 	}
+	// uploaded avatars and generated default avatars
+	return fmt.Sprintf("/avatar/%d/%d/%s", m.Id, size, m.AvatarVersion())
 }
 
 func (m *User) AvatarLink24() string {
@@ -156,11 +170,11 @@ func (m *User) FollowerUsers() orm.QuerySeter {
 }
 
 func (m *User) RecentPosts() orm.QuerySeter {
-	return Posts().Filter("User", m.Id)
+	return PublicPosts().Filter("User", m.Id)
 }
 
 func (m *User) RecentComments() orm.QuerySeter {
-	return Comments().Filter("User", m.Id)
+	return Comments().Filter("User", m.Id).Exclude("Status", setting.COMMENT_STATUS_HIDDEN)
 }
 
 func (m *User) FavoritePosts() orm.QuerySeter {
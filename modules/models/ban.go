@@ -0,0 +1,96 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// ban of a user, an ip or an email domain
+// Value: ip, CIDR or email domain, empty for user ban
+// Mode: suspend can not login, mute can login but not write
+// Expires: zero is permanent, expired bans deactivated on next check
+// Operator, Lifter: who banned and who lifted, for audit
+type Ban struct {
+	Id       int
+	Kind     string    `orm:"size(10);index"`
+	User     *User     `orm:"rel(fk);null"`
+	Value    string    `orm:"size(100);index"`
+	Mode     int       ``
+	Reason   string    `orm:"size(255)"`
+	IsActive bool      `orm:"index"`
+	Expires  time.Time `orm:"null"`
+	Operator *User     `orm:"rel(fk);null"`
+	Lifter   *User     `orm:"rel(fk);null"`
+	Created  time.Time `orm:"auto_now_add"`
+	Lifted   time.Time `orm:"null"`
+}
+
+func (m *Ban) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Ban) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Ban) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Ban) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Ban) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func (m *Ban) IsPermanent() bool {
+	return m.Expires.IsZero()
+}
+
+func (m *Ban) IsExpired() bool {
+	return !m.IsPermanent() && m.Expires.Before(time.Now())
+}
+
+func (m *Ban) IsMute() bool {
+	return m.Mode == setting.BAN_MUTE
+}
+
+func Bans() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("ban").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(Ban))
+}
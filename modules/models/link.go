@@ -0,0 +1,81 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// cached metadata of link for rendering its card in posts
+// Kind: github_repo, github_issue, playground, pkg, oembed or page
+// Extra: summary line of known sites, or code of playground snippets
+// Failed: fetching failed, the link is rendered as plain link
+type LinkPreview struct {
+	Id          int
+	UrlHash     string    `orm:"size(40);unique"`
+	Url         string    `orm:"size(500)"`
+	Kind        string    `orm:"size(20)"`
+	Title       string    `orm:"size(255)"`
+	Description string    `orm:"size(500)"`
+	Image       string    `orm:"size(500)"`
+	SiteName    string    `orm:"size(100)"`
+	Extra       string    `orm:"type(text)"`
+	Failed      bool      ``
+	Fetched     time.Time `orm:"index"`
+}
+
+func (m *LinkPreview) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LinkPreview) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LinkPreview) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LinkPreview) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LinkPreview) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func LinkPreviews() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("link_preview").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(LinkPreview))
+}
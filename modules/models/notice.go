@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 	"github.com/astaxie/beego/orm"
+	"github.com/varding/wetalk/modules/markdown"
 	"github.com/varding/wetalk/modules/utils"
 	"github.com/varding/wetalk/setting"
 	"time"
@@ -88,7 +89,7 @@ func (m *Notification) Link() string {
 
 func (m *Notification) GetContentCache() string {
 	if setting.RealtimeRenderMD {
-		return utils.RenderMarkdown(m.Content)
+		return markdown.RenderCached(markdown.ProfileNotification, m.Content)
 	} else {
 		return m.ContentCache
 	}
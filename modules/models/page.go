@@ -20,6 +20,7 @@ import (
 
 	"github.com/astaxie/beego/orm"
 
+	"github.com/varding/wetalk/modules/markdown"
 	"github.com/varding/wetalk/modules/utils"
 	"github.com/varding/wetalk/setting"
 )
@@ -31,6 +32,7 @@ type Page struct {
 	Title        string    `orm:"size(60)"`
 	Content      string    `orm:"type(text)"`
 	ContentCache string    `orm:"type(text)"`
+	CacheVersion string    `orm:"size(8)"`
 	LastAuthor   *User     `orm:"rel(fk);null"`
 	IsPublish    bool      `orm:"index"`
 	Created      time.Time `orm:"auto_now_add"`
@@ -82,16 +84,17 @@ func (m *Page) GetTitle() string {
 	return m.Title
 }
 
+// html of content, stored html rendered by old renderer is rendered again and saved
 func (m *Page) GetContentCache() string {
-	var content, contentCache string
-	content = m.Content
-	contentCache = m.ContentCache
-
 	if setting.RealtimeRenderMD {
-		return utils.RenderMarkdown(content)
-	} else {
-		return contentCache
+		return markdown.RenderCached(markdown.ProfilePage, m.Content)
+	}
+	if version := markdown.Version(markdown.ProfilePage); m.CacheVersion != version && m.Id > 0 {
+		m.ContentCache = markdown.RenderCached(markdown.ProfilePage, m.Content)
+		m.CacheVersion = version
+		m.Update("ContentCache", "CacheVersion")
 	}
+	return m.ContentCache
 }
 
 func Pages() orm.QuerySeter {
@@ -21,6 +21,7 @@ import (
 	"github.com/astaxie/beego/orm"
 	"github.com/beego/i18n"
 
+	"github.com/varding/wetalk/modules/markdown"
 	"github.com/varding/wetalk/modules/utils"
 	"github.com/varding/wetalk/setting"
 )
@@ -32,6 +33,7 @@ type Post struct {
 	Title        string    `orm:"size(60)"`
 	Content      string    `orm:"type(text)"`
 	ContentCache string    `orm:"type(text)"`
+	CacheVersion string    `orm:"size(8)"`
 	Browsers     int       `orm:"index"`
 	Replys       int       `orm:"index"`
 	Favorites    int       `orm:"index"`
@@ -40,6 +42,8 @@ type Post struct {
 	Topic        *Topic    `orm:"rel(fk)"`
 	Lang         int       `orm:"index"`
 	IsBest       bool      `orm:"index"`
+	IsHidden     bool      `orm:"index"`
+	IsLocked     bool      ``
 	CanEdit      bool      `orm:"index"`
 	Category     *Category `orm:"rel(fk)"`
 	Created      time.Time `orm:"auto_now_add"`
@@ -87,12 +91,17 @@ func (m *Post) Path() string {
 	return fmt.Sprintf("/post/%d", m.Id)
 }
 
+// html of content, stored html rendered by old renderer is rendered again and saved
 func (m *Post) GetContentCache() string {
 	if setting.RealtimeRenderMD {
-		return utils.RenderMarkdown(m.Content)
-	} else {
-		return m.ContentCache
+		return markdown.RenderCached(markdown.ProfilePost, m.Content)
 	}
+	if version := markdown.Version(markdown.ProfilePost); m.CacheVersion != version && m.Id > 0 {
+		m.ContentCache = markdown.RenderCached(markdown.ProfilePost, m.Content)
+		m.CacheVersion = version
+		m.Update("ContentCache", "CacheVersion")
+	}
+	return m.ContentCache
 }
 
 func (m *Post) Comments() orm.QuerySeter {
@@ -107,6 +116,11 @@ func Posts() orm.QuerySeter {
 	return orm.NewOrm().QueryTable("post").OrderBy("-Id")
 }
 
+// posts not hidden by moderator
+func PublicPosts() orm.QuerySeter {
+	return Posts().Filter("IsHidden", false)
+}
+
 // commnet content for post
 type Comment struct {
 	Id           int
@@ -114,6 +128,7 @@ type Comment struct {
 	Post         *Post  `orm:"rel(fk)"`
 	Message      string `orm:"type(text)"`
 	MessageCache string `orm:"type(text)"`
+	CacheVersion string `orm:"size(8)"`
 	Floor        int
 	Status       int       `orm:"index"`
 	Created      time.Time `orm:"auto_now_add;index"`
@@ -147,12 +162,21 @@ func (m *Comment) Delete() error {
 	return nil
 }
 
+func (m *Comment) IsHidden() bool {
+	return m.Status == setting.COMMENT_STATUS_HIDDEN
+}
+
+// html of message, stored html rendered by old renderer is rendered again and saved
 func (m *Comment) GetMessageCache() string {
 	if setting.RealtimeRenderMD {
-		return utils.RenderMarkdown(m.Message)
-	} else {
-		return m.MessageCache
+		return markdown.RenderCached(markdown.ProfileComment, m.Message)
+	}
+	if version := markdown.Version(markdown.ProfileComment); m.CacheVersion != version && m.Id > 0 {
+		m.MessageCache = markdown.RenderCached(markdown.ProfileComment, m.Message)
+		m.CacheVersion = version
+		m.Update("MessageCache", "CacheVersion")
 	}
+	return m.MessageCache
 }
 
 func (m *Comment) String() string {
@@ -0,0 +1,94 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// report of a post, comment or user
+// User: author of the reported content, or the reported user
+// Title, Uri: snapshot of the target when reported
+// Note: moderator audit notes, appended on each handling
+type Report struct {
+	Id       int
+	Reporter *User     `orm:"rel(fk)"`
+	Kind     string    `orm:"size(10);index"`
+	TargetId int       `orm:"index"`
+	User     *User     `orm:"rel(fk)"`
+	Title    string    `orm:"size(60)"`
+	Uri      string    `orm:"size(100)"`
+	Reason   int       ``
+	Message  string    `orm:"size(255)"`
+	Status   int       `orm:"index"`
+	Action   string    `orm:"size(10)"`
+	Note     string    `orm:"type(text)"`
+	Handler  *User     `orm:"rel(fk);null"`
+	Created  time.Time `orm:"auto_now_add;index"`
+	Handled  time.Time `orm:"null"`
+}
+
+func (m *Report) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Report) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Report) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Report) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Report) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func (m *Report) Link() string {
+	return setting.AppUrl + m.Uri
+}
+
+func (m *Report) IsOpen() bool {
+	return m.Status == setting.REPORT_OPEN
+}
+
+func Reports() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("report").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(Report))
+}
@@ -0,0 +1,138 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// named set of permissions
+// Permissions: comma separated permission strings, like post.lock
+type Role struct {
+	Id          int
+	Name        string    `orm:"size(30);unique"`
+	Description string    `orm:"size(255)"`
+	Permissions string    `orm:"type(text)"`
+	Created     time.Time `orm:"auto_now_add"`
+	Updated     time.Time `orm:"auto_now"`
+}
+
+func (m *Role) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Role) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Role) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Role) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Role) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func (m *Role) PermissionList() []string {
+	if len(m.Permissions) == 0 {
+		return nil
+	}
+	return strings.Split(m.Permissions, ",")
+}
+
+func (m *Role) HasPermission(perm string) bool {
+	for _, p := range m.PermissionList() {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func Roles() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("role").OrderBy("Id")
+}
+
+// role granted to user
+// Category, Topic: scope of the grant, both null is a site wide grant
+type RoleGrant struct {
+	Id       int
+	User     *User     `orm:"rel(fk)"`
+	Role     *Role     `orm:"rel(fk)"`
+	Category *Category `orm:"rel(fk);null"`
+	Topic    *Topic    `orm:"rel(fk);null"`
+	Created  time.Time `orm:"auto_now_add"`
+}
+
+func (m *RoleGrant) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RoleGrant) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RoleGrant) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RoleGrant) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RoleGrant) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func RoleGrants() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("role_grant").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(Role), new(RoleGrant))
+}
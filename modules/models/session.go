@@ -0,0 +1,84 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// login of user on a device, browser sessions and remember cookie of
+// the device refer to it, revoke it will logout the device
+// Token: keyed hash of the raw remember token, empty if not remembered
+// PrevToken: hash of the token replaced at Rotated, accepted for a short while
+// Expires: remember cookie expire time
+type LoginSession struct {
+	Id         int
+	User       *User     `orm:"rel(fk)"`
+	Device     string    `orm:"size(50)"`
+	UserAgent  string    `orm:"size(255)"`
+	Ip         string    `orm:"size(40)"`
+	Token      string    `orm:"size(64);index"`
+	PrevToken  string    `orm:"size(64)"`
+	Rotated    time.Time `orm:"null"`
+	IsRemember bool      ``
+	Expires    time.Time `orm:"null"`
+	IsRevoked  bool      `orm:"index"`
+	LastSeen   time.Time `orm:"index"`
+	Created    time.Time `orm:"auto_now_add"`
+}
+
+func (m *LoginSession) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LoginSession) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LoginSession) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LoginSession) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *LoginSession) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func LoginSessions() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("login_session").OrderBy("-LastSeen")
+}
+
+func init() {
+	orm.RegisterModel(new(LoginSession))
+}
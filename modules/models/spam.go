@@ -0,0 +1,155 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// blocklist rule of content check, managed in admin
+// Pattern: keyword matched case insensitive, or regexp when IsRegex
+// Action: setting.SPAM_ACTION_HOLD or setting.SPAM_ACTION_REJECT
+type SpamRule struct {
+	Id       int
+	Pattern  string    `orm:"size(200)"`
+	IsRegex  bool      ``
+	Action   int       ``
+	IsActive bool      `orm:"index"`
+	Created  time.Time `orm:"auto_now_add"`
+	Updated  time.Time `orm:"auto_now"`
+}
+
+func (m *SpamRule) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SpamRule) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SpamRule) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SpamRule) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *SpamRule) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func SpamRules() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("spam_rule").OrderBy("-Id")
+}
+
+// hash of normalized content for duplicate detection
+type ContentHash struct {
+	Id      int
+	User    *User     `orm:"rel(fk)"`
+	Hash    string    `orm:"size(40);index"`
+	Created time.Time `orm:"auto_now_add;index"`
+}
+
+func (m *ContentHash) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func ContentHashes() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("content_hash").OrderBy("-Id")
+}
+
+// post or comment held by content check, waiting for moderator
+// Post: the commented post when Kind is comment
+// Object: id of the published post or comment after approved
+type HeldContent struct {
+	Id        int
+	Kind      string    `orm:"size(10);index"`
+	User      *User     `orm:"rel(fk)"`
+	Post      *Post     `orm:"rel(fk);null"`
+	Category  int       ``
+	Topic     int       ``
+	Lang      int       ``
+	Title     string    `orm:"size(60)"`
+	Content   string    `orm:"type(text)"`
+	Ip        string    `orm:"size(40)"`
+	UserAgent string    `orm:"size(255)"`
+	Checker   string    `orm:"size(30)"`
+	Reason    string    `orm:"size(255)"`
+	Status    int       `orm:"index"`
+	Object    int       ``
+	Moderator *User     `orm:"rel(fk);null"`
+	Created   time.Time `orm:"auto_now_add;index"`
+	Handled   time.Time `orm:"null"`
+}
+
+func (m *HeldContent) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *HeldContent) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *HeldContent) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *HeldContent) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *HeldContent) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func HeldContents() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("held_content").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(SpamRule), new(ContentHash), new(HeldContent))
+}
@@ -0,0 +1,127 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// daily aggregate of site activity, computed by background job
+// ActiveUsers: users posted or commented in the day
+// TotalViews: sum of post browsers when aggregated, Views is the increase of the day
+// Storage: bytes of local upload files when aggregated
+type DailyStat struct {
+	Id            int
+	Day           time.Time `orm:"type(date);unique"`
+	Registrations int
+	Posts         int
+	Comments      int
+	ActiveUsers   int
+	Views         int
+	TotalViews    int64
+	Images        int
+	Storage       int64
+	Updated       time.Time `orm:"auto_now"`
+}
+
+func (m *DailyStat) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyStat) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyStat) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyStat) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyStat) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func DailyStats() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("daily_stat").OrderBy("Day")
+}
+
+// daily posts and comments of a topic
+type DailyTopicStat struct {
+	Id       int
+	Day      time.Time `orm:"type(date);index"`
+	Topic    *Topic    `orm:"rel(fk)"`
+	Category *Category `orm:"rel(fk)"`
+	Posts    int
+	Comments int
+}
+
+func (m *DailyTopicStat) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyTopicStat) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyTopicStat) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyTopicStat) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *DailyTopicStat) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func DailyTopicStats() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("daily_topic_stat").OrderBy("Day")
+}
+
+func init() {
+	orm.RegisterModel(new(DailyStat), new(DailyTopicStat))
+}
@@ -0,0 +1,188 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// personal access token or token issued to an oauth app
+// Token: keyed hash of the raw token, raw value is only shown once
+// Scopes: comma separated scope names
+// App: set when the token was issued through oauth2
+type AccessToken struct {
+	Id        int
+	User      *User     `orm:"rel(fk)"`
+	App       *OAuthApp `orm:"rel(fk);null"`
+	Name      string    `orm:"size(50)"`
+	Token     string    `orm:"size(64);unique"`
+	Scopes    string    `orm:"size(50)"`
+	LastIp    string    `orm:"size(40)"`
+	LastUsed  time.Time `orm:"null"`
+	Expires   time.Time `orm:"null"`
+	IsRevoked bool      `orm:"index"`
+	Created   time.Time `orm:"auto_now_add"`
+}
+
+func (m *AccessToken) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccessToken) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccessToken) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccessToken) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *AccessToken) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func (m *AccessToken) ScopeList() []string {
+	if len(m.Scopes) == 0 {
+		return nil
+	}
+	return strings.Split(m.Scopes, ",")
+}
+
+func (m *AccessToken) HasScope(scope string) bool {
+	for _, s := range m.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *AccessToken) IsExpired() bool {
+	return !m.Expires.IsZero() && m.Expires.Before(time.Now())
+}
+
+func AccessTokens() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("access_token").OrderBy("-Id")
+}
+
+// third-party application registered for oauth2
+// ClientSecret: keyed hash of the raw secret
+type OAuthApp struct {
+	Id           int
+	User         *User     `orm:"rel(fk)"`
+	Name         string    `orm:"size(50)"`
+	Url          string    `orm:"size(100)"`
+	ClientId     string    `orm:"size(40);unique"`
+	ClientSecret string    `orm:"size(64)"`
+	RedirectUri  string    `orm:"size(200)"`
+	Created      time.Time `orm:"auto_now_add"`
+}
+
+func (m *OAuthApp) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *OAuthApp) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *OAuthApp) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *OAuthApp) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *OAuthApp) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func OAuthApps() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("o_auth_app").OrderBy("-Id")
+}
+
+// short lived authorization code of oauth2 authorization-code flow
+type OAuthCode struct {
+	Id          int
+	App         *OAuthApp `orm:"rel(fk)"`
+	User        *User     `orm:"rel(fk)"`
+	Code        string    `orm:"size(64);unique"`
+	Scopes      string    `orm:"size(50)"`
+	RedirectUri string    `orm:"size(200)"`
+	Expires     time.Time ``
+	Created     time.Time `orm:"auto_now_add"`
+}
+
+func (m *OAuthCode) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *OAuthCode) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *OAuthCode) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func OAuthCodes() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("o_auth_code")
+}
+
+func init() {
+	orm.RegisterModel(new(AccessToken), new(OAuthApp), new(OAuthCode))
+}
@@ -0,0 +1,123 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// totp secret of user, login asks for a code once it is enabled
+// Secret: base32 encoded shared secret
+// LastStep: time step of the last accepted code, a code can't be used twice
+type TwoFactor struct {
+	Id        int
+	User      *User  `orm:"rel(one)"`
+	Secret    string `orm:"size(64)"`
+	IsEnabled bool
+	LastStep  int64
+	Created   time.Time `orm:"auto_now_add"`
+	Updated   time.Time `orm:"auto_now"`
+}
+
+func (m *TwoFactor) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *TwoFactor) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *TwoFactor) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *TwoFactor) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *TwoFactor) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func TwoFactors() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("two_factor").OrderBy("-Id")
+}
+
+// single-use code to login when the totp device is lost
+// Code: keyed hash of the raw code, raw value is only shown once
+type RecoveryCode struct {
+	Id      int
+	User    *User     `orm:"rel(fk)"`
+	Code    string    `orm:"size(64);index"`
+	IsUsed  bool      `orm:"index"`
+	Used    time.Time `orm:"null"`
+	Created time.Time `orm:"auto_now_add"`
+}
+
+func (m *RecoveryCode) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RecoveryCode) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RecoveryCode) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RecoveryCode) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *RecoveryCode) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func RecoveryCodes() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("recovery_code").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(TwoFactor), new(RecoveryCode))
+}
@@ -0,0 +1,152 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// outgoing webhook configured by admin
+// Secret: key of the HMAC-SHA256 payload signature, empty to not sign
+// Events: comma separated event names the hook subscribed
+type Webhook struct {
+	Id       int
+	Url      string    `orm:"size(200)"`
+	Secret   string    `orm:"size(100)"`
+	Events   string    `orm:"size(200)"`
+	IsActive bool      `orm:"index"`
+	Created  time.Time `orm:"auto_now_add"`
+	Updated  time.Time `orm:"auto_now"`
+}
+
+func (m *Webhook) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Webhook) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Webhook) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Webhook) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Webhook) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func (m *Webhook) EventList() []string {
+	if len(m.Events) == 0 {
+		return nil
+	}
+	return strings.Split(m.Events, ",")
+}
+
+func (m *Webhook) HasEvent(event string) bool {
+	for _, e := range m.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Webhook) Deliveries() orm.QuerySeter {
+	return WebhookDeliveries().Filter("Webhook", m.Id)
+}
+
+func Webhooks() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("webhook").OrderBy("-Id")
+}
+
+// one delivery of an event to a webhook, retried deliveries share the same row
+// IsPending: failed delivery waiting for retry at NextRetry
+type WebhookDelivery struct {
+	Id           int
+	Webhook      *Webhook  `orm:"rel(fk)"`
+	Event        string    `orm:"size(30);index"`
+	Payload      string    `orm:"type(text)"`
+	ResponseCode int       ``
+	ResponseBody string    `orm:"type(text)"`
+	Error        string    `orm:"size(255)"`
+	Duration     int       ``
+	Attempts     int       ``
+	IsSuccess    bool      `orm:"index"`
+	IsPending    bool      `orm:"index"`
+	NextRetry    time.Time `orm:"null;index"`
+	Created      time.Time `orm:"auto_now_add"`
+	Updated      time.Time `orm:"auto_now"`
+}
+
+func (m *WebhookDelivery) Insert() error {
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *WebhookDelivery) Read(fields ...string) error {
+	if err := orm.NewOrm().Read(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *WebhookDelivery) Update(fields ...string) error {
+	if _, err := orm.NewOrm().Update(m, fields...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *WebhookDelivery) Delete() error {
+	if _, err := orm.NewOrm().Delete(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *WebhookDelivery) String() string {
+	return utils.ToStr(m.Id)
+}
+
+func WebhookDeliveries() orm.QuerySeter {
+	return orm.NewOrm().QueryTable("webhook_delivery").OrderBy("-Id")
+}
+
+func init() {
+	orm.RegisterModel(new(Webhook), new(WebhookDelivery))
+}
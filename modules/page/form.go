@@ -17,6 +17,7 @@ package page
 import (
 	"github.com/astaxie/beego/validation"
 
+	"github.com/varding/wetalk/modules/markdown"
 	"github.com/varding/wetalk/modules/models"
 	"github.com/varding/wetalk/modules/utils"
 )
@@ -63,5 +64,6 @@ func (form *PageAdminForm) SetToPage(page *models.Page) {
 	}
 	page.LastAuthor.Id = form.LastAuthor
 
-	page.ContentCache = utils.RenderMarkdown(page.Content)
+	page.ContentCache = markdown.RenderCached(markdown.ProfilePage, page.Content)
+	page.CacheVersion = markdown.Version(markdown.ProfilePage)
 }
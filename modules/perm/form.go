@@ -0,0 +1,188 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package perm
+
+import (
+	"strings"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+)
+
+type RoleAdminForm struct {
+	Create      bool     `form:"-"`
+	Id          int      `form:"-"`
+	Name        string   `valid:"Required;MaxSize(30)"`
+	Description string   `form:"type(textarea)" valid:"MaxSize(255)"`
+	Permissions []string `form:"type(select);attr(multiple,multiple);attr(rel,select2)"`
+}
+
+func (form *RoleAdminForm) PermissionsSelectData() [][]string {
+	data := make([][]string, 0, len(Permissions))
+	for _, p := range Permissions {
+		data = append(data, []string{"model.perm_" + strings.Replace(p, ".", "_", -1), p})
+	}
+	return data
+}
+
+func (form *RoleAdminForm) Valid(v *validation.Validation) {
+	if models.CheckIsExist(models.Roles(), "Name", form.Name, form.Id) {
+		v.SetError("Name", "admin.field_need_unique")
+	}
+
+	if len(cleanPermissions(form.Permissions)) == 0 {
+		v.SetError("Permissions", "Can not be empty")
+	}
+}
+
+func (form *RoleAdminForm) Labels() map[string]string {
+	return map[string]string{
+		"Name":        "model.role_name",
+		"Description": "model.role_description",
+		"Permissions": "model.role_permissions",
+	}
+}
+
+func (form *RoleAdminForm) SetFromRole(role *models.Role) {
+	utils.SetFormValues(role, form)
+	form.Permissions = role.PermissionList()
+}
+
+func (form *RoleAdminForm) SetToRole(role *models.Role) {
+	utils.SetFormValues(form, role, "Id")
+	role.Permissions = cleanPermissions(form.Permissions)
+}
+
+// filter unknown permissions and return them in a comma separated string
+func cleanPermissions(perms []string) string {
+	clean := make([]string, 0, len(Permissions))
+	for _, p := range Permissions {
+		for _, v := range perms {
+			if v == p {
+				clean = append(clean, p)
+				break
+			}
+		}
+	}
+	return strings.Join(clean, ",")
+}
+
+type RoleGrantAdminForm struct {
+	Create   bool `form:"-"`
+	Id       int  `form:"-"`
+	User     int  `form:"attr(rel,select2-admin-model);attr(data-model,User)" valid:"Required"`
+	Role     int  `form:"type(select);attr(rel,select2)" valid:"Required"`
+	Category int  `form:"type(select);attr(rel,select2)"`
+	Topic    int  `form:"type(select);attr(rel,select2)"`
+}
+
+func (form *RoleGrantAdminForm) RoleSelectData() [][]string {
+	var roles []models.Role
+	models.Roles().All(&roles)
+	data := make([][]string, 0, len(roles))
+	for _, role := range roles {
+		data = append(data, []string{role.Name, utils.ToStr(role.Id)})
+	}
+	return data
+}
+
+func (form *RoleGrantAdminForm) CategorySelectData() [][]string {
+	var cats []models.Category
+	models.Categories().OrderBy("-order").All(&cats)
+	data := [][]string{{"model.grant_any", "0"}}
+	for _, cat := range cats {
+		data = append(data, []string{cat.Name, utils.ToStr(cat.Id)})
+	}
+	return data
+}
+
+func (form *RoleGrantAdminForm) TopicSelectData() [][]string {
+	var topics []models.Topic
+	models.Topics().OrderBy("-order").All(&topics)
+	data := [][]string{{"model.grant_any", "0"}}
+	for _, topic := range topics {
+		data = append(data, []string{topic.Name, utils.ToStr(topic.Id)})
+	}
+	return data
+}
+
+func (form *RoleGrantAdminForm) Valid(v *validation.Validation) {
+	user := models.User{Id: form.User}
+	if user.Read() != nil {
+		v.SetError("User", "admin.not_found_by_id")
+	}
+
+	role := models.Role{Id: form.Role}
+	if role.Read() != nil {
+		v.SetError("Role", "admin.not_found_by_id")
+	}
+
+	if form.Category != 0 {
+		cat := models.Category{Id: form.Category}
+		if cat.Read() != nil {
+			v.SetError("Category", "admin.not_found_by_id")
+		}
+	}
+
+	if form.Topic != 0 {
+		topic := models.Topic{Id: form.Topic}
+		if topic.Read() != nil {
+			v.SetError("Topic", "admin.not_found_by_id")
+		} else if form.Category != 0 && topic.Category.Id != form.Category {
+			v.SetError("Topic", "admin.grant_topic_not_in_category")
+		}
+	}
+}
+
+func (form *RoleGrantAdminForm) Labels() map[string]string {
+	return map[string]string{
+		"User":     "model.user",
+		"Role":     "model.role",
+		"Category": "model.category",
+		"Topic":    "model.topic",
+	}
+}
+
+func (form *RoleGrantAdminForm) Helps() map[string]string {
+	return map[string]string{
+		"Category": "model.grant_scope_help",
+	}
+}
+
+func (form *RoleGrantAdminForm) SetFromRoleGrant(grant *models.RoleGrant) {
+	form.User = grant.User.Id
+	form.Role = grant.Role.Id
+	if grant.Category != nil {
+		form.Category = grant.Category.Id
+	}
+	if grant.Topic != nil {
+		form.Topic = grant.Topic.Id
+	}
+}
+
+func (form *RoleGrantAdminForm) SetToRoleGrant(grant *models.RoleGrant) {
+	grant.User = &models.User{Id: form.User}
+	grant.Role = &models.Role{Id: form.Role}
+	grant.Category = nil
+	if form.Category != 0 {
+		grant.Category = &models.Category{Id: form.Category}
+	}
+	grant.Topic = nil
+	if form.Topic != 0 {
+		grant.Topic = &models.Topic{Id: form.Topic}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package perm implemented role based permissions with category and topic scopes.
+package perm
+
+import (
+	"sync"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+)
+
+const (
+	PostEdit      = "post.edit"
+	PostBest      = "post.best"
+	PostLock      = "post.lock"
+	PostHide      = "post.hide"
+	CommentHide   = "comment.hide"
+	CommentDelete = "comment.delete"
+	ReportHandle  = "report.handle"
+	ContentReview = "content.review"
+	UserBan       = "user.ban"
+)
+
+// all known permissions, in display order
+var Permissions = []string{
+	PostEdit, PostBest, PostLock, PostHide,
+	CommentHide, CommentDelete,
+	ReportHandle, ContentReview, UserBan,
+}
+
+// where a permission is checked, zero value is site wide
+type Scope struct {
+	Category int
+	Topic    int
+}
+
+var Global = Scope{}
+
+func ScopeOfPost(post *models.Post) Scope {
+	var scope Scope
+	if post.Category != nil {
+		scope.Category = post.Category.Id
+	}
+	if post.Topic != nil {
+		scope.Topic = post.Topic.Id
+	}
+	return scope
+}
+
+func ScopeOfTopic(topic *models.Topic) Scope {
+	scope := Scope{Topic: topic.Id}
+	if topic.Category != nil {
+		scope.Category = topic.Category.Id
+	}
+	return scope
+}
+
+// scope of any supported object, unknown objects are site wide
+func ScopeOf(obj interface{}) Scope {
+	switch v := obj.(type) {
+	case Scope:
+		return v
+	case *models.Post:
+		return ScopeOfPost(v)
+	case *models.Topic:
+		return ScopeOfTopic(v)
+	case *models.Category:
+		return Scope{Category: v.Id}
+	}
+	return Global
+}
+
+type grant struct {
+	perms    map[string]bool
+	category int
+	topic    int
+}
+
+// grant without scope matches everywhere, category grant matches its topics
+func (g *grant) match(perm string, scope Scope) bool {
+	if !g.perms[perm] {
+		return false
+	}
+	switch {
+	case g.topic != 0:
+		return g.topic == scope.Topic
+	case g.category != 0:
+		return g.category == scope.Category
+	}
+	return true
+}
+
+var (
+	lock   sync.RWMutex
+	grants = make(map[int][]grant)
+)
+
+// grants will be reloaded on next check, call after roles or grants changed
+func Reload() {
+	lock.Lock()
+	grants = make(map[int][]grant)
+	lock.Unlock()
+}
+
+func userGrants(userId int) []grant {
+	lock.RLock()
+	list, ok := grants[userId]
+	lock.RUnlock()
+	if ok {
+		return list
+	}
+
+	var rows []models.RoleGrant
+	if _, err := models.RoleGrants().Filter("User", userId).RelatedSel("Role").All(&rows); err != nil {
+		beego.Error("Perm: load grants ", err)
+		return nil
+	}
+
+	list = make([]grant, 0, len(rows))
+	for _, row := range rows {
+		g := grant{perms: make(map[string]bool)}
+		for _, p := range row.Role.PermissionList() {
+			g.perms[p] = true
+		}
+		if row.Category != nil {
+			g.category = row.Category.Id
+		}
+		if row.Topic != nil {
+			g.topic = row.Topic.Id
+		}
+		list = append(list, g)
+	}
+
+	lock.Lock()
+	grants[userId] = list
+	lock.Unlock()
+	return list
+}
+
+// check user has permission in scope, admin can do anything
+func Can(user *models.User, perm string, scope Scope) bool {
+	if user == nil || user.Id == 0 || !user.IsActive || user.IsForbid {
+		return false
+	}
+	if user.IsAdmin {
+		return true
+	}
+	for _, g := range userGrants(user.Id) {
+		if g.match(perm, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// check user has permission in any scope
+func CanAny(user *models.User, perm string) bool {
+	if user == nil || user.Id == 0 || !user.IsActive || user.IsForbid {
+		return false
+	}
+	if user.IsAdmin {
+		return true
+	}
+	for _, g := range userGrants(user.Id) {
+		if g.perms[perm] {
+			return true
+		}
+	}
+	return false
+}
+
+// user is admin or has any granted role
+func IsModerator(user *models.User) bool {
+	if user == nil || user.Id == 0 {
+		return false
+	}
+	return user.IsAdmin || len(userGrants(user.Id)) > 0
+}
+
+// template function, {{if can .User "post.lock" .Post}}
+func can(user *models.User, perm string, obj ...interface{}) bool {
+	scope := Global
+	if len(obj) > 0 {
+		scope = ScopeOf(obj[0])
+	}
+	return Can(user, perm, scope)
+}
+
+func init() {
+	beego.AddFuncMap("can", can)
+	beego.AddFuncMap("canany", CanAny)
+	beego.AddFuncMap("ismoderator", IsModerator)
+}
@@ -0,0 +1,78 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package playground
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// fence returns fence marker and language of opening fence line.
+func fence(line string) (string, string) {
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, marker) {
+			n := len(line) - len(strings.TrimLeft(line, marker[:1]))
+			info := strings.Fields(line[n:])
+			lang := ""
+			if len(info) > 0 {
+				lang = strings.ToLower(info[0])
+			}
+			return line[:n], lang
+		}
+	}
+	return "", ""
+}
+
+// FormatMarkdown runs gofmt on fenced go code blocks of markdown content,
+// blocks can't be parsed are kept and their errors are returned.
+func FormatMarkdown(content string) (string, []error) {
+	var errs []error
+	var out, code []string
+	marker, lang := "", ""
+	blocks := 0
+
+	content = strings.Replace(content, "\r\n", "\n", -1)
+	lines := strings.Split(content, "\n")
+	for _, line := range lines {
+		if marker == "" {
+			out = append(out, line)
+			marker, lang = fence(line)
+			continue
+		}
+
+		if strings.HasPrefix(line, marker) && strings.TrimSpace(strings.TrimLeft(line, marker[:1])) == "" {
+			if (lang == "go" || lang == "golang") && len(code) > 0 {
+				blocks++
+				src := strings.Join(code, "\n")
+				if formatted, err := format.Source([]byte(src)); err != nil {
+					errs = append(errs, fmt.Errorf("block %d: %v", blocks, err))
+				} else {
+					code = strings.Split(string(bytes.TrimRight(formatted, "\n")), "\n")
+				}
+			}
+			out = append(out, code...)
+			out = append(out, line)
+			marker, lang, code = "", "", nil
+			continue
+		}
+		code = append(code, line)
+	}
+
+	// unclosed block
+	out = append(out, code...)
+	return strings.Join(out, "\n"), errs
+}
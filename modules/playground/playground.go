@@ -0,0 +1,191 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package playground proxies running, formatting and sharing of go snippets
+// to a playground service, run results are cached by hash of snippets.
+package playground
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/varding/wetalk/setting"
+)
+
+var (
+	ErrDisabled = errors.New("playground is disabled")
+	ErrTooLarge = errors.New("code is too large")
+)
+
+// error of code can't be parsed when formatting
+type SyntaxError string
+
+func (e SyntaxError) Error() string {
+	return string(e)
+}
+
+// one output event of running program
+type Event struct {
+	Message string
+	Kind    string // stdout or stderr
+	Delay   time.Duration
+}
+
+// result of compiling and running snippet
+type Result struct {
+	Errors    string
+	VetErrors string
+	Status    int
+	Events    []Event
+}
+
+// Output joins messages of all events.
+func (r *Result) Output() string {
+	var out []string
+	for _, e := range r.Events {
+		out = append(out, e.Message)
+	}
+	return strings.Join(out, "")
+}
+
+func newClient() *http.Client {
+	return &http.Client{Timeout: time.Duration(setting.PlaygroundTimeout) * time.Second}
+}
+
+func check(code string) error {
+	if !setting.PlaygroundEnabled {
+		return ErrDisabled
+	}
+	if int64(len(code)) > setting.PlaygroundMaxCodeSize {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+func post(path string, contentType string, body io.Reader) ([]byte, error) {
+	endpoint := strings.TrimRight(setting.PlaygroundEndpoint, "/")
+	req, err := http.NewRequest("POST", endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "WeTalk/"+setting.APP_VER)
+
+	resp, err := newClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("playground: %s", resp.Status)
+	}
+	return data, nil
+}
+
+func postForm(path string, values url.Values, v interface{}) error {
+	data, err := post(path, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Run compiles and runs code on playground, results of same code are
+// returned from cache.
+func Run(code string) (*Result, error) {
+	if err := check(code); err != nil {
+		return nil, err
+	}
+
+	// results are saved as json strings, which all cache backends can hold
+	key := "playground:run:" + hash(code)
+	if v, ok := setting.Cache.Get(key).(string); ok {
+		var result Result
+		if json.Unmarshal([]byte(v), &result) == nil {
+			return &result, nil
+		}
+	}
+
+	var result Result
+	values := url.Values{"version": {"2"}, "body": {code}, "withVet": {"true"}}
+	if err := postForm("/compile", values, &result); err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(&result); err == nil {
+		setting.Cache.Put(key, string(data), int64(setting.PlaygroundCacheMinutes)*60)
+	}
+	return &result, nil
+}
+
+// Format formats code and fixes imports on playground.
+func Format(code string) (string, error) {
+	if err := check(code); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Body  string
+		Error string
+	}
+	values := url.Values{"body": {code}, "imports": {"true"}}
+	if err := postForm("/fmt", values, &result); err != nil {
+		return "", err
+	}
+	if result.Error != "" {
+		return "", SyntaxError(result.Error)
+	}
+	return result.Body, nil
+}
+
+// Share saves code on playground and returns link of it.
+func Share(code string) (string, error) {
+	if err := check(code); err != nil {
+		return "", err
+	}
+
+	key := "playground:share:" + hash(code)
+	id, _ := setting.Cache.Get(key).(string)
+	if id == "" {
+		data, err := post("/share", "text/plain; charset=utf-8", strings.NewReader(code))
+		if err != nil {
+			return "", err
+		}
+		id = strings.TrimSpace(string(data))
+		if id == "" || strings.ContainsAny(id, "/?#") {
+			return "", fmt.Errorf("playground: invalid share id %q", id)
+		}
+		setting.Cache.Put(key, id, int64(setting.PlaygroundCacheMinutes)*60)
+	}
+	return strings.TrimRight(setting.PlaygroundShareURL, "/") + "/p/" + id, nil
+}
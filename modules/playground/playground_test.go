@@ -0,0 +1,134 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package playground
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/cache"
+
+	. "github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// local fake of playground, counts compile requests to check cache
+func playgroundStub(compiles *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/compile", func(w http.ResponseWriter, r *http.Request) {
+		*compiles++
+		if strings.Contains(r.FormValue("body"), "undefined") {
+			w.Write([]byte(`{"Errors":"prog.go:3:2: undefined: x","Events":null}`))
+			return
+		}
+		w.Write([]byte(`{"Errors":"","Status":1,"Events":[{"Message":"hello\n","Kind":"stdout","Delay":0},{"Message":"world\n","Kind":"stderr","Delay":0}]}`))
+	})
+	mux.HandleFunc("/fmt", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("body") == "bad" {
+			w.Write([]byte(`{"Body":"","Error":"prog.go:1:1: expected 'package'"}`))
+			return
+		}
+		w.Write([]byte(`{"Body":"package main\n","Error":""}`))
+	})
+	mux.HandleFunc("/share", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("abc123"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func setupStub(t *testing.T, compiles *int) *httptest.Server {
+	ts := playgroundStub(compiles)
+	c, err := cache.NewCache("memory", `{"interval":360}`)
+	ThrowFailNow(t, AssertIs(err, nil))
+	setting.Cache = c
+	setting.PlaygroundEnabled = true
+	setting.PlaygroundEndpoint = ts.URL
+	setting.PlaygroundShareURL = "https://play.golang.org"
+	setting.PlaygroundTimeout = 3
+	setting.PlaygroundMaxCodeSize = 1 << 10
+	setting.PlaygroundCacheMinutes = 10
+	return ts
+}
+
+func TestRun(t *testing.T) {
+	compiles := 0
+	ts := setupStub(t, &compiles)
+	defer ts.Close()
+
+	res, err := Run("package main")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(res.Output(), "hello\nworld\n"))
+	ThrowFail(t, AssertIs(res.Status, 1))
+
+	// same code is returned from cache
+	res, err = Run("package main")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(res.Output(), "hello\nworld\n"))
+	ThrowFail(t, AssertIs(compiles, 1))
+
+	res, err = Run("undefined")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(res.Errors, "prog.go:3:2: undefined: x"))
+	ThrowFail(t, AssertIs(compiles, 2))
+
+	_, err = Run(strings.Repeat("a", 2<<10))
+	ThrowFail(t, AssertIs(err, ErrTooLarge))
+
+	setting.PlaygroundEnabled = false
+	_, err = Run("package main")
+	ThrowFail(t, AssertIs(err, ErrDisabled))
+}
+
+func TestFormatShare(t *testing.T) {
+	compiles := 0
+	ts := setupStub(t, &compiles)
+	defer ts.Close()
+
+	code, err := Format("package  main")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(code, "package main\n"))
+
+	_, err = Format("bad")
+	_, ok := err.(SyntaxError)
+	ThrowFail(t, AssertIs(ok, true))
+
+	link, err := Share("package main")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(link, "https://play.golang.org/p/abc123"))
+
+	_, err = Share("")
+	ThrowFail(t, AssertIs(err != nil, true))
+}
+
+func TestFormatMarkdown(t *testing.T) {
+	content := "text\n```go\nfunc  main(){\nfmt.Println(1)}\n```\n\n```\nnot  go\n```\n~~~golang\nx :=  1\n~~~"
+	formatted, errs := FormatMarkdown(content)
+	ThrowFail(t, AssertIs(len(errs), 0))
+	ThrowFail(t, AssertIs(formatted, "text\n```go\nfunc main() {\n\tfmt.Println(1)\n}\n```\n\n```\nnot  go\n```\n~~~golang\nx := 1\n~~~"))
+
+	// blocks with syntax errors are kept
+	content = "```go\nfunc main( {\n```\r\n```go\n```\n```go\nunclosed  block"
+	formatted, errs = FormatMarkdown(content)
+	ThrowFail(t, AssertIs(len(errs), 1))
+	ThrowFail(t, AssertIs(formatted, "```go\nfunc main( {\n```\n```go\n```\n```go\nunclosed  block"))
+}
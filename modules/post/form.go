@@ -18,19 +18,24 @@ import (
 	"github.com/astaxie/beego/validation"
 	"github.com/beego/i18n"
 
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/markdown"
 	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/spam"
 	"github.com/varding/wetalk/modules/utils"
 	"github.com/varding/wetalk/setting"
 )
 
 type PostForm struct {
-	Lang     int            `form:"type(select);attr(rel,select2)"`
-	Topic    int            `form:"type(select);attr(rel,select2)" valid:"Required"`
-	Title    string         `form:"attr(autocomplete,off)" valid:"Required;MinSize(5);MaxSize(60)"`
-	Content  string         `form:"type(textarea)" valid:"Required;MinSize(10)"`
-	Category int            `form:"-"`
-	Topics   []models.Topic `form:"-"`
-	Locale   i18n.Locale    `form:"-"`
+	Lang      int            `form:"type(select);attr(rel,select2)"`
+	Topic     int            `form:"type(select);attr(rel,select2)" valid:"Required"`
+	Title     string         `form:"attr(autocomplete,off)" valid:"Required;MinSize(5);MaxSize(60)"`
+	Content   string         `form:"type(textarea)" valid:"Required;MinSize(10)"`
+	Category  int            `form:"-"`
+	Topics    []models.Topic `form:"-"`
+	Locale    i18n.Locale    `form:"-"`
+	Ip        string         `form:"-"`
+	UserAgent string         `form:"-"`
 }
 
 func (form *PostForm) LangSelectData() [][]string {
@@ -67,7 +72,24 @@ func (form *PostForm) Valid(v *validation.Validation) {
 	}
 }
 
+// check content then save post, return spam.ErrHeld if held for moderation
 func (form *PostForm) SavePost(post *models.Post, user *models.User) error {
+	content := spam.Content{
+		Kind:      spam.KindPost,
+		User:      user,
+		Title:     form.Title,
+		Text:      form.Content,
+		Ip:        form.Ip,
+		UserAgent: form.UserAgent,
+	}
+	held := models.HeldContent{Category: form.Category, Topic: form.Topic, Lang: form.Lang}
+	if err := spam.Inspect(&content, &held); err != nil {
+		return err
+	}
+	return form.publishPost(post, user)
+}
+
+func (form *PostForm) publishPost(post *models.Post, user *models.User) error {
 	utils.SetFormValues(form, post)
 	post.Category = &models.Category{Id: form.Category}
 	post.Topic = &models.Topic{Id: form.Topic}
@@ -75,12 +97,16 @@ func (form *PostForm) SavePost(post *models.Post, user *models.User) error {
 	post.LastReply = user
 	post.LastAuthor = user
 	post.CanEdit = true
-	post.ContentCache = utils.RenderMarkdown(form.Content)
+	post.ContentCache = markdown.RenderCached(markdown.ProfilePost, form.Content)
+	post.CacheVersion = markdown.Version(markdown.ProfilePost)
 
 	// mentioned follow users
 	FilterMentions(user, post.ContentCache)
 
-	return post.Insert()
+	if err := post.Insert(); err != nil {
+		return err
+	}
+	return attachment.LinkPostAttachments(post)
 }
 
 func (form *PostForm) SetFromPost(post *models.Post) {
@@ -89,18 +115,49 @@ func (form *PostForm) SetFromPost(post *models.Post) {
 	form.Topic = post.Topic.Id
 }
 
+// check changed title or content then update post, return spam.ErrHeld if
+// the edit is held for moderation, the post is kept unchanged then
 func (form *PostForm) UpdatePost(post *models.Post, user *models.User) error {
 	changes := utils.FormChanges(post, form)
 	if len(changes) == 0 {
 		return nil
 	}
+
+	for _, c := range changes {
+		if c != "Title" && c != "Content" {
+			continue
+		}
+		content := spam.Content{
+			Kind:      spam.KindPostEdit,
+			User:      user,
+			Title:     form.Title,
+			Text:      form.Content,
+			Link:      post.Link(),
+			Ip:        form.Ip,
+			UserAgent: form.UserAgent,
+		}
+		held := models.HeldContent{Post: post, Category: form.Category, Topic: form.Topic, Lang: form.Lang}
+		if err := spam.Inspect(&content, &held); err != nil {
+			return err
+		}
+		break
+	}
+	return form.editPost(post, user)
+}
+
+func (form *PostForm) editPost(post *models.Post, user *models.User) error {
+	changes := utils.FormChanges(post, form)
+	if len(changes) == 0 {
+		return nil
+	}
 	utils.SetFormValues(form, post)
 	post.Category.Id = form.Category
 	post.Topic.Id = form.Topic
 	for _, c := range changes {
 		if c == "Content" {
-			post.ContentCache = utils.RenderMarkdown(form.Content)
-			changes = append(changes, "ContentCache")
+			post.ContentCache = markdown.RenderCached(markdown.ProfilePost, form.Content)
+			post.CacheVersion = markdown.Version(markdown.ProfilePost)
+			changes = append(changes, "ContentCache", "CacheVersion")
 		}
 	}
 
@@ -112,7 +169,10 @@ func (form *PostForm) UpdatePost(post *models.Post, user *models.User) error {
 
 	changes = append(changes, "Updated")
 
-	return post.Update(changes...)
+	if err := post.Update(changes...); err != nil {
+		return err
+	}
+	return attachment.LinkPostAttachments(post)
 }
 
 func (form *PostForm) Placeholders() map[string]string {
@@ -138,6 +198,7 @@ type PostAdminForm struct {
 	Topic      int    `form:"type(select);attr(rel,select2)" valid:"Required"`
 	Lang       int    `form:"type(select);attr(rel,select2)"`
 	IsBest     bool   ``
+	IsHidden   bool   ``
 }
 
 func (form *PostAdminForm) Valid(v *validation.Validation) {
@@ -216,16 +277,38 @@ func (form *PostAdminForm) SetToPost(post *models.Post) {
 		}
 		post.Category.Id = topic.Category.Id
 	}
-	post.ContentCache = utils.RenderMarkdown(post.Content)
+	post.ContentCache = markdown.RenderCached(markdown.ProfilePost, post.Content)
+	post.CacheVersion = markdown.Version(markdown.ProfilePost)
 }
 
 type CommentForm struct {
-	Message string `form:"type(textarea,markdown)" valid:"Required;MinSize(5)"`
+	Message   string `form:"type(textarea,markdown)" valid:"Required;MinSize(5)"`
+	Ip        string `form:"-"`
+	UserAgent string `form:"-"`
 }
 
+// check content then save comment, return spam.ErrHeld if held for moderation
 func (form *CommentForm) SaveComment(comment *models.Comment, user *models.User, post *models.Post) error {
+	content := spam.Content{
+		Kind:      spam.KindComment,
+		User:      user,
+		Text:      form.Message,
+		Link:      post.Link(),
+		Ip:        form.Ip,
+		UserAgent: form.UserAgent,
+	}
+	// scope of held comment is the post's, for moderator permissions
+	held := models.HeldContent{Post: post, Category: post.Category.Id, Topic: post.Topic.Id}
+	if err := spam.Inspect(&content, &held); err != nil {
+		return err
+	}
+	return form.publishComment(comment, user, post)
+}
+
+func (form *CommentForm) publishComment(comment *models.Comment, user *models.User, post *models.Post) error {
 	comment.Message = form.Message
-	comment.MessageCache = utils.RenderMarkdown(form.Message)
+	comment.MessageCache = markdown.RenderCached(markdown.ProfileComment, form.Message)
+	comment.CacheVersion = markdown.Version(markdown.ProfileComment)
 	comment.User = user
 	comment.Post = post
 	if err := comment.Insert(); err == nil {
@@ -234,7 +317,10 @@ func (form *CommentForm) SaveComment(comment *models.Comment, user *models.User,
 
 		cnt, _ := post.Comments().Filter("Id__lte", comment.Id).Count()
 		comment.Floor = int(cnt)
-		return comment.Update("Floor")
+		if err := comment.Update("Floor"); err != nil {
+			return err
+		}
+		return attachment.LinkCommentAttachments(comment)
 	} else {
 		return err
 	}
@@ -243,7 +329,7 @@ func (form *CommentForm) SaveComment(comment *models.Comment, user *models.User,
 type CommentAdminForm struct {
 	Create  bool   `form:"-"`
 	User    int    `form:"attr(rel,select2-admin-model);attr(data-model,User)" valid:"Required"`
-	Post    int    `valid:"Required"`
+	Post    int    `form:"attr(rel,select2-admin-model);attr(data-model,Post)" valid:"Required"`
 	Message string `form:"type(textarea)" valid:"Required"`
 	Floor   int    `valid:"Required"`
 	Status  int    `valid:""`
@@ -286,5 +372,6 @@ func (form *CommentAdminForm) SetToComment(comment *models.Comment) {
 	}
 	comment.Post.Id = form.Post
 
-	comment.MessageCache = utils.RenderMarkdown(comment.Message)
+	comment.MessageCache = markdown.RenderCached(markdown.ProfileComment, comment.Message)
+	comment.CacheVersion = markdown.Version(markdown.ProfileComment)
 }
@@ -0,0 +1,99 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package post
+
+import (
+	"fmt"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/spam"
+	"github.com/varding/wetalk/modules/webhook"
+	"github.com/varding/wetalk/setting"
+)
+
+// publish held content after approved by moderator
+func ApproveHeldContent(held *models.HeldContent, moderator *models.User) error {
+	if held.Status != setting.HELD_PENDING {
+		return fmt.Errorf("held content %d already handled", held.Id)
+	}
+
+	user := models.User{Id: held.User.Id}
+	if err := user.Read(); err != nil {
+		return err
+	}
+
+	switch held.Kind {
+	case spam.KindPost:
+		form := PostForm{
+			Lang:     held.Lang,
+			Topic:    held.Topic,
+			Category: held.Category,
+			Title:    held.Title,
+			Content:  held.Content,
+		}
+		var post models.Post
+		if err := form.publishPost(&post, &user); err != nil {
+			return err
+		}
+		held.Object = post.Id
+		webhook.PostCreated(&post, &user)
+
+	case spam.KindPostEdit:
+		post := models.Post{Id: held.Post.Id}
+		if err := post.Read(); err != nil {
+			return err
+		}
+		form := PostForm{
+			Lang:     held.Lang,
+			Topic:    held.Topic,
+			Category: held.Category,
+			Title:    held.Title,
+			Content:  held.Content,
+		}
+		if err := form.editPost(&post, &user); err != nil {
+			return err
+		}
+		held.Object = post.Id
+		webhook.PostEdited(&post, &user)
+
+	case spam.KindComment:
+		post := models.Post{Id: held.Post.Id}
+		if err := post.Read(); err != nil {
+			return err
+		}
+		form := CommentForm{Message: held.Content}
+		var comment models.Comment
+		if err := form.publishComment(&comment, &user, &post); err != nil {
+			return err
+		}
+		held.Object = comment.Id
+		FilterCommentMentions(&user, &post, &comment)
+		webhook.CommentCreated(&comment, &post, &user)
+		PostReplysCount(&post)
+
+	default:
+		return fmt.Errorf("unknown held content kind %s", held.Kind)
+	}
+
+	return spam.SetHandled(held, moderator, setting.HELD_APPROVED)
+}
+
+// drop held content, it will never be published
+func RejectHeldContent(held *models.HeldContent, moderator *models.User) error {
+	if held.Status != setting.HELD_PENDING {
+		return fmt.Errorf("held content %d already handled", held.Id)
+	}
+	return spam.SetHandled(held, moderator, setting.HELD_REJECTED)
+}
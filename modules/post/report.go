@@ -0,0 +1,302 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package post
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/astaxie/beego/validation"
+	"github.com/beego/i18n"
+
+	"github.com/varding/wetalk/modules/ban"
+	"github.com/varding/wetalk/modules/markdown"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/perm"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+const (
+	ReportPost    = "post"
+	ReportComment = "comment"
+	ReportUser    = "user"
+)
+
+// moderator actions on a report
+const (
+	ReportResolve = "resolve"
+	ReportDismiss = "dismiss"
+	ReportHide    = "hide"
+	ReportBan     = "ban"
+)
+
+var ReportActions = []string{ReportResolve, ReportDismiss, ReportHide, ReportBan}
+
+var reportReasons = [][]string{
+	{"post.report_reason_spam", utils.ToStr(setting.REPORT_REASON_SPAM)},
+	{"post.report_reason_abuse", utils.ToStr(setting.REPORT_REASON_ABUSE)},
+	{"post.report_reason_offtopic", utils.ToStr(setting.REPORT_REASON_OFFTOPIC)},
+	{"post.report_reason_illegal", utils.ToStr(setting.REPORT_REASON_ILLEGAL)},
+	{"post.report_reason_other", utils.ToStr(setting.REPORT_REASON_OTHER)},
+}
+
+// locale key of report reason
+func ReportReasonKey(reason int) string {
+	for _, parts := range reportReasons {
+		if parts[1] == utils.ToStr(reason) {
+			return parts[0]
+		}
+	}
+	return "post.report_reason_other"
+}
+
+type ReportForm struct {
+	Reason  int    `form:"type(select)" valid:"Required"`
+	Message string `form:"type(textarea)" valid:"MaxSize(255)"`
+}
+
+func (form *ReportForm) ReasonSelectData() [][]string {
+	return reportReasons
+}
+
+func (form *ReportForm) Valid(v *validation.Validation) {
+	if form.Reason < setting.REPORT_REASON_SPAM || form.Reason > setting.REPORT_REASON_OTHER {
+		v.SetError("Reason", "post.report_reason_invalid")
+	}
+	if form.Reason == setting.REPORT_REASON_OTHER && len(form.Message) == 0 {
+		v.SetError("Message", "post.report_message_required")
+	}
+}
+
+func (form *ReportForm) Labels() map[string]string {
+	return map[string]string{
+		"Reason":  "post.report_reason",
+		"Message": "post.report_message",
+	}
+}
+
+// fill report target from kind and id, the target must exist
+func SetReportTarget(report *models.Report, kind string, id int) error {
+	report.Kind = kind
+	report.TargetId = id
+
+	switch kind {
+	case ReportPost:
+		post := models.Post{Id: id}
+		if err := post.Read(); err != nil {
+			return err
+		}
+		report.User = post.User
+		report.Title = post.Title
+		report.Uri = fmt.Sprintf("post/%d", post.Id)
+
+	case ReportComment:
+		comment := models.Comment{Id: id}
+		if err := comment.Read(); err != nil {
+			return err
+		}
+		post := models.Post{Id: comment.Post.Id}
+		if err := post.Read(); err != nil {
+			return err
+		}
+		report.User = comment.User
+		report.Title = post.Title
+		report.Uri = fmt.Sprintf("post/%d#reply%d", post.Id, comment.Floor)
+
+	case ReportUser:
+		user := models.User{Id: id}
+		if err := user.Read(); err != nil {
+			return err
+		}
+		report.User = &user
+		report.Title = user.UserName
+		report.Uri = "user/" + user.UserName
+
+	default:
+		return fmt.Errorf("unknown report kind %s", kind)
+	}
+	return nil
+}
+
+// user already has an open report on the target
+func HasOpenReport(reporter *models.User, kind string, id int) bool {
+	cnt, _ := models.Reports().Filter("Reporter", reporter.Id).Filter("Kind", kind).
+		Filter("TargetId", id).Filter("Status", setting.REPORT_OPEN).Count()
+	return cnt > 0
+}
+
+func (form *ReportForm) SaveReport(report *models.Report, reporter *models.User) error {
+	report.Reporter = reporter
+	report.Reason = form.Reason
+	report.Message = form.Message
+	report.Status = setting.REPORT_OPEN
+	return report.Insert()
+}
+
+// scope of reported content, users are site wide
+func ReportScope(report *models.Report) perm.Scope {
+	postId := 0
+	switch report.Kind {
+	case ReportPost:
+		postId = report.TargetId
+	case ReportComment:
+		comment := models.Comment{Id: report.TargetId}
+		if err := comment.Read(); err == nil {
+			postId = comment.Post.Id
+		}
+	}
+
+	post := models.Post{Id: postId}
+	if postId == 0 || post.Read() != nil {
+		return perm.Global
+	}
+	return perm.ScopeOfPost(&post)
+}
+
+// check user can take the action on report
+func CanHandleReport(user *models.User, report *models.Report, action string) bool {
+	scope := ReportScope(report)
+	if !perm.Can(user, perm.ReportHandle, scope) {
+		return false
+	}
+
+	switch action {
+	case ReportHide:
+		if report.Kind == ReportComment {
+			return perm.Can(user, perm.CommentHide, scope)
+		}
+		return perm.Can(user, perm.PostHide, scope)
+	case ReportBan:
+		return perm.Can(user, perm.UserBan, perm.Global)
+	}
+	return true
+}
+
+// handle a report with moderator action, hide and ban also resolve the report
+func HandleReport(report *models.Report, handler *models.User, action, note string) error {
+	status := setting.REPORT_RESOLVED
+
+	switch action {
+	case ReportResolve:
+	case ReportDismiss:
+		status = setting.REPORT_DISMISSED
+	case ReportHide:
+		if err := hideReportTarget(report); err != nil {
+			return err
+		}
+	case ReportBan:
+		if err := banReportedUser(report, handler, note); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown report action %s", action)
+	}
+
+	report.Status = status
+	report.Action = action
+	report.Handler = handler
+	report.Handled = time.Now()
+	report.Note += fmt.Sprintf("[%s] %s %s: %s\n",
+		report.Handled.Format("2006-01-02 15:04:05"), handler.UserName, action, note)
+
+	if err := report.Update("Status", "Action", "Handler", "Handled", "Note"); err != nil {
+		return err
+	}
+
+	notifyReporter(report, handler)
+	return nil
+}
+
+func hideReportTarget(report *models.Report) error {
+	switch report.Kind {
+	case ReportPost:
+		post := models.Post{Id: report.TargetId}
+		if err := post.Read(); err != nil {
+			return err
+		}
+		post.IsHidden = true
+		return post.Update("IsHidden")
+
+	case ReportComment:
+		comment := models.Comment{Id: report.TargetId}
+		if err := comment.Read(); err != nil {
+			return err
+		}
+		comment.Status = setting.COMMENT_STATUS_HIDDEN
+		return comment.Update("Status")
+	}
+	return fmt.Errorf("report kind %s can not be hidden", report.Kind)
+}
+
+// suspend reported user permanently, note is the ban reason
+func banReportedUser(report *models.Report, handler *models.User, note string) error {
+	user := models.User{Id: report.User.Id}
+	if err := user.Read(); err != nil {
+		return err
+	}
+	if len(note) == 0 {
+		note = i18n.Tr(setting.Langs[setting.DefaultLang], ReportReasonKey(report.Reason))
+	}
+	_, err := ban.BanUser(&user, handler, setting.BAN_SUSPEND, 0, note)
+	return err
+}
+
+// tell the reporter the report has been handled
+func notifyReporter(report *models.Report, handler *models.User) {
+	reporter := models.User{Id: report.Reporter.Id}
+	if err := reporter.Read(); err != nil {
+		return
+	}
+
+	lang := reporter.Lang
+	if lang < 0 || lang >= len(setting.Langs) {
+		lang = setting.DefaultLang
+	}
+	content := i18n.Tr(setting.Langs[lang], "post.report_handled_"+report.Action)
+
+	// notification uri only holds short links
+	var uri string
+	var floor int
+	switch report.Kind {
+	case ReportPost:
+		uri = fmt.Sprintf("post/%d", report.TargetId)
+	case ReportComment:
+		comment := models.Comment{Id: report.TargetId}
+		if err := comment.Read(); err == nil {
+			uri = fmt.Sprintf("post/%d", comment.Post.Id)
+			floor = comment.Floor
+		}
+	case ReportUser:
+		if len(report.Uri) <= 20 {
+			uri = report.Uri
+		}
+	}
+
+	notification := models.Notification{
+		FromUser:     handler,
+		ToUser:       &reporter,
+		Action:       setting.NOTICE_TYPE_REPORT,
+		Title:        report.Title,
+		TargetId:     report.Id,
+		Uri:          uri,
+		Lang:         reporter.Lang,
+		Floor:        floor,
+		Content:      content,
+		ContentCache: markdown.Render(markdown.ProfileNotification, content),
+		Status:       setting.NOTICE_UNREAD,
+	}
+	notification.Insert()
+}
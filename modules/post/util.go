@@ -28,11 +28,11 @@ import (
 )
 
 func ListPostsOfCategory(cat *models.Category, posts *[]models.Post) (int64, error) {
-	return models.Posts().Filter("Category", cat).RelatedSel().OrderBy("-Updated").All(posts)
+	return models.PublicPosts().Filter("Category", cat).RelatedSel().OrderBy("-Updated").All(posts)
 }
 
 func ListPostsOfTopic(topic *models.Topic, posts *[]models.Post) (int64, error) {
-	return models.Posts().Filter("Topic", topic).RelatedSel().OrderBy("-Updated").All(posts)
+	return models.PublicPosts().Filter("Topic", topic).RelatedSel().OrderBy("-Updated").All(posts)
 }
 
 var mentionRegexp = regexp.MustCompile(`\B@([\d\w-_]*)`)
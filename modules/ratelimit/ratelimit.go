@@ -0,0 +1,231 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package ratelimit implemented token bucket limits of write actions,
+// fixed windows are counted instead with shared caches.
+package ratelimit
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/cache"
+	"github.com/astaxie/beego/context"
+
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+const (
+	ActionPost     = "post"
+	ActionComment  = "comment"
+	ActionUpload   = "upload"
+	ActionRegister = "register"
+	ActionApi      = "api"
+	ActionReport   = "report"
+	ActionPlay     = "playground"
+)
+
+// Burst requests are allowed in Period, tokens refill evenly
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// parse limit from "<requests>/<seconds>"
+func ParseLimit(value string) (Limit, error) {
+	var limit Limit
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return limit, fmt.Errorf("invalid rate limit %q", value)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || burst <= 0 {
+		return limit, fmt.Errorf("invalid rate limit %q", value)
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || seconds <= 0 {
+		return limit, fmt.Errorf("invalid rate limit %q", value)
+	}
+	limit.Burst = burst
+	limit.Period = time.Duration(seconds) * time.Second
+	return limit, nil
+}
+
+// get configured limit of action, ip buckets use "<action>_ip" if exist
+func GetLimit(action string, ip bool) (Limit, bool) {
+	value, ok := "", false
+	if ip {
+		value, ok = setting.RateLimits[action+"_ip"]
+	}
+	if !ok {
+		value, ok = setting.RateLimits[action]
+	}
+	if !ok {
+		return Limit{}, false
+	}
+	limit, err := ParseLimit(value)
+	if err != nil {
+		beego.Error("RateLimit: ", err)
+		return limit, false
+	}
+	return limit, true
+}
+
+// buckets in memory cache are locked by shards of their keys
+var locks [64]sync.Mutex
+
+func keyLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &locks[h.Sum32()%uint32(len(locks))]
+}
+
+// take one token from bucket of key, return the wait time if limit is reached
+// buckets are kept in memory cache, shared caches count requests of fixed
+// windows by atomic Incr instead, app instances share them without locks
+func Take(key string, limit Limit) (bool, time.Duration) {
+	if _, ok := setting.Cache.(*cache.MemoryCache); ok {
+		return takeBucket(key, limit)
+	}
+	return takeWindow(key, limit)
+}
+
+// token bucket is saved in setting.Cache as "<tokens>|<unix nano of last take>"
+func takeBucket(key string, limit Limit) (bool, time.Duration) {
+	lock := keyLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	now := time.Now()
+	rate := float64(limit.Burst) / limit.Period.Seconds()
+	tokens := float64(limit.Burst)
+
+	if v, ok := setting.Cache.Get(key).(string); ok {
+		parts := strings.Split(v, "|")
+		if len(parts) == 2 {
+			t, _ := strconv.ParseFloat(parts[0], 64)
+			last, _ := strconv.ParseInt(parts[1], 10, 64)
+			elapsed := now.Sub(time.Unix(0, last)).Seconds()
+			tokens = math.Min(float64(limit.Burst), t+elapsed*rate)
+		}
+	}
+
+	if tokens < 1 {
+		wait := time.Duration((1 - tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+
+	tokens -= 1
+	value := strconv.FormatFloat(tokens, 'f', 4, 64) + "|" + utils.ToStr(now.UnixNano())
+	// a bucket untouched for a whole period is full again, let it expire
+	setting.Cache.Put(key, value, int64(limit.Period.Seconds())+1)
+	return true, 0
+}
+
+// count of window is saved in setting.Cache as "<key>.<window>"
+func takeWindow(key string, limit Limit) (bool, time.Duration) {
+	now := time.Now()
+	window := now.UnixNano() / int64(limit.Period)
+	key += "." + strconv.FormatInt(window, 10)
+
+	// concurrent first requests of window may both save 1, others are counted by Incr
+	count := 1
+	if setting.Cache.Get(key) == nil {
+		setting.Cache.Put(key, count, int64(limit.Period.Seconds())+1)
+	} else {
+		if err := setting.Cache.Incr(key); err != nil {
+			beego.Error("RateLimit: ", err)
+			return true, 0
+		}
+		count, _ = setting.Cache.Get(key).(int)
+	}
+
+	if count > limit.Burst {
+		return false, time.Unix(0, (window+1)*int64(limit.Period)).Sub(now)
+	}
+	return true, 0
+}
+
+// check action limits of user and ip, userId 0 means anonymous
+func Allow(action string, userId int, ip string) (bool, time.Duration) {
+	if !setting.RateLimitEnabled {
+		return true, 0
+	}
+
+	if limit, ok := GetLimit(action, true); ok && len(ip) > 0 {
+		if ok, wait := Take(fmt.Sprintf("RL.%s.ip.%s", action, ip), limit); !ok {
+			return false, wait
+		}
+	}
+
+	if limit, ok := GetLimit(action, false); ok && userId > 0 {
+		if ok, wait := Take(fmt.Sprintf("RL.%s.u.%d", action, userId), limit); !ok {
+			return false, wait
+		}
+	}
+
+	return true, 0
+}
+
+// write 429 response with Retry-After header
+func Deny(ctx *context.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	ctx.Output.Header("Retry-After", utils.ToStr(seconds))
+	ctx.Output.SetStatus(429)
+
+	if ctx.Input.Header("X-Requested-With") == "XMLHttpRequest" || strings.Contains(ctx.Input.Header("Accept"), "json") {
+		ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+		ctx.WriteString(fmt.Sprintf(`{"success":false,"error":"rate_limited","retry_after":%d}`, seconds))
+	} else {
+		ctx.Output.Header("Content-Type", "text/plain; charset=utf-8")
+		ctx.WriteString(fmt.Sprintf("Too Many Requests, retry after %d seconds", seconds))
+	}
+}
+
+// beego filter limits non GET requests of action by ip and session user,
+// paths in except are skipped, they are limited by their own actions
+func Filter(action string, except ...string) func(ctx *context.Context) {
+	return func(ctx *context.Context) {
+		switch ctx.Request.Method {
+		case "GET", "HEAD", "OPTIONS":
+			return
+		}
+
+		path := strings.TrimSuffix(ctx.Request.URL.Path, "/")
+		for _, p := range except {
+			if path == p {
+				return
+			}
+		}
+
+		var userId int
+		if ctx.Input.CruSession != nil {
+			userId, _ = ctx.Input.CruSession.Get("auth_user_id").(int)
+		}
+
+		if ok, wait := Allow(action, userId, ctx.Input.IP()); !ok {
+			Deny(ctx, wait)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package spam
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/setting"
+)
+
+// akismet compatible http checker, the endpoint can point to a local stub
+// spam is held, "X-akismet-pro-tip: discard" is rejected, errors pass
+type AkismetChecker struct{}
+
+func (c *AkismetChecker) Name() string {
+	return "akismet"
+}
+
+func (c *AkismetChecker) Check(content *Content) Result {
+	if len(setting.AkismetKey) == 0 {
+		return Result{Verdict: Pass}
+	}
+
+	spam, discard, err := AkismetCheck(setting.AkismetEndpoint, setting.AkismetKey, content)
+	if err != nil {
+		beego.Error("Spam: akismet ", err)
+		return Result{Verdict: Pass}
+	}
+
+	switch {
+	case discard:
+		return Result{Verdict: Reject, Reason: "akismet discard"}
+	case spam:
+		return Result{Verdict: Hold, Reason: "akismet spam"}
+	}
+	return Result{Verdict: Pass}
+}
+
+// send comment-check request, return whether content is spam and should be discarded
+func AkismetCheck(endpoint, key string, content *Content) (spam bool, discard bool, err error) {
+	commentType := "forum-post"
+	if content.Kind == KindComment {
+		commentType = "reply"
+	}
+
+	values := url.Values{
+		"api_key":              {key},
+		"blog":                 {setting.AppUrl},
+		"user_ip":              {content.Ip},
+		"user_agent":           {content.UserAgent},
+		"permalink":            {content.Link},
+		"comment_type":         {commentType},
+		"comment_author":       {content.User.UserName},
+		"comment_author_email": {content.User.Email},
+		"comment_author_url":   {content.User.Url},
+		"comment_content":      {content.Title + "\n" + content.Text},
+		"comment_date_gmt":     {time.Now().UTC().Format(time.RFC3339)},
+	}
+
+	client := http.Client{Timeout: time.Duration(setting.AkismetTimeout) * time.Second}
+	resp, err := client.PostForm(endpoint, values)
+	if err != nil {
+		return false, false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, false, err
+	}
+
+	switch strings.TrimSpace(string(body)) {
+	case "true":
+		return true, resp.Header.Get("X-akismet-pro-tip") == "discard", nil
+	case "false":
+		return false, false, nil
+	}
+	return false, false, fmt.Errorf("unexpected response %q %s", body, resp.Header.Get("X-akismet-debug-help"))
+}
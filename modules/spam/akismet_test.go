@@ -0,0 +1,72 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package spam
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/varding/wetalk/modules/models"
+	. "github.com/varding/wetalk/modules/utils"
+)
+
+// local akismet stub, content "viagra" is spam and "discard" is blatant spam
+func akismetStub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("api_key") != "key" {
+			w.Header().Set("X-akismet-debug-help", "invalid key")
+			w.Write([]byte("invalid"))
+			return
+		}
+		switch r.FormValue("comment_content") {
+		case "\ndiscard":
+			w.Header().Set("X-akismet-pro-tip", "discard")
+			w.Write([]byte("true"))
+		case "\nviagra":
+			w.Write([]byte("true"))
+		default:
+			w.Write([]byte("false"))
+		}
+	}))
+}
+
+func TestAkismetCheck(t *testing.T) {
+	ts := akismetStub()
+	defer ts.Close()
+
+	content := &Content{Kind: KindPost, User: &models.User{UserName: "wetalk"}}
+
+	content.Text = "hello gopher"
+	spam, discard, err := AkismetCheck(ts.URL, "key", content)
+	ThrowFailNow(t, err)
+	ThrowFail(t, AssertIs(spam, false))
+	ThrowFail(t, AssertIs(discard, false))
+
+	content.Text = "viagra"
+	spam, discard, err = AkismetCheck(ts.URL, "key", content)
+	ThrowFailNow(t, err)
+	ThrowFail(t, AssertIs(spam, true))
+	ThrowFail(t, AssertIs(discard, false))
+
+	content.Text = "discard"
+	spam, discard, err = AkismetCheck(ts.URL, "key", content)
+	ThrowFailNow(t, err)
+	ThrowFail(t, AssertIs(spam, true))
+	ThrowFail(t, AssertIs(discard, true))
+
+	_, _, err = AkismetCheck(ts.URL, "wrong", content)
+	ThrowFail(t, AssertIs(err != nil, true))
+}
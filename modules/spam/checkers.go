@@ -0,0 +1,197 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package spam
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+func init() {
+	blocklist = new(BlocklistChecker)
+	Register(blocklist)
+	Register(new(LinkChecker))
+	Register(new(DuplicateChecker))
+	Register(new(AkismetChecker))
+}
+
+type compiledRule struct {
+	rule   models.SpamRule
+	regexp *regexp.Regexp
+}
+
+// match content with admin managed keyword and regexp rules
+type BlocklistChecker struct {
+	lock   sync.RWMutex
+	loaded bool
+	rules  []compiledRule
+}
+
+var blocklist *BlocklistChecker
+
+// rules will be reloaded on next check, call after rules changed
+func ReloadRules() {
+	if blocklist != nil {
+		blocklist.lock.Lock()
+		blocklist.loaded = false
+		blocklist.lock.Unlock()
+	}
+}
+
+func (c *BlocklistChecker) Name() string {
+	return "blocklist"
+}
+
+func (c *BlocklistChecker) load() {
+	var rules []models.SpamRule
+	if _, err := models.SpamRules().Filter("IsActive", true).All(&rules); err != nil {
+		beego.Error("Spam: load rules ", err)
+		return
+	}
+
+	c.rules = make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		r := compiledRule{rule: rule}
+		if rule.IsRegex {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				beego.Error("Spam: rule ", rule.Id, " ", err)
+				continue
+			}
+			r.regexp = re
+		} else {
+			r.rule.Pattern = strings.ToLower(rule.Pattern)
+		}
+		c.rules = append(c.rules, r)
+	}
+	c.loaded = true
+}
+
+func (c *BlocklistChecker) Check(content *Content) Result {
+	c.lock.RLock()
+	if !c.loaded {
+		c.lock.RUnlock()
+		c.lock.Lock()
+		if !c.loaded {
+			c.load()
+		}
+		c.lock.Unlock()
+		c.lock.RLock()
+	}
+	defer c.lock.RUnlock()
+
+	text := content.Title + "\n" + content.Text
+	lower := strings.ToLower(text)
+
+	result := Result{Verdict: Pass}
+	for _, r := range c.rules {
+		var matched bool
+		if r.regexp != nil {
+			matched = r.regexp.MatchString(text)
+		} else {
+			matched = strings.Contains(lower, r.rule.Pattern)
+		}
+		if !matched {
+			continue
+		}
+		reason := fmt.Sprintf("matched rule %d", r.rule.Id)
+		if r.rule.Action == setting.SPAM_ACTION_REJECT {
+			return Result{Verdict: Reject, Reason: reason}
+		}
+		if result.Verdict == Pass {
+			result = Result{Verdict: Hold, Reason: reason}
+		}
+	}
+	return result
+}
+
+var linkRegexp = regexp.MustCompile(`(?i)\bhttps?://`)
+
+// hold content with too many links, new users have a lower limit
+type LinkChecker struct{}
+
+func (c *LinkChecker) Name() string {
+	return "links"
+}
+
+func IsNewUser(user *models.User) bool {
+	return time.Since(user.Created) < time.Duration(setting.SpamNewUserHours)*time.Hour
+}
+
+func (c *LinkChecker) Check(content *Content) Result {
+	links := len(linkRegexp.FindAllStringIndex(content.Text, -1))
+
+	max := setting.SpamMaxLinks
+	if IsNewUser(content.User) {
+		max = setting.SpamNewUserMaxLinks
+		if links > max {
+			return Result{Verdict: Hold, Reason: fmt.Sprintf("new user posted %d links", links)}
+		}
+	}
+
+	if links > max {
+		return Result{Verdict: Hold, Reason: fmt.Sprintf("%d links", links)}
+	}
+	return Result{Verdict: Pass}
+}
+
+var spaceRegexp = regexp.MustCompile(`\s+`)
+
+// hash of content with case and whitespace normalized
+func ContentHash(c *Content) string {
+	text := strings.ToLower(strings.TrimSpace(c.Title + " " + c.Text))
+	text = spaceRegexp.ReplaceAllString(text, " ")
+	h := sha1.New()
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// save content hash for duplicate detection
+func RecordHash(c *Content) {
+	hash := models.ContentHash{User: c.User, Hash: ContentHash(c)}
+	if err := hash.Insert(); err != nil {
+		beego.Error("Spam: record hash ", err)
+	}
+}
+
+// reject content repeated by the same user, hold content copied from others
+type DuplicateChecker struct{}
+
+func (c *DuplicateChecker) Name() string {
+	return "duplicate"
+}
+
+func (c *DuplicateChecker) Check(content *Content) Result {
+	since := time.Now().Add(-time.Duration(setting.SpamDuplicateHours) * time.Hour)
+	qs := models.ContentHashes().Filter("Hash", ContentHash(content)).Filter("Created__gte", since)
+
+	if cnt, _ := qs.Filter("User", content.User.Id).Count(); cnt > 0 {
+		return Result{Verdict: Reject, Reason: "duplicate of own content"}
+	}
+	if cnt, _ := qs.Count(); cnt > 0 {
+		return Result{Verdict: Hold, Reason: "duplicate of other's content"}
+	}
+	return Result{Verdict: Pass}
+}
@@ -0,0 +1,76 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package spam
+
+import (
+	"regexp"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+type SpamRuleAdminForm struct {
+	Create   bool   `form:"-"`
+	Id       int    `form:"-"`
+	Pattern  string `valid:"Required;MaxSize(200)"`
+	IsRegex  bool   ``
+	Action   int    `form:"type(select);attr(rel,select2)" valid:"Required"`
+	IsActive bool   ``
+}
+
+func (form *SpamRuleAdminForm) ActionSelectData() [][]string {
+	return [][]string{
+		[]string{"model.spamrule_action_hold", utils.ToStr(setting.SPAM_ACTION_HOLD)},
+		[]string{"model.spamrule_action_reject", utils.ToStr(setting.SPAM_ACTION_REJECT)},
+	}
+}
+
+func (form *SpamRuleAdminForm) Valid(v *validation.Validation) {
+	if form.IsRegex {
+		if _, err := regexp.Compile(form.Pattern); err != nil {
+			v.SetError("Pattern", "admin.spamrule_invalid_regexp")
+		}
+	}
+
+	if form.Action != setting.SPAM_ACTION_HOLD && form.Action != setting.SPAM_ACTION_REJECT {
+		v.SetError("Action", "error")
+	}
+}
+
+func (form *SpamRuleAdminForm) Labels() map[string]string {
+	return map[string]string{
+		"Pattern":  "model.spamrule_pattern",
+		"IsRegex":  "model.spamrule_isregex",
+		"Action":   "model.spamrule_action",
+		"IsActive": "model.spamrule_isactive",
+	}
+}
+
+func (form *SpamRuleAdminForm) Helps() map[string]string {
+	return map[string]string{
+		"Pattern": "model.spamrule_pattern_help",
+	}
+}
+
+func (form *SpamRuleAdminForm) SetFromSpamRule(rule *models.SpamRule) {
+	utils.SetFormValues(rule, form)
+}
+
+func (form *SpamRuleAdminForm) SetToSpamRule(rule *models.SpamRule) {
+	utils.SetFormValues(form, rule, "Id")
+}
@@ -0,0 +1,144 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package spam implemented content check pipeline of new posts and comments.
+package spam
+
+import (
+	"errors"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+const (
+	KindPost     = "post"
+	KindComment  = "comment"
+	KindPostEdit = "post_edit"
+)
+
+type Verdict int
+
+const (
+	Pass Verdict = iota
+	Hold
+	Reject
+)
+
+var (
+	ErrHeld     = errors.New("content held for moderation")
+	ErrRejected = errors.New("content rejected")
+)
+
+// content to check
+// Link: permalink of the post, empty when creating a post
+// Kind post_edit is a changed title or content of the post in Link
+type Content struct {
+	Kind      string
+	User      *models.User
+	Title     string
+	Text      string
+	Link      string
+	Ip        string
+	UserAgent string
+}
+
+type Result struct {
+	Verdict Verdict
+	Checker string
+	Reason  string
+}
+
+type Checker interface {
+	Name() string
+	Check(c *Content) Result
+}
+
+var checkers []Checker
+
+// register a checker into pipeline, checkers run in registered order
+func Register(checker Checker) {
+	checkers = append(checkers, checker)
+}
+
+// run all checkers, reject stop the pipeline at once and hold wins pass
+func Check(c *Content) Result {
+	result := Result{Verdict: Pass}
+	for _, checker := range checkers {
+		r := checker.Check(c)
+		if r.Verdict == Pass {
+			continue
+		}
+		r.Checker = checker.Name()
+		if r.Verdict == Reject {
+			return r
+		}
+		if result.Verdict == Pass {
+			result = r
+		}
+	}
+	return result
+}
+
+// check content before publish, held content is saved into moderation queue
+// held carries the extra fields needed to publish it later
+func Inspect(c *Content, held *models.HeldContent) error {
+	if !setting.SpamEnabled || c.User.IsAdmin {
+		return nil
+	}
+
+	result := Check(c)
+	switch result.Verdict {
+	case Reject:
+		beego.Info("Spam: rejected ", c.Kind, " of user ", c.User.Id, " by ", result.Checker, ": ", result.Reason)
+		return ErrRejected
+	case Hold:
+		held.Kind = c.Kind
+		held.User = c.User
+		held.Title = c.Title
+		held.Content = c.Text
+		held.Ip = c.Ip
+		held.UserAgent = c.UserAgent
+		held.Checker = result.Checker
+		held.Reason = result.Reason
+		held.Status = setting.HELD_PENDING
+		if err := held.Insert(); err != nil {
+			return err
+		}
+	}
+
+	RecordHash(c)
+
+	if result.Verdict == Hold {
+		return ErrHeld
+	}
+	return nil
+}
+
+// count pending content in moderation queue
+func CountPending() int64 {
+	cnt, _ := models.HeldContents().Filter("Status", setting.HELD_PENDING).Count()
+	return cnt
+}
+
+// mark held content handled by moderator
+func SetHandled(held *models.HeldContent, moderator *models.User, status int) error {
+	held.Status = status
+	held.Moderator = moderator
+	held.Handled = time.Now()
+	return held.Update("Status", "Object", "Moderator", "Handled")
+}
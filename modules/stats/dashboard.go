@@ -0,0 +1,150 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package stats
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// value of one day in a series
+// Percent: height in chart relative to the max value
+type Point struct {
+	Day     time.Time
+	Value   int
+	Percent int
+}
+
+// Name: locale key of the series
+type Series struct {
+	Name    string
+	Total   int
+	Average int
+	Points  []Point
+}
+
+// topic or category ordered by activity
+type Rank struct {
+	Name     string
+	Link     string
+	Posts    int
+	Comments int
+}
+
+type Dashboard struct {
+	Days          int
+	Series        []*Series
+	TopTopics     []Rank
+	TopCategories []Rank
+	Storage       int64
+	Updated       time.Time
+}
+
+func newSeries(name string, stats []models.DailyStat, value func(*models.DailyStat) int) *Series {
+	series := &Series{Name: name, Points: make([]Point, 0, len(stats))}
+
+	max := 0
+	for i := range stats {
+		v := value(&stats[i])
+		series.Total += v
+		if v > max {
+			max = v
+		}
+		series.Points = append(series.Points, Point{Day: stats[i].Day, Value: v})
+	}
+
+	if len(stats) > 0 {
+		series.Average = series.Total / len(stats)
+	}
+	if max > 0 {
+		for i := range series.Points {
+			series.Points[i].Percent = series.Points[i].Value * 100 / max
+		}
+	}
+	return series
+}
+
+// LoadDashboard reads statistics of last days from aggregate tables
+func LoadDashboard(days int) (*Dashboard, error) {
+	start := dayStart(time.Now()).AddDate(0, 0, 1-days)
+	dash := &Dashboard{Days: days}
+
+	var stats []models.DailyStat
+	if _, err := models.DailyStats().Filter("Day__gte", start).Limit(days).All(&stats); err != nil {
+		return nil, err
+	}
+
+	dash.Series = []*Series{
+		newSeries("admin.stats_registrations", stats, func(s *models.DailyStat) int { return s.Registrations }),
+		newSeries("admin.stats_posts", stats, func(s *models.DailyStat) int { return s.Posts }),
+		newSeries("admin.stats_comments", stats, func(s *models.DailyStat) int { return s.Comments }),
+		newSeries("admin.stats_active_users", stats, func(s *models.DailyStat) int { return s.ActiveUsers }),
+		newSeries("admin.stats_views", stats, func(s *models.DailyStat) int { return s.Views }),
+		newSeries("admin.stats_images", stats, func(s *models.DailyStat) int { return s.Images }),
+	}
+
+	if len(stats) > 0 {
+		last := stats[len(stats)-1]
+		dash.Storage = last.Storage
+		dash.Updated = last.Updated
+	}
+
+	var err error
+	if dash.TopTopics, err = topRanks("topic_id", start); err != nil {
+		return nil, err
+	}
+	if dash.TopCategories, err = topRanks("category_id", start); err != nil {
+		return nil, err
+	}
+	return dash, nil
+}
+
+// most active topics or categories since start
+func topRanks(column string, start time.Time) ([]Rank, error) {
+	var lists []orm.ParamsList
+	_, err := orm.NewOrm().Raw("SELECT "+column+", SUM(posts), SUM(comments) FROM daily_topic_stat "+
+		"WHERE day >= ? GROUP BY "+column+" ORDER BY SUM(posts) + SUM(comments) DESC LIMIT 10", start).ValuesList(&lists)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := make([]Rank, 0, len(lists))
+	for _, list := range lists {
+		id, _ := utils.StrTo(utils.ToStr(list[0])).Int()
+		rank := Rank{}
+		rank.Posts, _ = utils.StrTo(utils.ToStr(list[1])).Int()
+		rank.Comments, _ = utils.StrTo(utils.ToStr(list[2])).Int()
+
+		if column == "topic_id" {
+			topic := models.Topic{Id: id}
+			if topic.Read() != nil {
+				continue
+			}
+			rank.Name, rank.Link = topic.Name, topic.Link()
+		} else {
+			cat := models.Category{Id: id}
+			if cat.Read() != nil {
+				continue
+			}
+			rank.Name, rank.Link = cat.Name, cat.Link()
+		}
+		ranks = append(ranks, rank)
+	}
+	return ranks, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package stats aggregates daily site statistics for admin dashboard.
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// local dir of uploaded files
+const uploadPath = "upload"
+
+// start time of the day
+func dayStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func countCreated(qs orm.QuerySeter, start, end time.Time) int {
+	cnt, err := qs.Filter("Created__gte", start).Filter("Created__lt", end).Count()
+	if err != nil {
+		beego.Error("Stats: ", err)
+	}
+	return int(cnt)
+}
+
+// users posted or commented in the time range
+func countActiveUsers(start, end time.Time) int {
+	var cnt int
+	err := orm.NewOrm().Raw("SELECT COUNT(DISTINCT user_id) FROM ("+
+		"SELECT user_id FROM post WHERE created >= ? AND created < ? UNION "+
+		"SELECT user_id FROM comment WHERE created >= ? AND created < ?) active",
+		start, end, start, end).QueryRow(&cnt)
+	if err != nil {
+		beego.Error("Stats: ", err)
+	}
+	return cnt
+}
+
+func totalViews() int64 {
+	var total int64
+	if err := orm.NewOrm().Raw("SELECT COALESCE(SUM(browsers), 0) FROM post").QueryRow(&total); err != nil {
+		beego.Error("Stats: ", err)
+	}
+	return total
+}
+
+func storageSize() int64 {
+	var size int64
+	filepath.Walk(uploadPath, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// Aggregate computes statistics of the day, the old result is replaced
+func Aggregate(day time.Time) error {
+	start := dayStart(day)
+	end := start.AddDate(0, 0, 1)
+
+	stat := models.DailyStat{Day: start}
+	exists := stat.Read("Day") == nil
+
+	stat.Registrations = countCreated(models.Users(), start, end)
+	stat.Posts = countCreated(models.Posts(), start, end)
+	stat.Comments = countCreated(models.Comments(), start, end)
+	stat.Images = countCreated(models.Images(), start, end)
+	stat.ActiveUsers = countActiveUsers(start, end)
+
+	// views and storage can only be measured at present,
+	// views of today are counted from the last snapshot of yesterday
+	if start.Equal(dayStart(time.Now())) {
+		stat.TotalViews = totalViews()
+		stat.Storage = storageSize()
+
+		prev := models.DailyStat{Day: start.AddDate(0, 0, -1)}
+		if prev.Read("Day") == nil && prev.TotalViews > 0 && stat.TotalViews > prev.TotalViews {
+			stat.Views = int(stat.TotalViews - prev.TotalViews)
+		}
+	}
+
+	var err error
+	if exists {
+		err = stat.Update()
+	} else {
+		err = stat.Insert()
+	}
+	if err != nil {
+		return err
+	}
+
+	return aggregateTopics(start, end)
+}
+
+// count posts and comments of each topic in the day
+func aggregateTopics(start, end time.Time) error {
+	o := orm.NewOrm()
+
+	queries := []string{
+		"SELECT topic_id, category_id, COUNT(*) FROM post WHERE created >= ? AND created < ? GROUP BY topic_id, category_id",
+		"SELECT p.topic_id, p.category_id, COUNT(*) FROM comment c INNER JOIN post p ON p.id = c.post_id " +
+			"WHERE c.created >= ? AND c.created < ? GROUP BY p.topic_id, p.category_id",
+	}
+
+	topics := make(map[int]*models.DailyTopicStat)
+	for i, query := range queries {
+		var lists []orm.ParamsList
+		if _, err := o.Raw(query, start, end).ValuesList(&lists); err != nil {
+			return err
+		}
+		for _, list := range lists {
+			topicId, _ := utils.StrTo(utils.ToStr(list[0])).Int()
+			categoryId, _ := utils.StrTo(utils.ToStr(list[1])).Int()
+			cnt, _ := utils.StrTo(utils.ToStr(list[2])).Int()
+
+			stat, ok := topics[topicId]
+			if !ok {
+				stat = &models.DailyTopicStat{
+					Day:      start,
+					Topic:    &models.Topic{Id: topicId},
+					Category: &models.Category{Id: categoryId},
+				}
+				topics[topicId] = stat
+			}
+			if i == 0 {
+				stat.Posts += cnt
+			} else {
+				stat.Comments += cnt
+			}
+		}
+	}
+
+	if _, err := models.DailyTopicStats().Filter("Day", start).Delete(); err != nil {
+		return err
+	}
+	for _, stat := range topics {
+		if err := stat.Insert(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run aggregates today and yesterday, past days are filled when no statistics yet
+func Run() {
+	days := 1
+	if cnt, err := models.DailyStats().Count(); err == nil && cnt == 0 {
+		days = setting.StatsBackfillDays
+	}
+
+	now := time.Now()
+	for i := days; i >= 0; i-- {
+		if err := Aggregate(now.AddDate(0, 0, -i)); err != nil {
+			beego.Error("Stats: ", err)
+		}
+	}
+}
+
+// start background worker for aggregating statistics
+func StartWorker() {
+	go func() {
+		for {
+			Run()
+
+			interval := setting.StatsInterval
+			if interval <= 0 {
+				interval = 60
+			}
+			time.Sleep(time.Duration(interval) * time.Minute)
+		}
+	}()
+}
@@ -0,0 +1,96 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Local stores files in a directory of local disk,
+// they are served by wetalk under /files/.
+type Local struct {
+	Root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{Root: root}
+}
+
+// Path returns file path of key relative to working directory.
+func (s *Local) Path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(cleanKey(key)))
+}
+
+func (s *Local) Put(key string, r io.Reader, size int64, mime string) error {
+	filePath := s.Path(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(file, r); err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+
+	if err != nil {
+		os.Remove(filePath)
+	}
+	return err
+}
+
+func (s *Local) Get(key string) (io.ReadCloser, error) {
+	file, err := os.Open(s.Path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	return file, err
+}
+
+func (s *Local) Delete(key string) error {
+	if err := os.Remove(s.Path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Local) URL(key string) string {
+	return "/files/" + cleanKey(key)
+}
+
+func (s *Local) Stat(key string) (*FileInfo, error) {
+	fi, err := os.Stat(s.Path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &FileInfo{
+		Key:      key,
+		Size:     fi.Size(),
+		Mime:     mime.TypeByExtension(path.Ext(key)),
+		Modified: fi.ModTime(),
+	}, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/qiniu/api.v6/conf"
+	qio "github.com/qiniu/api.v6/io"
+	"github.com/qiniu/api.v6/rs"
+
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// Qiniu stores files in a public bucket of qiniu.com.
+type Qiniu struct {
+	Bucket string
+	Domain string
+}
+
+func NewQiniu(accessKey, secretKey, bucket, domain string) *Qiniu {
+	conf.ACCESS_KEY = accessKey
+	conf.SECRET_KEY = secretKey
+	return &Qiniu{Bucket: bucket, Domain: domain}
+}
+
+func (s *Qiniu) Put(key string, r io.Reader, size int64, mime string) error {
+	var putRet qio.PutRet
+	var putExtra = &qio.PutExtra{
+		MimeType: mime,
+	}
+	// bucket:key scope allows replacing the exist file
+	uptoken := utils.GetQiniuUptoken(s.Bucket + ":" + cleanKey(key))
+	return qio.Put(nil, &putRet, uptoken, cleanKey(key), r, putExtra)
+}
+
+func (s *Qiniu) Get(key string) (io.ReadCloser, error) {
+	resp, err := http.Get(s.URL(key))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *Qiniu) Delete(key string) error {
+	if err := rs.New(nil).Delete(nil, s.Bucket, cleanKey(key)); err != nil {
+		if _, err := s.Stat(key); err == ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Qiniu) URL(key string) string {
+	return utils.GetQiniuPublicDownloadUrl(s.Domain, cleanKey(key))
+}
+
+func (s *Qiniu) ImageURL(key string, width, height int) string {
+	return utils.GetQiniuZoomViewUrl(s.URL(key), width, height)
+}
+
+func (s *Qiniu) Stat(key string) (*FileInfo, error) {
+	resp, err := http.Head(s.URL(key))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return responseInfo(key, resp), nil
+}
+
+// checkResponse converts failed http response to error.
+func checkResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotExist
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("storage: %s %s: %s", resp.Request.Method, resp.Request.URL, resp.Status)
+	}
+	return nil
+}
+
+// responseInfo reads file info from headers of http response.
+func responseInfo(key string, resp *http.Response) *FileInfo {
+	info := &FileInfo{
+		Key:  key,
+		Size: resp.ContentLength,
+		Mime: resp.Header.Get("Content-Type"),
+	}
+	info.Modified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info
+}
@@ -0,0 +1,202 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload skips hashing of uploaded bodies, it is supported by aws s3 and minio.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3 stores files in a bucket of S3 compatible services with path style urls,
+// requests are signed with AWS signature version 4.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicUrl string
+	Client    *http.Client
+}
+
+func NewS3(endpoint, region, bucket, accessKey, secretKey, publicUrl string) *S3 {
+	return &S3{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PublicUrl: publicUrl,
+		Client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (s *S3) objectUrl(key string) string {
+	return s.Endpoint + "/" + s3Escape(s.Bucket) + "/" + s3Escape(cleanKey(key))
+}
+
+// do sends a signed request of the object of key, the response is checked by checkResponse.
+func (s *S3) do(method, key string, body io.Reader, size int64, mime string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectUrl(key), body)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	if mime != "" {
+		req.Header.Set("Content-Type", mime)
+	}
+	signV4(req, s.AccessKey, s.SecretKey, s.Region, payloadHash, time.Now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64, mime string) error {
+	resp, err := s.do("PUT", key, r, size, mime)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(key string) error {
+	resp, err := s.do("DELETE", key, nil, 0, "")
+	if err == ErrNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) URL(key string) string {
+	if s.PublicUrl != "" {
+		return strings.TrimRight(s.PublicUrl, "/") + "/" + s3Escape(cleanKey(key))
+	}
+	return s.objectUrl(key)
+}
+
+func (s *S3) Stat(key string) (*FileInfo, error) {
+	resp, err := s.do("HEAD", key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return responseInfo(key, resp), nil
+}
+
+// sha256 of empty string
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 signs req with host, content-type, range and x-amz-* headers.
+func signV4(req *http.Request, accessKey, secretKey, region, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	names := []string{"host"}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders string
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSha256([]byte("AWS4"+secretKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes path like uri encoding of AWS signature, slashes are kept.
+func s3Escape(path string) string {
+	var buf []byte
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			buf = append(buf, c)
+		} else {
+			buf = append(buf, fmt.Sprintf("%%%02X", c)...)
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package storage stores uploaded files in local disk, Qiniu or S3 compatible services.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/setting"
+)
+
+var ErrNotExist = errors.New("storage: file not exist")
+
+// FileInfo describes a stored file.
+type FileInfo struct {
+	Key      string
+	Size     int64
+	Mime     string
+	Modified time.Time
+}
+
+// Storage saves files by key, keys are slash separated paths like `img/14/03/01/1/full.jpg`.
+type Storage interface {
+	// Put saves size bytes of r as key, the file is replaced if exists.
+	Put(key string, r io.Reader, size int64, mime string) error
+
+	// Get opens the file of key, returns ErrNotExist if not found.
+	Get(key string) (io.ReadCloser, error)
+
+	// Delete removes the file of key, it is not an error if not found.
+	Delete(key string) error
+
+	// URL is where browsers can download the file.
+	URL(key string) string
+
+	// Stat returns info of the file of key, returns ErrNotExist if not found.
+	Stat(key string) (*FileInfo, error)
+}
+
+// ImageViewer is implemented by storages that can resize images on the fly.
+type ImageViewer interface {
+	ImageURL(key string, width, height int) string
+}
+
+var (
+	current     Storage
+	currentOnce sync.Once
+)
+
+// Open creates the storage of backend with settings in app.ini.
+func Open(backend string) (Storage, error) {
+	switch backend {
+	case "local":
+		return NewLocal(setting.StorageLocalRoot), nil
+	case "qiniu":
+		return NewQiniu(setting.QiniuAccessKey, setting.QiniuSecurityKey, setting.QiniuPostBucket, setting.QiniuPostDomain), nil
+	case "s3":
+		return NewS3(setting.S3Endpoint, setting.S3Region, setting.S3Bucket,
+			setting.S3AccessKey, setting.S3SecretKey, setting.S3PublicUrl), nil
+	}
+	return nil, fmt.Errorf("storage: unknown backend `%s`", backend)
+}
+
+// Current returns the storage configured as [storage] backend,
+// local storage is used if the backend is invalid.
+func Current() Storage {
+	currentOnce.Do(func() {
+		var err error
+		if current, err = Open(setting.StorageBackend); err != nil {
+			beego.Error("storage.Current: ", err)
+			current = NewLocal(setting.StorageLocalRoot)
+		}
+	})
+	return current
+}
+
+// ImageURL returns url of image resized to fit in width and height,
+// the original url is returned if the storage can't resize images.
+func ImageURL(s Storage, key string, width, height int) string {
+	if viewer, ok := s.(ImageViewer); ok {
+		return viewer.ImageURL(key, width, height)
+	}
+	return s.URL(key)
+}
+
+// Copy copies the file of key from src to dst.
+func Copy(dst, src Storage, key string) error {
+	info, err := src.Stat(key)
+	if err != nil {
+		return err
+	}
+
+	r, err := src.Get(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return dst.Put(key, r, info.Size, info.Mime)
+}
+
+// cleanKey removes leading slashes and parent directories of key.
+func cleanKey(key string) string {
+	parts := strings.Split(key, "/")
+	clean := parts[:0]
+	for _, part := range parts {
+		switch part {
+		case "", ".", "..":
+		default:
+			clean = append(clean, part)
+		}
+	}
+	return strings.Join(clean, "/")
+}
@@ -0,0 +1,321 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package unfurl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+var ErrNotAllowed = errors.New("host of link is not allowed")
+
+// endpoints of known sites, replaced by local stubs in tests
+var (
+	githubAPI     = "https://api.github.com"
+	playgroundURL = "https://play.golang.org"
+)
+
+var (
+	metaRegexp  = regexp.MustCompile(`(?i)<(meta|link)\s[^>]*>`)
+	attrRegexp  = regexp.MustCompile(`(?i)([a-z:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	titleRegexp = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	playRegexp  = regexp.MustCompile(`^(?:/play)?/p/([\w-]+)$`)
+)
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// Allowed checks whether link is http or https link of allowed hosts or their subdomains.
+func Allowed(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := hostname(u.Host)
+	for _, allow := range setting.UnfurlAllowHosts {
+		if host == allow || strings.HasSuffix(host, "."+allow) {
+			return true
+		}
+	}
+	return false
+}
+
+func newClient() *http.Client {
+	return &http.Client{
+		Timeout: time.Duration(setting.UnfurlTimeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !Allowed(req.URL.String()) {
+				return ErrNotAllowed
+			}
+			return nil
+		},
+	}
+}
+
+// get reads body of link no larger than max body size.
+func get(client *http.Client, link string, accept string) ([]byte, error) {
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "WeTalk-Unfurl/"+setting.APP_VER)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", link, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, setting.UnfurlMaxBodySize))
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// Fetch fetches metadata of link, known sites are fetched from their apis and
+// other pages from their oEmbed or OpenGraph metadata.
+func Fetch(link string) (*models.LinkPreview, error) {
+	if !Allowed(link) {
+		return nil, ErrNotAllowed
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newClient()
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	host := hostname(u.Host)
+
+	switch {
+	case host == "github.com" && len(parts) == 2:
+		return fetchGithubRepo(client, parts[0], parts[1])
+	case host == "github.com" && len(parts) == 4 && (parts[2] == "issues" || parts[2] == "pull"):
+		return fetchGithubIssue(client, parts[0], parts[1], parts[3])
+	case (host == "play.golang.org" || host == "go.dev") && playRegexp.MatchString(u.Path):
+		return fetchPlayground(client, playRegexp.FindStringSubmatch(u.Path)[1])
+	}
+
+	preview, err := fetchPage(client, link)
+	if err != nil {
+		return nil, err
+	}
+	if host == "pkg.go.dev" {
+		preview.Kind = "pkg"
+		preview.Title = strings.Trim(u.Path, "/")
+	}
+	return preview, nil
+}
+
+func getJSON(client *http.Client, link string, v interface{}) error {
+	data, err := get(client, link, "application/json")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func fetchGithubRepo(client *http.Client, owner, repo string) (*models.LinkPreview, error) {
+	var info struct {
+		FullName    string `json:"full_name"`
+		Description string `json:"description"`
+		Language    string `json:"language"`
+		Stars       int    `json:"stargazers_count"`
+		Owner       struct {
+			Avatar string `json:"avatar_url"`
+		} `json:"owner"`
+	}
+	if err := getJSON(client, fmt.Sprintf("%s/repos/%s/%s", githubAPI, owner, repo), &info); err != nil {
+		return nil, err
+	}
+
+	extra := fmt.Sprintf("★ %d", info.Stars)
+	if info.Language != "" {
+		extra += " · " + info.Language
+	}
+	return &models.LinkPreview{
+		Kind:        "github_repo",
+		Title:       info.FullName,
+		Description: truncate(info.Description, 300),
+		Image:       info.Owner.Avatar,
+		SiteName:    "GitHub",
+		Extra:       extra,
+	}, nil
+}
+
+func fetchGithubIssue(client *http.Client, owner, repo, number string) (*models.LinkPreview, error) {
+	var info struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		State  string `json:"state"`
+		Body   string `json:"body"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		PullRequest *struct{} `json:"pull_request"`
+	}
+	if err := getJSON(client, fmt.Sprintf("%s/repos/%s/%s/issues/%s", githubAPI, owner, repo, number), &info); err != nil {
+		return nil, err
+	}
+
+	kind := "issue"
+	if info.PullRequest != nil {
+		kind = "pull request"
+	}
+	return &models.LinkPreview{
+		Kind:        "github_issue",
+		Title:       fmt.Sprintf("%s/%s#%d %s", owner, repo, info.Number, truncate(info.Title, 200)),
+		Description: truncate(info.Body, 300),
+		SiteName:    "GitHub",
+		Extra:       fmt.Sprintf("%s %s · %s", info.State, kind, info.User.Login),
+	}, nil
+}
+
+func fetchPlayground(client *http.Client, id string) (*models.LinkPreview, error) {
+	code, err := get(client, playgroundURL+"/p/"+id+".go", "text/plain")
+	if err != nil {
+		return nil, err
+	}
+
+	// first lines of snippet
+	lines := strings.SplitN(string(code), "\n", 16)
+	if len(lines) > 15 {
+		lines = append(lines[:15], "…")
+	}
+	return &models.LinkPreview{
+		Kind:     "playground",
+		Title:    "Go Playground",
+		SiteName: "play.golang.org",
+		Extra:    strings.TrimRight(strings.Join(lines, "\n"), "\n"),
+	}, nil
+}
+
+func parseAttrs(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrRegexp.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3])
+	}
+	return attrs
+}
+
+// fetchPage reads oEmbed or OpenGraph metadata of html page.
+func fetchPage(client *http.Client, link string) (*models.LinkPreview, error) {
+	data, err := get(client, link, "text/html")
+	if err != nil {
+		return nil, err
+	}
+	page := string(data)
+
+	preview := &models.LinkPreview{Kind: "page"}
+	var oembed string
+	meta := make(map[string]string)
+	for _, m := range metaRegexp.FindAllStringSubmatch(page, -1) {
+		attrs := parseAttrs(m[0])
+		if strings.ToLower(m[1]) == "link" {
+			if attrs["type"] == "application/json+oembed" && attrs["href"] != "" {
+				oembed = attrs["href"]
+			}
+			continue
+		}
+		name := attrs["property"]
+		if name == "" {
+			name = attrs["name"]
+		}
+		if _, ok := meta[name]; !ok && name != "" {
+			meta[name] = attrs["content"]
+		}
+	}
+
+	if oembed != "" {
+		if ref, err := url.Parse(oembed); err == nil {
+			base, _ := url.Parse(link)
+			oembed = base.ResolveReference(ref).String()
+			if Allowed(oembed) && fetchOembed(client, oembed, preview) == nil {
+				return preview, nil
+			}
+		}
+	}
+
+	preview.Title = meta["og:title"]
+	if preview.Title == "" {
+		if m := titleRegexp.FindStringSubmatch(page); m != nil {
+			preview.Title = html.UnescapeString(m[1])
+		}
+	}
+	if preview.Title == "" {
+		return nil, fmt.Errorf("%s: no title", link)
+	}
+	preview.Title = truncate(preview.Title, 200)
+
+	preview.Description = meta["og:description"]
+	if preview.Description == "" {
+		preview.Description = meta["description"]
+	}
+	preview.Description = truncate(preview.Description, 300)
+	preview.Image = meta["og:image"]
+	preview.SiteName = truncate(meta["og:site_name"], 100)
+	return preview, nil
+}
+
+func fetchOembed(client *http.Client, link string, preview *models.LinkPreview) error {
+	var info struct {
+		Title     string `json:"title"`
+		Author    string `json:"author_name"`
+		Provider  string `json:"provider_name"`
+		Thumbnail string `json:"thumbnail_url"`
+	}
+	if err := getJSON(client, link, &info); err != nil {
+		return err
+	}
+	if info.Title == "" {
+		return errors.New("oembed: no title")
+	}
+
+	preview.Kind = "oembed"
+	preview.Title = truncate(info.Title, 200)
+	preview.Image = info.Thumbnail
+	preview.SiteName = truncate(info.Provider, 100)
+	preview.Extra = info.Author
+	return nil
+}
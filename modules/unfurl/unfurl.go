@@ -0,0 +1,246 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package unfurl renders links alone in paragraphs as cards with metadata
+// of the linked pages, fetched from allowed hosts and cached in database.
+package unfurl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+// paragraph of only one link rendered by markdown
+var linkRegexp = regexp.MustCompile(`<p><a href="([^"]+)"(?: rel="nofollow")?>([^<]+)</a></p>`)
+
+// size of url and image columns, longer links are not unfurled
+const maxURLSize = 500
+
+// ErrPending is returned when link is still fetching after rendering stopped waiting.
+var ErrPending = errors.New("unfurl: link is still fetching")
+
+// Extension replaces bare links alone in paragraphs with their cards,
+// it is registered as markdown extension "unfurl".
+// links are fetched in background, rendering waits for them until a shared
+// deadline and links still fetching are kept as plain links this time.
+func Extension(content string) string {
+	if !setting.UnfurlEnabled {
+		return content
+	}
+
+	deadline := time.Now().Add(time.Duration(setting.UnfurlWaitMillis) * time.Millisecond)
+
+	// start fetching of all links first, so they are waited at the same time
+	replaceLinks(content, func(link string) (*models.LinkPreview, error) {
+		if _, fresh, err := readCached(link); err == nil && !fresh {
+			startFetch(link)
+		}
+		return nil, ErrPending
+	})
+
+	return replaceLinks(content, func(link string) (*models.LinkPreview, error) {
+		return Lookup(link, deadline)
+	})
+}
+
+// replaceLinks replaces links with cards of metadata returned by lookup,
+// links failed to lookup are kept as they are.
+func replaceLinks(content string, lookup func(string) (*models.LinkPreview, error)) string {
+	count := 0
+	return linkRegexp.ReplaceAllStringFunc(content, func(m string) string {
+		parts := linkRegexp.FindStringSubmatch(m)
+		link := html.UnescapeString(parts[1])
+		if len(link) > maxURLSize || link != strings.TrimSpace(html.UnescapeString(parts[2])) || !Allowed(link) {
+			return m
+		}
+		if count >= setting.UnfurlMaxLinks {
+			return m
+		}
+		count++
+
+		preview, err := lookup(link)
+		if err != nil || preview.Failed {
+			return m
+		}
+		return Card(preview)
+	})
+}
+
+func urlHash(link string) string {
+	sum := sha1.Sum([]byte(link))
+	return hex.EncodeToString(sum[:])
+}
+
+// readCached reads cached metadata of link, fresh is false when there is
+// no cache or it is expired, expired cache is returned with nil error.
+func readCached(link string) (preview *models.LinkPreview, fresh bool, err error) {
+	cached := models.LinkPreview{UrlHash: urlHash(link)}
+	if err := cached.Read("UrlHash"); err == orm.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	hours := setting.UnfurlCacheHours
+	if cached.Failed {
+		hours = setting.UnfurlRetryHours
+	}
+	return &cached, time.Since(cached.Fetched) < time.Duration(hours)*time.Hour, nil
+}
+
+// links being fetched, renderings of same link share one fetching
+var (
+	fetchLock sync.Mutex
+	fetching  = make(map[string]chan struct{})
+)
+
+// startFetch fetches link in background, returned channel is closed
+// after its metadata are saved.
+func startFetch(link string) <-chan struct{} {
+	fetchLock.Lock()
+	defer fetchLock.Unlock()
+
+	if done, ok := fetching[link]; ok {
+		return done
+	}
+	done := make(chan struct{})
+	fetching[link] = done
+
+	go func() {
+		if err := fetchAndSave(link); err != nil {
+			beego.Error("unfurl: ", err)
+		}
+
+		fetchLock.Lock()
+		delete(fetching, link)
+		fetchLock.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// fetchAndSave fetches link and saves its metadata, failed fetching is also
+// saved to avoid fetching broken links on every rendering.
+func fetchAndSave(link string) error {
+	cached, _, err := readCached(link)
+	if err != nil {
+		return err
+	}
+
+	preview, err := Fetch(link)
+	if err != nil {
+		beego.Info("unfurl: ", err)
+		preview = &models.LinkPreview{Failed: true}
+	}
+	fitColumns(preview)
+	preview.UrlHash = urlHash(link)
+	preview.Url = link
+	preview.Fetched = time.Now()
+
+	if cached != nil {
+		preview.Id = cached.Id
+		return preview.Update()
+	}
+	return preview.Insert()
+}
+
+// fitColumns truncates metadata to sizes of their columns,
+// image too long to be saved is dropped.
+func fitColumns(p *models.LinkPreview) {
+	if len([]rune(p.Image)) > maxURLSize {
+		p.Image = ""
+	}
+	p.Kind = truncate(p.Kind, 20)
+	p.Title = truncate(p.Title, 255)
+	p.Description = truncate(p.Description, 500)
+	p.SiteName = truncate(p.SiteName, 100)
+}
+
+// Lookup returns cached metadata of link, link is fetched again when cache expired.
+// It waits fetching until deadline, expired cache is returned if fetching is not
+// finished, or ErrPending if there is no cache. Metadata failed to save are
+// also treated as not fetched.
+func Lookup(link string, deadline time.Time) (*models.LinkPreview, error) {
+	cached, fresh, err := readCached(link)
+	if err != nil || fresh {
+		return cached, err
+	}
+
+	select {
+	case <-startFetch(link):
+		if preview, fresh, err := readCached(link); err != nil || fresh {
+			return preview, err
+		}
+	case <-time.After(deadline.Sub(time.Now())):
+	}
+
+	if cached != nil {
+		return cached, nil
+	}
+	return nil, ErrPending
+}
+
+func isImageURL(link string) bool {
+	return strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://")
+}
+
+// Card renders html of link card, all metadata are escaped.
+func Card(p *models.LinkPreview) string {
+	var b bytes.Buffer
+	esc := html.EscapeString
+
+	b.WriteString(`<div class="link-card link-card-` + esc(strings.Replace(p.Kind, "_", "-", -1)) + `">`)
+	if p.Image != "" && isImageURL(p.Image) && p.Kind != "playground" {
+		b.WriteString(`<img class="link-card-image" src="` + esc(p.Image) + `" alt="">`)
+	}
+
+	title := p.Title
+	if title == "" {
+		title = p.Url
+	}
+	b.WriteString(`<a class="link-card-title" href="` + esc(p.Url) + `" rel="nofollow" target="_blank">` + esc(title) + `</a>`)
+
+	if p.Description != "" {
+		b.WriteString(`<p class="link-card-desc">` + esc(p.Description) + `</p>`)
+	}
+	if p.Kind == "playground" {
+		b.WriteString(`<pre class="link-card-code"><code class="language-go">` + esc(p.Extra) + `</code></pre>`)
+	}
+
+	var meta []string
+	if p.SiteName != "" {
+		meta = append(meta, esc(p.SiteName))
+	}
+	if p.Extra != "" && p.Kind != "playground" {
+		meta = append(meta, esc(p.Extra))
+	}
+	if len(meta) > 0 {
+		b.WriteString(`<div class="link-card-meta">` + strings.Join(meta, " · ") + `</div>`)
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
@@ -0,0 +1,198 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package unfurl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/varding/wetalk/modules/models"
+	. "github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// local stub of github api, playground and a page with OpenGraph or oEmbed metadata
+func sitesStub() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/golang/go", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"full_name":"golang/go","description":"The Go programming language","language":"Go","stargazers_count":100,"owner":{"avatar_url":"https://example.com/a.png"}}`))
+	})
+	mux.HandleFunc("/repos/golang/go/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number":1,"title":"<b>bug</b>","state":"open","user":{"login":"gopher"},"pull_request":{}}`))
+	})
+	mux.HandleFunc("/p/abc.go", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("package main\n\nfunc main() {}\n"))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Fallback</title>
+<meta property="og:title" content="Page &amp; Title">
+<meta name="description" content="About page">
+<meta property="og:site_name" content="Example">
+</head></html>`))
+	})
+	mux.HandleFunc("/video", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Video</title>
+<link rel="alternate" type="application/json+oembed" href="/oembed?url=video">
+</head></html>`))
+	})
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"title":"A video","author_name":"gopher","provider_name":"Tube","thumbnail_url":"https://example.com/t.jpg"}`))
+	})
+	mux.HandleFunc("/notitle", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html></html>`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func setupStub(t *testing.T) *httptest.Server {
+	ts := sitesStub()
+	githubAPI = ts.URL
+	playgroundURL = ts.URL
+	setting.UnfurlAllowHosts = []string{"github.com", "play.golang.org", "127.0.0.1"}
+	setting.UnfurlTimeout = 3
+	setting.UnfurlMaxLinks = 2
+	setting.UnfurlMaxBodySize = 1 << 16
+	return ts
+}
+
+func TestAllowed(t *testing.T) {
+	setting.UnfurlAllowHosts = []string{"github.com"}
+
+	ThrowFail(t, AssertIs(Allowed("https://github.com/golang/go"), true))
+	ThrowFail(t, AssertIs(Allowed("https://gist.github.com/x"), true))
+	ThrowFail(t, AssertIs(Allowed("https://github.com:443/x"), true))
+	ThrowFail(t, AssertIs(Allowed("https://evilgithub.com/x"), false))
+	ThrowFail(t, AssertIs(Allowed("ftp://github.com/x"), false))
+	ThrowFail(t, AssertIs(Allowed("javascript:alert(1)"), false))
+}
+
+func TestFetch(t *testing.T) {
+	ts := setupStub(t)
+	defer ts.Close()
+
+	p, err := Fetch("https://github.com/golang/go")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(p.Kind, "github_repo"))
+	ThrowFail(t, AssertIs(p.Title, "golang/go"))
+	ThrowFail(t, AssertIs(p.Extra, "★ 100 · Go"))
+
+	p, err = Fetch("https://github.com/golang/go/pull/1")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(p.Kind, "github_issue"))
+	ThrowFail(t, AssertIs(p.Title, "golang/go#1 <b>bug</b>"))
+	ThrowFail(t, AssertIs(p.Extra, "open pull request · gopher"))
+
+	p, err = Fetch("https://play.golang.org/p/abc")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(p.Kind, "playground"))
+	ThrowFail(t, AssertIs(p.Extra, "package main\n\nfunc main() {}"))
+
+	p, err = Fetch(ts.URL + "/page")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(p.Kind, "page"))
+	ThrowFail(t, AssertIs(p.Title, "Page & Title"))
+	ThrowFail(t, AssertIs(p.Description, "About page"))
+	ThrowFail(t, AssertIs(p.SiteName, "Example"))
+
+	p, err = Fetch(ts.URL + "/video")
+	ThrowFailNow(t, AssertIs(err, nil))
+	ThrowFail(t, AssertIs(p.Kind, "oembed"))
+	ThrowFail(t, AssertIs(p.Title, "A video"))
+	ThrowFail(t, AssertIs(p.SiteName, "Tube"))
+
+	_, err = Fetch(ts.URL + "/notitle")
+	ThrowFail(t, AssertIs(err != nil, true))
+
+	_, err = Fetch(ts.URL + "/missing")
+	ThrowFail(t, AssertIs(err != nil, true))
+
+	_, err = Fetch("https://example.com/")
+	ThrowFail(t, AssertIs(err, ErrNotAllowed))
+}
+
+func TestCard(t *testing.T) {
+	card := Card(&models.LinkPreview{
+		Url:   "https://github.com/golang/go/issues/1",
+		Kind:  "github_issue",
+		Title: `<script>alert("x")</script>`,
+		Image: "javascript:alert(1)",
+		Extra: "open issue",
+	})
+	ThrowFail(t, AssertIs(strings.Contains(card, "<script>"), false))
+	ThrowFail(t, AssertIs(strings.Contains(card, "javascript:"), false))
+	ThrowFail(t, AssertIs(strings.Contains(card, `class="link-card link-card-github-issue"`), true))
+	ThrowFail(t, AssertIs(strings.Contains(card, `<div class="link-card-meta">open issue</div>`), true))
+
+	card = Card(&models.LinkPreview{Url: "https://play.golang.org/p/abc", Kind: "playground", Extra: "a < b"})
+	ThrowFail(t, AssertIs(strings.Contains(card, `<code class="language-go">a &lt; b</code>`), true))
+}
+
+func TestReplaceLinks(t *testing.T) {
+	setting.UnfurlAllowHosts = []string{"github.com"}
+	setting.UnfurlMaxLinks = 2
+
+	lookups := 0
+	lookup := func(link string) (*models.LinkPreview, error) {
+		lookups++
+		switch link {
+		case "https://github.com/a/b":
+			return &models.LinkPreview{Url: link, Kind: "github_repo", Title: "a/b"}, nil
+		case "https://github.com/a/failed":
+			return &models.LinkPreview{Url: link, Failed: true}, nil
+		}
+		return nil, errors.New("fetch failed")
+	}
+
+	content := `<p><a href="https://github.com/a/b" rel="nofollow">https://github.com/a/b</a></p>`
+	ThrowFail(t, AssertIs(strings.HasPrefix(replaceLinks(content, lookup), `<div class="link-card`), true))
+
+	// failed links degrade to plain links
+	for _, content := range []string{
+		`<p><a href="https://github.com/a/failed" rel="nofollow">https://github.com/a/failed</a></p>`,
+		`<p><a href="https://github.com/a/error" rel="nofollow">https://github.com/a/error</a></p>`,
+	} {
+		ThrowFail(t, AssertIs(replaceLinks(content, lookup), content))
+	}
+
+	// links with text, inside paragraphs or of other hosts are not fetched
+	lookups = 0
+	for _, content := range []string{
+		`<p><a href="https://github.com/a/b" rel="nofollow">repo</a></p>`,
+		`<p>see <a href="https://github.com/a/b" rel="nofollow">https://github.com/a/b</a></p>`,
+		`<p><a href="https://example.com/" rel="nofollow">https://example.com/</a></p>`,
+		`<p><a href="https://github.com/` + strings.Repeat("a", maxURLSize) + `">https://github.com/` + strings.Repeat("a", maxURLSize) + `</a></p>`,
+	} {
+		ThrowFail(t, AssertIs(replaceLinks(content, lookup), content))
+	}
+	ThrowFail(t, AssertIs(lookups, 0))
+
+	// max links
+	lookups = 0
+	replaceLinks(strings.Repeat(content, 3), lookup)
+	ThrowFail(t, AssertIs(lookups, 2))
+}
+
+func TestFitColumns(t *testing.T) {
+	p := &models.LinkPreview{
+		Title: strings.Repeat("标", 300),
+		Image: "https://github.com/" + strings.Repeat("a", maxURLSize),
+	}
+	fitColumns(p)
+	ThrowFail(t, AssertIs(len([]rune(p.Title)), 255))
+	ThrowFail(t, AssertIs(p.Image, ""))
+}
@@ -127,7 +127,8 @@ func init() {
 	beego.AddFuncMap("sum", sum)
 	beego.AddFuncMap("loginto", loginto)
 	beego.AddFuncMap("isnotificationread", isnotificationread)
-	beego.AddFuncMap("getbulletintype", getbulletintype)
+	beego.AddFuncMap("getbulletintype", GetBulletinType)
+	beego.AddFuncMap("filesize", FileSize)
 }
 
 func RenderTemplate(TplNames string, Data map[interface{}]interface{}) string {
@@ -155,7 +156,7 @@ func isnotificationread(status int) bool {
 	return result
 }
 
-func getbulletintype(lang string, t int) string {
+func GetBulletinType(lang string, t int) string {
 	var typeStr string
 	switch t {
 	case setting.BULLETIN_FRIEND_LINK:
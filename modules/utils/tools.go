@@ -412,3 +412,18 @@ func IsMatchHost(uri string) bool {
 
 	return true
 }
+
+// human readable size of bytes
+func FileSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", size, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
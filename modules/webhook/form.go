@@ -0,0 +1,91 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package webhook
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/astaxie/beego/validation"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+)
+
+type WebhookAdminForm struct {
+	Create   bool     `form:"-"`
+	Id       int      `form:"-"`
+	Url      string   `valid:"Required;MaxSize(200)"`
+	Secret   string   `valid:"MaxSize(100)"`
+	Events   []string `form:"type(select);attr(multiple,multiple);attr(rel,select2)"`
+	IsActive bool     ``
+}
+
+func (form *WebhookAdminForm) EventsSelectData() [][]string {
+	data := make([][]string, 0, len(Events))
+	for _, event := range Events {
+		data = append(data, []string{"model.webhook_event_" + strings.Replace(event, ".", "_", -1), event})
+	}
+	return data
+}
+
+func (form *WebhookAdminForm) Valid(v *validation.Validation) {
+	if u, err := url.Parse(form.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		v.SetError("Url", "admin.webhook_invalid_url")
+	}
+
+	if len(cleanEvents(form.Events)) == 0 {
+		v.SetError("Events", "Can not be empty")
+	}
+}
+
+func (form *WebhookAdminForm) Labels() map[string]string {
+	return map[string]string{
+		"Url":      "model.webhook_url",
+		"Secret":   "model.webhook_secret",
+		"Events":   "model.webhook_events",
+		"IsActive": "model.webhook_isactive",
+	}
+}
+
+func (form *WebhookAdminForm) Helps() map[string]string {
+	return map[string]string{
+		"Secret": "model.webhook_secret_help",
+	}
+}
+
+func (form *WebhookAdminForm) SetFromWebhook(hook *models.Webhook) {
+	utils.SetFormValues(hook, form)
+	form.Events = hook.EventList()
+}
+
+func (form *WebhookAdminForm) SetToWebhook(hook *models.Webhook) {
+	utils.SetFormValues(form, hook, "Id")
+	hook.Events = cleanEvents(form.Events)
+}
+
+// filter unknown events and return them in a comma separated string
+func cleanEvents(events []string) string {
+	clean := make([]string, 0, len(Events))
+	for _, e := range Events {
+		for _, v := range events {
+			if v == e {
+				clean = append(clean, e)
+				break
+			}
+		}
+	}
+	return strings.Join(clean, ",")
+}
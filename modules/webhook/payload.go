@@ -0,0 +1,83 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package webhook
+
+import (
+	"github.com/varding/wetalk/modules/models"
+)
+
+// public user fields for payload, never send email or password
+func UserData(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       user.Id,
+		"username": user.UserName,
+		"nickname": user.NickName,
+		"url":      user.Link(),
+	}
+}
+
+func PostData(post *models.Post, user *models.User) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":      post.Id,
+		"title":   post.Title,
+		"content": post.Content,
+		"url":     post.Link(),
+		"is_best": post.IsBest,
+		"user":    UserData(user),
+	}
+	if post.Topic != nil {
+		data["topic"] = post.Topic.Id
+	}
+	if post.Category != nil {
+		data["category"] = post.Category.Id
+	}
+	return data
+}
+
+func CommentData(comment *models.Comment, post *models.Post, user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      comment.Id,
+		"floor":   comment.Floor,
+		"message": comment.Message,
+		"url":     post.Link(),
+		"post": map[string]interface{}{
+			"id":    post.Id,
+			"title": post.Title,
+		},
+		"user": UserData(user),
+	}
+}
+
+// fire helpers used by routers
+
+func PostCreated(post *models.Post, user *models.User) {
+	Fire(EventPostCreated, PostData(post, user))
+}
+
+func PostEdited(post *models.Post, user *models.User) {
+	Fire(EventPostEdited, PostData(post, user))
+}
+
+func PostBest(post *models.Post, user *models.User) {
+	Fire(EventPostBest, PostData(post, user))
+}
+
+func CommentCreated(comment *models.Comment, post *models.Post, user *models.User) {
+	Fire(EventCommentCreated, CommentData(comment, post, user))
+}
+
+func UserRegistered(user *models.User) {
+	Fire(EventUserRegistered, UserData(user))
+}
@@ -0,0 +1,209 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+const (
+	EventPostCreated    = "post.created"
+	EventPostEdited     = "post.edited"
+	EventPostBest       = "post.best"
+	EventCommentCreated = "comment.created"
+	EventUserRegistered = "user.registered"
+)
+
+var Events = []string{
+	EventPostCreated,
+	EventPostEdited,
+	EventPostBest,
+	EventCommentCreated,
+	EventUserRegistered,
+}
+
+// max saved bytes of response body
+const maxResponseBody = 1024
+
+// sign payload with hook secret, send as X-Wetalk-Signature header
+func Sign(secret, payload string) string {
+	return "sha256=" + utils.EncodeHmac(secret, payload, sha256.New)
+}
+
+// fire event to all active hooks subscribed it, deliveries are sent async
+func Fire(event string, data interface{}) {
+	if !setting.WebhookEnabled {
+		return
+	}
+
+	var hooks []models.Webhook
+	if _, err := models.Webhooks().Filter("IsActive", true).All(&hooks); err != nil {
+		beego.Error("Webhook: ", err)
+		return
+	}
+
+	var payload string
+	for i := range hooks {
+		hook := &hooks[i]
+		if !hook.HasEvent(event) {
+			continue
+		}
+
+		if len(payload) == 0 {
+			body, err := json.Marshal(map[string]interface{}{
+				"event":   event,
+				"created": time.Now().Unix(),
+				"data":    data,
+			})
+			if err != nil {
+				beego.Error("Webhook: ", err)
+				return
+			}
+			payload = string(body)
+		}
+
+		delivery := models.WebhookDelivery{
+			Webhook: hook,
+			Event:   event,
+			Payload: payload,
+		}
+		if err := delivery.Insert(); err != nil {
+			beego.Error("Webhook: ", err)
+			continue
+		}
+		go Deliver(&delivery)
+	}
+}
+
+// send the delivery once, record the response and schedule retry if failed
+func Deliver(delivery *models.WebhookDelivery) {
+	hook := delivery.Webhook
+	if err := hook.Read(); err != nil {
+		beego.Error("Webhook: ", err)
+		return
+	}
+
+	delivery.Attempts += 1
+	delivery.ResponseCode = 0
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	start := time.Now()
+	err := post(hook, delivery)
+	delivery.Duration = int(time.Since(start) / time.Millisecond)
+
+	delivery.IsSuccess = err == nil
+	delivery.IsPending = false
+	if err != nil {
+		delivery.Error = err.Error()
+		if len(delivery.Error) > 255 {
+			delivery.Error = delivery.Error[:255]
+		}
+
+		// exponential backoff, base * 2^(attempts-1)
+		if delivery.Attempts < setting.WebhookMaxAttempts {
+			backoff := time.Duration(setting.WebhookRetryBackoff) * time.Second << uint(delivery.Attempts-1)
+			delivery.NextRetry = time.Now().Add(backoff)
+			delivery.IsPending = true
+		}
+	}
+
+	if err := delivery.Update("Attempts", "ResponseCode", "ResponseBody", "Error", "Duration",
+		"IsSuccess", "IsPending", "NextRetry", "Updated"); err != nil {
+		beego.Error("Webhook: ", err)
+	}
+}
+
+func post(hook *models.Webhook, delivery *models.WebhookDelivery) error {
+	req, err := http.NewRequest("POST", hook.Url, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wetalk-webhook/"+setting.APP_VER)
+	req.Header.Set("X-Wetalk-Event", delivery.Event)
+	req.Header.Set("X-Wetalk-Delivery", utils.ToStr(delivery.Id))
+	if len(hook.Secret) > 0 {
+		req.Header.Set("X-Wetalk-Signature", Sign(hook.Secret, delivery.Payload))
+	}
+
+	client := http.Client{Timeout: time.Duration(setting.WebhookTimeout) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: maxResponseBody})
+	delivery.ResponseCode = resp.StatusCode
+	delivery.ResponseBody = string(body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("response status %s", resp.Status)
+	}
+	return nil
+}
+
+// send a new delivery with the same payload
+func Redeliver(old *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		Webhook: old.Webhook,
+		Event:   old.Event,
+		Payload: old.Payload,
+	}
+	if err := delivery.Insert(); err != nil {
+		return nil, err
+	}
+	go Deliver(&delivery)
+	return &delivery, nil
+}
+
+// retry pending deliveries which reached retry time
+func RetryPending() {
+	var deliveries []*models.WebhookDelivery
+	_, err := models.WebhookDeliveries().Filter("IsPending", true).Filter("NextRetry__lte", time.Now()).
+		OrderBy("NextRetry").Limit(100).All(&deliveries)
+	if err != nil {
+		beego.Error("Webhook: ", err)
+		return
+	}
+	for _, delivery := range deliveries {
+		Deliver(delivery)
+	}
+}
+
+// start background worker for retrying failed deliveries
+func StartRetryWorker() {
+	go func() {
+		for _ = range time.Tick(30 * time.Second) {
+			if setting.WebhookEnabled {
+				RetryPending()
+			}
+		}
+	}()
+}
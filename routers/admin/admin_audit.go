@@ -0,0 +1,303 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+)
+
+// values of these fields never write to audit log
+var auditMasked = map[string]bool{
+	"Password":     true,
+	"Rands":        true,
+	"Secret":       true,
+	"ClientSecret": true,
+	"Token":        true,
+}
+
+// max rows of one csv export
+const auditExportLimit = 10000
+
+// write audit log after a post request in admin pages redirected
+func (this *BaseAdminRouter) Finish() {
+	this.BaseRouter.Finish()
+
+	if !this.auditing {
+		return
+	}
+
+	location := this.Ctx.ResponseWriter.Header().Get("Location")
+	if location == "" {
+		return
+	}
+
+	if err := this.writeAudit(location); err != nil {
+		beego.Error("Audit: ", err)
+	}
+}
+
+func (this *BaseAdminRouter) writeAudit(location string) error {
+	values := this.Ctx.Input.Params
+
+	model := this.GetString(":model")
+	if model == "" {
+		parts := strings.Split(this.Ctx.Request.URL.Path, "/")
+		if len(parts) > 2 {
+			model = parts[2]
+		}
+	}
+
+	audit := models.AuditLog{
+		Actor: &this.User,
+		Model: model,
+		Ip:    this.Ctx.Input.IP(),
+		Path:  this.Ctx.Request.URL.Path,
+	}
+
+	if app, ok := this.AppController.(ModelFinder); ok {
+		if _, ok := values[":id"]; ok {
+			return this.writeModelAudit(app, &audit, location)
+		}
+	}
+
+	// other actions record the action and target ids only
+	audit.Action = this.GetString("action")
+	if audit.Action == "" {
+		audit.Action = "post"
+	}
+
+	ids := this.GetStrings("ids")
+	if len(ids) == 0 {
+		ids = []string{this.GetString(":id")}
+	}
+	for _, value := range ids {
+		log := audit
+		log.ObjectId, _ = utils.StrTo(value).Int()
+		if err := log.Insert(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *BaseAdminRouter) writeModelAudit(app ModelFinder, audit *models.AuditLog, location string) error {
+	var id int
+	before := this.auditBefore
+
+	if this.GetString(":id") == "new" {
+		audit.Action = "create"
+		// created object id is the last part of redirect url
+		parts := strings.Split(location, "/")
+		id, _ = utils.StrTo(parts[len(parts)-1]).Int()
+		if id <= 0 {
+			return nil
+		}
+		before = reflect.New(reflect.Indirect(reflect.ValueOf(app.Object())).Type()).Interface()
+	} else {
+		audit.Action = this.GetString(":action")
+		if audit.Action == "" {
+			audit.Action = "update"
+		}
+		id, _ = utils.StrTo(this.GetString(":id")).Int()
+	}
+
+	if before == nil {
+		return nil
+	}
+
+	// object is zero value if it has been deleted
+	after := reflect.New(reflect.Indirect(reflect.ValueOf(before)).Type()).Interface()
+	if err := app.ObjectQs().Filter("Id", id).Limit(1).One(after); err != nil && err != orm.ErrNoRows {
+		return err
+	}
+
+	changes := auditChanges(before, after)
+	switch audit.Action {
+	case "create", "update", "delete":
+		// nothing changed, the action didn't happen
+		if len(changes) == 0 {
+			return nil
+		}
+	}
+
+	audit.ObjectId = id
+	audit.SetChanges(changes)
+	return audit.Insert()
+}
+
+// copy object with its related objects, form setters may change them in place
+func auditCopy(object interface{}) interface{} {
+	val := reflect.Indirect(reflect.ValueOf(object))
+	dup := reflect.New(val.Type())
+	dup.Elem().Set(val)
+
+	elm := dup.Elem()
+	for i := 0; i < elm.NumField(); i++ {
+		field := elm.Field(i)
+		if field.Kind() != reflect.Ptr || field.IsNil() || field.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		rel := reflect.New(field.Elem().Type())
+		rel.Elem().Set(field.Elem())
+		field.Set(rel)
+	}
+	return dup.Interface()
+}
+
+func auditValue(field reflect.Value) string {
+	if field.Kind() == reflect.Ptr && field.IsNil() {
+		return ""
+	}
+	if t, ok := field.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return utils.ToStr(field.Interface())
+}
+
+// changed fields between two objects, auto updated time is skipped
+func auditChanges(before, after interface{}) []models.AuditChange {
+	fields := utils.FormChanges(before, after, "Updated")
+
+	elmBefore := reflect.Indirect(reflect.ValueOf(before))
+	elmAfter := reflect.Indirect(reflect.ValueOf(after))
+
+	changes := make([]models.AuditChange, 0, len(fields))
+	for _, name := range fields {
+		change := models.AuditChange{
+			Field:  name,
+			Before: auditValue(elmBefore.FieldByName(name)),
+			After:  auditValue(elmAfter.FieldByName(name)),
+		}
+		if change.Before == change.After {
+			continue
+		}
+		if auditMasked[name] {
+			change.Before, change.After = "******", "******"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// AuditAdminRouter serves the audit log of admin actions.
+type AuditAdminRouter struct {
+	BaseAdminRouter
+}
+
+// filter audit logs by query values
+func (this *AuditAdminRouter) auditLogs() orm.QuerySeter {
+	qs := models.AuditLogs()
+
+	if actor := this.GetString("actor"); actor != "" {
+		qs = qs.Filter("Actor__UserName", actor)
+		this.Data["Actor"] = actor
+	}
+	if model := this.GetString("model"); model != "" {
+		qs = qs.Filter("Model", model)
+		this.Data["Model"] = model
+	}
+	if action := this.GetString("action"); action != "" {
+		qs = qs.Filter("Action", action)
+		this.Data["Action"] = action
+	}
+	if from, err := time.ParseInLocation("2006-01-02", this.GetString("from"), time.Local); err == nil {
+		qs = qs.Filter("Created__gte", from)
+		this.Data["From"] = this.GetString("from")
+	}
+	if to, err := time.ParseInLocation("2006-01-02", this.GetString("to"), time.Local); err == nil {
+		qs = qs.Filter("Created__lt", to.AddDate(0, 0, 1))
+		this.Data["To"] = this.GetString("to")
+	}
+	return qs
+}
+
+// List implemented filterable audit log list.
+func (this *AuditAdminRouter) List() {
+	this.TplNames = "admin/audit/list.html"
+	this.Data["auditAdmin"] = true
+
+	qs := this.auditLogs()
+	cnt, _ := qs.Count()
+	p := this.SetPaginator(20, cnt)
+
+	var logs []models.AuditLog
+	if _, err := qs.Limit(p.PerPageNums, p.Offset()).RelatedSel().All(&logs); err != nil {
+		this.Data["Error"] = err
+		beego.Error(err)
+	}
+
+	changes := make(map[int][]models.AuditChange, len(logs))
+	for _, log := range logs {
+		changes[log.Id] = log.ChangeList()
+	}
+	this.Data["Objects"] = logs
+	this.Data["Changes"] = changes
+	this.Data["Query"] = this.Ctx.Request.URL.RawQuery
+}
+
+// Export implemented filtered audit logs as csv.
+func (this *AuditAdminRouter) Export() {
+	var logs []models.AuditLog
+	if _, err := this.auditLogs().Limit(auditExportLimit).RelatedSel().All(&logs); err != nil {
+		beego.Error(err)
+		this.Abort("500")
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Id", "Created", "Actor", "Action", "Model", "ObjectId", "Field", "Before", "After", "Ip", "Path"})
+	for _, log := range logs {
+		row := []string{
+			utils.ToStr(log.Id),
+			log.Created.Format("2006-01-02 15:04:05"),
+			log.Actor.UserName,
+			log.Action,
+			log.Model,
+			utils.ToStr(log.ObjectId),
+			"", "", "",
+			log.Ip,
+			log.Path,
+		}
+		changes := log.ChangeList()
+		if len(changes) == 0 {
+			w.Write(row)
+			continue
+		}
+		// one row per changed field
+		for _, change := range changes {
+			row[6], row[7], row[8] = change.Field, change.Before, change.After
+			w.Write(row)
+		}
+	}
+	w.Flush()
+
+	this.Ctx.Output.Header("Content-Type", "text/csv; charset=utf-8")
+	this.Ctx.Output.Header("Content-Disposition", "attachment; filename=audit-"+time.Now().Format("20060102")+".csv")
+	this.Ctx.Output.Body(buf.Bytes())
+}
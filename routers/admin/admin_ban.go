@@ -0,0 +1,127 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/ban"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+)
+
+type BanAdminRouter struct {
+	ModelAdminRouter
+	object models.Ban
+}
+
+func (this *BanAdminRouter) Object() interface{} {
+	return &this.object
+}
+
+func (this *BanAdminRouter) ObjectQs() orm.QuerySeter {
+	return models.Bans().RelatedSel("User", "Operator", "Lifter")
+}
+
+// view for list model data
+func (this *BanAdminRouter) List() {
+	var bans []models.Ban
+	qs := models.Bans().RelatedSel("User", "Operator", "Lifter")
+	if user, _ := utils.StrTo(this.GetString("user")).Int(); user > 0 {
+		qs = qs.Filter("User", user)
+	}
+	if this.GetString("all") != "true" {
+		qs = qs.Filter("IsActive", true)
+	} else {
+		this.Data["ShowAll"] = true
+	}
+	if err := this.SetObjects(qs, &bans); err != nil {
+		this.Data["Error"] = err
+		beego.Error(err)
+	}
+}
+
+// view for create object
+func (this *BanAdminRouter) Create() {
+	form := ban.BanAdminForm{Create: true, Kind: ban.KindUser}
+	form.User, _ = utils.StrTo(this.GetString("user")).Int()
+	this.SetFormSets(&form)
+}
+
+// view for new object save
+func (this *BanAdminRouter) Save() {
+	form := ban.BanAdminForm{Create: true}
+	if this.ValidFormSets(&form) == false {
+		return
+	}
+
+	b := models.Ban{IsActive: true, Operator: &this.User}
+	form.SetToBan(&b)
+	if err := b.Insert(); err == nil {
+		this.FlashRedirect(fmt.Sprintf("/admin/ban/%d", b.Id), 302, "CreateSuccess")
+		return
+	} else {
+		beego.Error(err)
+		this.Data["Error"] = err
+	}
+}
+
+// view for edit object
+func (this *BanAdminRouter) Edit() {
+	form := ban.BanAdminForm{}
+	form.SetFromBan(&this.object)
+	this.SetFormSets(&form)
+}
+
+// view for update object
+func (this *BanAdminRouter) Update() {
+	form := ban.BanAdminForm{Id: this.object.Id}
+	if this.ValidFormSets(&form) == false {
+		return
+	}
+
+	url := fmt.Sprintf("/admin/ban/%d", this.object.Id)
+
+	form.SetToBan(&this.object)
+	if err := this.object.Update("Kind", "User", "Value", "Mode", "Reason", "Expires"); err == nil {
+		this.FlashRedirect(url, 302, "UpdateSuccess")
+		return
+	} else {
+		beego.Error(err)
+		this.Data["Error"] = err
+	}
+}
+
+// view for confirm lift ban
+func (this *BanAdminRouter) Confirm() {
+}
+
+// view for lift ban, bans are never deleted for audit
+func (this *BanAdminRouter) Delete() {
+	if this.FormOnceNotMatch() {
+		return
+	}
+
+	if err := ban.Lift(&this.object, &this.User); err == nil {
+		this.FlashRedirect("/admin/ban", 302, "LiftSuccess")
+		return
+	} else {
+		beego.Error(err)
+		this.Data["Error"] = err
+	}
+}
@@ -21,12 +21,18 @@ import (
 	"github.com/astaxie/beego/orm"
 
 	"github.com/varding/wetalk/modules/auth"
+	"github.com/varding/wetalk/modules/perm"
 	"github.com/varding/wetalk/modules/utils"
 	"github.com/varding/wetalk/routers/base"
+	"github.com/varding/wetalk/setting"
 )
 
 type BaseAdminRouter struct {
 	base.BaseRouter
+
+	// post requests are written to audit log when finished
+	auditing    bool
+	auditBefore interface{}
 }
 
 func (this *BaseAdminRouter) NestPrepare() {
@@ -34,24 +40,51 @@ func (this *BaseAdminRouter) NestPrepare() {
 		return
 	}
 
-	// if user isn't admin, then logout user
+	// moderators can open pages of their permission
 	if !this.User.IsAdmin {
-		auth.LogoutUser(this.Ctx)
-		// write flash message, use .flash.NotPermit
-		this.FlashWrite("NotPermit", "true")
-		this.Redirect("/login", 302)
+		if app, ok := this.AppController.(ModeratorRouter); ok && perm.CanAny(&this.User, app.ModeratorPerm()) {
+			this.Data["IsModeratorPage"] = true
+		} else if perm.IsModerator(&this.User) {
+			this.Abort("403")
+			return
+		} else {
+			// if user isn't admin, then logout user
+			auth.LogoutUser(this.Ctx)
+			// write flash message, use .flash.NotPermit
+			this.FlashWrite("NotPermit", "true")
+			this.Redirect("/login", 302)
+			return
+		}
+	}
+
+	// access token need admin scope
+	if !this.CheckTokenScope(auth.ScopeAdmin) {
+		this.Abort("403")
+		return
+	}
+
+	// two-factor login can be required for admin pages
+	if setting.TwoFactorEnforceAdmin && !this.IsTokenAuth && !auth.HasTwoFactor(&this.User) {
+		this.FlashRedirect("/settings/twofactor", 302, "TwoFactorRequired")
 		return
 	}
 
 	// it's admin and current in admin page
 	this.Data["IsAdminPage"] = true
 
+	this.auditing = this.Ctx.Request.Method == "POST"
+
 	if app, ok := this.AppController.(ModelPreparer); ok {
 		app.ModelPrepare()
 		return
 	}
 }
 
+// admin pages also open to users granted the permission
+type ModeratorRouter interface {
+	ModeratorPerm() string
+}
+
 type ModelFinder interface {
 	Object() interface{}
 	ObjectQs() orm.QuerySeter
@@ -143,5 +176,10 @@ func (this *ModelAdminRouter) QueryObject() bool {
 		this.Data["Object"] = object
 	}
 
+	// keep the object before change for audit
+	if this.auditing {
+		this.auditBefore = auditCopy(object)
+	}
+
 	return true
 }
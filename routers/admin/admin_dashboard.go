@@ -14,6 +14,15 @@
 
 package admin
 
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/mailer"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/stats"
+	"github.com/varding/wetalk/setting"
+)
+
 type AdminDashboardRouter struct {
 	BaseAdminRouter
 }
@@ -21,4 +30,38 @@ type AdminDashboardRouter struct {
 func (this *AdminDashboardRouter) Get() {
 	this.Data["consoleAdmin"] = true
 	this.TplNames = "admin/dashboard.html"
+
+	// days must be one of configured ranges
+	days := setting.StatsRanges[0]
+	if d, err := this.GetInt("days"); err == nil {
+		for _, r := range setting.StatsRanges {
+			if r == int(d) {
+				days = r
+			}
+		}
+	}
+	this.Data["Days"] = days
+	this.Data["Ranges"] = setting.StatsRanges
+
+	// bar width of charts fit the number of days
+	width := 420 / days
+	if width < 2 {
+		width = 2
+	}
+	this.Data["BarWidth"] = width
+
+	if dash, err := stats.LoadDashboard(days); err != nil {
+		this.Data["Error"] = err
+		beego.Error("Dashboard: ", err)
+	} else {
+		this.Data["Dash"] = dash
+	}
+
+	// pending work is counted live
+	held, _ := models.HeldContents().Filter("Status", setting.HELD_PENDING).Count()
+	reports, _ := models.Reports().Filter("Status", setting.REPORT_OPEN).Count()
+	this.Data["PendingHeld"] = held
+	this.Data["PendingReports"] = reports
+
+	this.Data["Mail"] = mailer.Stats()
 }
@@ -0,0 +1,64 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// ImageAdminRouter serves cleanup of uploaded images not used by any content.
+type ImageAdminRouter struct {
+	BaseAdminRouter
+}
+
+// Cleanup implemented preview of unused images.
+func (this *ImageAdminRouter) Cleanup() {
+	this.TplNames = "admin/image/cleanup.html"
+	this.Data["imageAdmin"] = true
+	this.Data["UnusedDays"] = setting.ImageUnusedDays
+
+	images, err := attachment.UnusedImages()
+	if err != nil {
+		this.Data["Error"] = err
+		beego.Error(err)
+		return
+	}
+
+	var size int64
+	for _, img := range images {
+		size += img.Size
+	}
+	this.Data["UnusedCount"] = len(images)
+	this.Data["UnusedSize"] = size
+
+	// preview latest ones
+	if len(images) > 100 {
+		images = images[:100]
+	}
+	this.Data["Images"] = images
+}
+
+// CleanupRun implemented removing all unused images.
+func (this *ImageAdminRouter) CleanupRun() {
+	removed, err := attachment.CleanUnusedImages()
+	if err != nil {
+		beego.Error(err)
+	}
+	this.FlashRedirect("/admin/image/cleanup", 302, "ImagesRemoved", utils.ToStr(removed))
+}
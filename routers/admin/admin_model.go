@@ -0,0 +1,557 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	"github.com/astaxie/beego/validation"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/perm"
+	"github.com/varding/wetalk/modules/utils"
+)
+
+const (
+	// max rows of one list export
+	exportLimit = 10000
+
+	// max rows of one csv import
+	importLimit = 1000
+)
+
+// ModelRouter serves admin pages of all registered models.
+type ModelRouter struct {
+	ModelAdminRouter
+	admin  *ModelAdmin
+	object interface{}
+}
+
+// ModeratorPerm implemented moderators can open models of their permission.
+func (this *ModelRouter) ModeratorPerm() string {
+	if admin := getModelAdmin(this.GetString(":model")); admin != nil {
+		return admin.Perm
+	}
+	return ""
+}
+
+func (this *ModelRouter) ModelPrepare() {
+	this.admin = getModelAdmin(this.GetString(":model"))
+	if this.admin == nil {
+		this.Abort("404")
+		return
+	}
+
+	// whole model is managed, moderator need site wide grant
+	if !this.User.IsAdmin && !perm.Can(&this.User, this.admin.Perm, perm.Global) {
+		this.Abort("403")
+		return
+	}
+
+	this.object = this.admin.New()
+	this.Data["Model"] = this.admin.Name
+
+	this.ModelAdminRouter.ModelPrepare()
+}
+
+func (this *ModelRouter) Object() interface{} {
+	return this.object
+}
+
+func (this *ModelRouter) ObjectQs() orm.QuerySeter {
+	return this.admin.Qs()
+}
+
+// sortable column title of model list
+type ListColumn struct {
+	Label  string
+	Link   string
+	Sorted bool
+	Desc   bool
+}
+
+// query of model list with search keyword, filters and sort of request
+func (this *ModelRouter) listQs() (orm.QuerySeter, string) {
+	admin := this.admin
+	qs := admin.Qs()
+
+	// search keyword in any of search fields
+	if q := this.GetString("q"); q != "" && len(admin.Search) > 0 {
+		cond := orm.NewCondition()
+		for _, field := range admin.Search {
+			cond = cond.Or(field, q)
+		}
+		qs = qs.SetCond(cond)
+		this.Data["q"] = q
+	}
+
+	for _, field := range admin.Filters {
+		if value := this.GetString(strings.ToLower(field)); value != "" {
+			if v, ok := admin.filterValue(field, value); ok {
+				qs = qs.Filter(field, v)
+			}
+		}
+	}
+
+	sort := this.GetString("sort")
+	if admin.hasField(admin.Sorts, strings.TrimPrefix(sort, "-")) {
+		qs = qs.OrderBy(sort)
+	} else {
+		sort = ""
+	}
+	return qs, sort
+}
+
+// bulk action with evaluated target choices, for list template
+type ListAction struct {
+	Name    string
+	Choices [][]string
+}
+
+// view for list model data
+func (this *ModelRouter) List() {
+	this.TplNames = "admin/model/list.html"
+	admin := this.admin
+	qs, sort := this.listQs()
+
+	objects := reflect.New(reflect.SliceOf(reflect.TypeOf(this.object).Elem()))
+	if err := this.SetObjects(qs, objects.Interface()); err != nil {
+		this.Data["Error"] = err
+		beego.Error(err)
+	}
+
+	actions := make([]ListAction, 0, len(admin.Actions))
+	for _, a := range admin.Actions {
+		action := ListAction{Name: a.Name}
+		if a.Choices != nil {
+			action.Choices = a.Choices()
+		}
+		actions = append(actions, action)
+	}
+
+	this.Data["Columns"] = this.listColumns(sort)
+	this.Data["Rows"] = admin.listRows(this.Lang, objects.Interface())
+	this.Data["Filters"] = admin.listFilters(this.GetString)
+	this.Data["Searchable"] = len(admin.Search) > 0
+	this.Data["Actions"] = actions
+	this.Data["Import"] = admin.Import
+
+	// export keeps filters of the list
+	values, _ := url.ParseQuery(this.Ctx.Request.URL.RawQuery)
+	values.Del("p")
+	this.Data["ExportQuery"] = values.Encode()
+}
+
+// column titles link to list sorted by the column
+func (this *ModelRouter) listColumns(sort string) []ListColumn {
+	columns := make([]ListColumn, 0, len(this.admin.Columns))
+	for _, column := range this.admin.Columns {
+		c := ListColumn{Label: column.Label}
+		if this.admin.hasField(this.admin.Sorts, column.Field) {
+			c.Sorted = strings.TrimPrefix(sort, "-") == column.Field
+			c.Desc = c.Sorted && strings.HasPrefix(sort, "-")
+
+			values, _ := url.ParseQuery(this.Ctx.Request.URL.RawQuery)
+			values.Del("p")
+			if c.Sorted && !c.Desc {
+				values.Set("sort", "-"+column.Field)
+			} else {
+				values.Set("sort", column.Field)
+			}
+			c.Link = "?" + values.Encode()
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// bulk action for selected objects
+func (this *ModelRouter) Bulk() {
+	admin := this.admin
+	url := "/admin/" + admin.Name
+
+	action := this.GetString("action")
+	var handle func(interface{}, string) error
+	if action == "delete" {
+		handle = admin.deleteObject
+	} else {
+		for _, a := range admin.Actions {
+			if a.Name == action {
+				handle = a.Handle
+			}
+		}
+	}
+	if handle == nil {
+		this.FlashRedirect(url, 302, "BulkFailed")
+		return
+	}
+	target := this.GetString("target_" + action)
+
+	failed := 0
+	for _, value := range this.GetStrings("ids") {
+		id, _ := utils.StrTo(value).Int()
+		object := admin.New()
+		if err := admin.Qs().Filter("Id", id).Limit(1).One(object); err != nil {
+			failed++
+			continue
+		}
+		if err := handle(object, target); err != nil {
+			beego.Error("Bulk: ", err)
+			failed++
+		}
+	}
+	admin.changed()
+
+	if failed > 0 {
+		this.FlashRedirect(url, 302, "BulkFailed")
+		return
+	}
+	this.FlashRedirect(url, 302, "BulkSuccess")
+}
+
+// download objects of list as csv or json, ?format=json
+func (this *ModelRouter) Export() {
+	admin := this.admin
+	qs, _ := this.listQs()
+
+	objects := reflect.New(reflect.SliceOf(reflect.TypeOf(this.object).Elem()))
+	if _, err := qs.Limit(exportLimit).All(objects.Interface()); err != nil {
+		beego.Error(err)
+		this.Abort("500")
+		return
+	}
+
+	header := make([]string, 0, len(admin.Columns))
+	for _, column := range admin.Columns {
+		header = append(header, column.Field)
+	}
+
+	var buf bytes.Buffer
+	rows := admin.listRows(this.Lang, objects.Interface())
+	filename := admin.Name + "-" + time.Now().Format("20060102")
+
+	if this.GetString("format") == "json" {
+		data := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			m := make(map[string]string, len(header))
+			for i, cell := range row.Cells {
+				m[header[i]] = cell.String()
+			}
+			data = append(data, m)
+		}
+		if err := json.NewEncoder(&buf).Encode(data); err != nil {
+			beego.Error(err)
+			this.Abort("500")
+			return
+		}
+		this.Ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+		filename += ".json"
+	} else {
+		w := csv.NewWriter(&buf)
+		w.Write(header)
+		for _, row := range rows {
+			record := make([]string, 0, len(row.Cells))
+			for _, cell := range row.Cells {
+				record = append(record, cell.String())
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		this.Ctx.Output.Header("Content-Type", "text/csv; charset=utf-8")
+		filename += ".csv"
+	}
+
+	this.Ctx.Output.Header("Content-Disposition", "attachment; filename="+filename)
+	this.Ctx.Output.Body(buf.Bytes())
+}
+
+// result of one csv row of import
+type ImportResult struct {
+	Line   int
+	Ok     bool
+	Id     int
+	Errors map[string]string
+}
+
+// view for import objects from csv
+func (this *ModelRouter) ImportForm() {
+	if !this.admin.Import {
+		this.Abort("404")
+		return
+	}
+	this.TplNames = "admin/model/import.html"
+	this.Data["Fields"] = importFields(this.admin.Form(nil))
+	this.Data["DryRun"] = true
+}
+
+// view for import objects, with dryrun checked rows are validated only
+func (this *ModelRouter) Import() {
+	if !this.admin.Import {
+		this.Abort("404")
+		return
+	}
+	this.TplNames = "admin/model/import.html"
+	admin := this.admin
+
+	dryRun := this.GetString("dryrun") != ""
+	this.Data["DryRun"] = dryRun
+	this.Data["Fields"] = importFields(admin.Form(nil))
+
+	file, _, err := this.Ctx.Request.FormFile("file")
+	if err != nil {
+		this.Data["ImportError"] = this.Tr("admin.import_no_file")
+		return
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		this.Data["ImportError"] = this.Tr("admin.import_bad_file")
+		return
+	}
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+	}
+
+	var results []ImportResult
+	created := 0
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(results) >= importLimit {
+			this.Data["ImportError"] = this.Tr("admin.import_too_many", importLimit)
+			break
+		}
+
+		result := ImportResult{Line: line}
+		if err != nil {
+			result.Errors = map[string]string{"csv": err.Error()}
+			results = append(results, result)
+			continue
+		}
+
+		values := make(url.Values, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				values.Set(name, record[i])
+			}
+		}
+
+		form := admin.Form(nil)
+		utils.ParseForm(form, values)
+		valid := validation.Validation{}
+		if ok, _ := valid.Valid(form); !ok {
+			result.Errors = make(map[string]string, len(valid.Errors))
+			for _, e := range valid.Errors {
+				result.Errors[e.Key] = this.Tr(e.Message)
+			}
+			results = append(results, result)
+			continue
+		}
+
+		if !dryRun {
+			object := admin.New()
+			admin.SetTo(form, object)
+			if err := object.(modelObject).Insert(); err != nil {
+				result.Errors = map[string]string{"insert": err.Error()}
+				results = append(results, result)
+				continue
+			}
+			result.Id = objectId(object)
+			created++
+			this.auditImport(result.Id)
+		}
+
+		result.Ok = true
+		results = append(results, result)
+	}
+
+	if created > 0 {
+		admin.changed()
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Ok {
+			failed++
+		}
+	}
+
+	this.Data["Results"] = results
+	this.Data["Failed"] = failed
+	this.Data["Created"] = created
+	this.Data["Imported"] = true
+}
+
+// import renders report instead of redirect, write audit log of created object here
+func (this *ModelRouter) auditImport(id int) {
+	log := models.AuditLog{
+		Actor:    &this.User,
+		Action:   "import",
+		Model:    this.admin.Name,
+		ObjectId: id,
+		Ip:       this.Ctx.Input.IP(),
+		Path:     this.Ctx.Request.URL.Path,
+	}
+	if err := log.Insert(); err != nil {
+		beego.Error("Audit: ", err)
+	}
+}
+
+// form field names can be csv columns
+func importFields(form interface{}) []string {
+	elm := reflect.Indirect(reflect.ValueOf(form))
+	fields := make([]string, 0, elm.NumField())
+	for i := 0; i < elm.NumField(); i++ {
+		if elm.Type().Field(i).Tag.Get("form") == "-" {
+			continue
+		}
+		fields = append(fields, elm.Type().Field(i).Name)
+	}
+	return fields
+}
+
+// view for create object
+func (this *ModelRouter) Create() {
+	form := this.admin.Form(nil)
+	prefillForm(form, this.Input())
+	this.SetFormSets(form)
+}
+
+// view for new object save
+func (this *ModelRouter) Save() {
+	form := this.admin.Form(nil)
+	if !this.ValidFormSets(form) {
+		return
+	}
+
+	object := this.admin.New()
+	this.admin.SetTo(form, object)
+	if err := object.(modelObject).Insert(); err == nil {
+		this.admin.changed()
+		id := objectId(object)
+		this.FlashRedirect(fmt.Sprintf("/admin/%s/%d", this.admin.Name, id), 302, "CreateSuccess")
+		return
+	} else {
+		beego.Error(err)
+		this.Data["Error"] = err
+	}
+}
+
+// view for edit object
+func (this *ModelRouter) Edit() {
+	form := this.admin.Form(this.object)
+	this.admin.SetFrom(form, this.object)
+	this.SetFormSets(form)
+}
+
+// view for update object
+func (this *ModelRouter) Update() {
+	form := this.admin.Form(this.object)
+	if !this.ValidFormSets(form) {
+		return
+	}
+
+	// get changed field names
+	changes := utils.FormChanges(this.object, form)
+
+	url := fmt.Sprintf("/admin/%s/%d", this.admin.Name, objectId(this.object))
+
+	// update changed fields only
+	if len(changes) > 0 {
+		for _, field := range this.admin.UpdateFields {
+			if !this.admin.hasField(changes, field) {
+				changes = append(changes, field)
+			}
+		}
+		this.admin.SetTo(form, this.object)
+		if err := this.object.(modelObject).Update(changes...); err == nil {
+			this.admin.changed()
+			this.FlashRedirect(url, 302, "UpdateSuccess")
+			return
+		} else {
+			beego.Error(err)
+			this.Data["Error"] = err
+		}
+	} else {
+		this.Redirect(url, 302)
+	}
+}
+
+// view for confirm delete object
+func (this *ModelRouter) Confirm() {
+}
+
+// view for delete object
+func (this *ModelRouter) Delete() {
+	if this.FormOnceNotMatch() {
+		return
+	}
+
+	url := "/admin/" + this.admin.Name
+
+	if err := this.admin.deleteObject(this.object, ""); err == nil {
+		this.admin.changed()
+		this.FlashRedirect(url, 302, "DeleteSuccess")
+		return
+	} else if reason, ok := err.(DeleteNotAllowed); ok {
+		this.FlashRedirect(url, 302, "DeleteNotAllowed", string(reason))
+		return
+	} else {
+		beego.Error(err)
+		this.Data["Error"] = err
+	}
+}
+
+// set form fields named in query, as /admin/rolegrant/new?user=1
+func prefillForm(form interface{}, values url.Values) {
+	elm := reflect.Indirect(reflect.ValueOf(form))
+	for i := 0; i < elm.NumField(); i++ {
+		fT := elm.Type().Field(i)
+		if fT.Tag.Get("form") == "-" {
+			continue
+		}
+		value := values.Get(strings.ToLower(fT.Name))
+		if value == "" {
+			continue
+		}
+		field := elm.Field(i)
+		switch field.Kind() {
+		case reflect.Bool:
+			b, _ := utils.StrTo(value).Bool()
+			field.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, _ := utils.StrTo(value).Int64()
+			field.SetInt(n)
+		case reflect.String:
+			field.SetString(value)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/perm"
+	"github.com/varding/wetalk/modules/post"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// ModerationAdminRouter serves the queue of content held by spam check.
+type ModerationAdminRouter struct {
+	BaseAdminRouter
+}
+
+// ModeratorPerm implemented moderators can review held content in their scope.
+func (this *ModerationAdminRouter) ModeratorPerm() string {
+	return perm.ContentReview
+}
+
+// List implemented pending held content list.
+func (this *ModerationAdminRouter) List() {
+	this.TplNames = "admin/moderation/list.html"
+	this.Data["moderationAdmin"] = true
+
+	status := setting.HELD_PENDING
+	switch this.GetString("status") {
+	case "approved":
+		status = setting.HELD_APPROVED
+	case "rejected":
+		status = setting.HELD_REJECTED
+	}
+	this.Data["Status"] = status
+
+	qs := models.HeldContents().Filter("Status", status)
+	cnt, _ := qs.Count()
+	p := this.SetPaginator(20, cnt)
+
+	var items []models.HeldContent
+	if _, err := qs.Limit(p.PerPageNums, p.Offset()).RelatedSel().All(&items); err != nil {
+		this.Data["Error"] = err
+		beego.Error(err)
+	}
+	this.Data["Objects"] = items
+}
+
+// Handle implemented approve or reject held content.
+func (this *ModerationAdminRouter) Handle() {
+	id, _ := utils.StrTo(this.GetString(":id")).Int()
+	held := models.HeldContent{Id: id}
+	if err := held.Read(); err != nil {
+		this.Abort("404")
+		return
+	}
+
+	scope := perm.Scope{Category: held.Category, Topic: held.Topic}
+	if !perm.Can(&this.User, perm.ContentReview, scope) {
+		this.FlashRedirect("/admin/moderation", 302, "HandleFailed")
+		return
+	}
+
+	var err error
+	switch this.GetString("action") {
+	case "approve":
+		err = post.ApproveHeldContent(&held, &this.User)
+	case "reject":
+		err = post.RejectHeldContent(&held, &this.User)
+	default:
+		this.Abort("404")
+		return
+	}
+
+	if err != nil {
+		beego.Error("Moderation: ", err)
+		this.FlashRedirect("/admin/moderation", 302, "HandleFailed")
+		return
+	}
+
+	this.FlashRedirect("/admin/moderation", 302, "HandleSuccess")
+}
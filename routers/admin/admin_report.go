@@ -0,0 +1,98 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/perm"
+	"github.com/varding/wetalk/modules/post"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// ReportAdminRouter serves the queue of user reports.
+type ReportAdminRouter struct {
+	BaseAdminRouter
+}
+
+// ModeratorPerm implemented moderators can handle reports in their scope.
+func (this *ReportAdminRouter) ModeratorPerm() string {
+	return perm.ReportHandle
+}
+
+// List implemented report list filtered by status.
+func (this *ReportAdminRouter) List() {
+	this.TplNames = "admin/report/list.html"
+	this.Data["reportAdmin"] = true
+
+	status := setting.REPORT_OPEN
+	switch this.GetString("status") {
+	case "resolved":
+		status = setting.REPORT_RESOLVED
+	case "dismissed":
+		status = setting.REPORT_DISMISSED
+	}
+	this.Data["Status"] = status
+	this.Data["Actions"] = post.ReportActions
+
+	qs := models.Reports().Filter("Status", status)
+	cnt, _ := qs.Count()
+	p := this.SetPaginator(20, cnt)
+
+	var reports []models.Report
+	if _, err := qs.Limit(p.PerPageNums, p.Offset()).RelatedSel().All(&reports); err != nil {
+		this.Data["Error"] = err
+		beego.Error(err)
+	}
+
+	reasons := make(map[int]string, len(reports))
+	for _, report := range reports {
+		reasons[report.Id] = post.ReportReasonKey(report.Reason)
+	}
+	this.Data["Objects"] = reports
+	this.Data["Reasons"] = reasons
+}
+
+// Bulk implemented handle selected reports with one action.
+func (this *ReportAdminRouter) Bulk() {
+	action := this.GetString("action")
+	note := this.GetString("note")
+
+	failed := 0
+	for _, value := range this.GetStrings("ids") {
+		id, _ := utils.StrTo(value).Int()
+		report := models.Report{Id: id}
+		if err := report.Read(); err != nil || !report.IsOpen() {
+			failed++
+			continue
+		}
+		if !post.CanHandleReport(&this.User, &report, action) {
+			failed++
+			continue
+		}
+		if err := post.HandleReport(&report, &this.User, action, note); err != nil {
+			beego.Error("Report: ", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		this.FlashRedirect("/admin/report", 302, "HandleFailed")
+		return
+	}
+	this.FlashRedirect("/admin/report", 302, "HandleSuccess")
+}
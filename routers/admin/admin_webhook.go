@@ -0,0 +1,165 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/modules/webhook"
+)
+
+type WebhookAdminRouter struct {
+	ModelAdminRouter
+	object models.Webhook
+}
+
+func (this *WebhookAdminRouter) Object() interface{} {
+	return &this.object
+}
+
+func (this *WebhookAdminRouter) ObjectQs() orm.QuerySeter {
+	return models.Webhooks()
+}
+
+// view for list model data
+func (this *WebhookAdminRouter) List() {
+	var hooks []models.Webhook
+	qs := models.Webhooks()
+	if err := this.SetObjects(qs, &hooks); err != nil {
+		this.Data["Error"] = err
+		beego.Error(err)
+	}
+}
+
+// view for create object
+func (this *WebhookAdminRouter) Create() {
+	form := webhook.WebhookAdminForm{Create: true, IsActive: true}
+	this.SetFormSets(&form)
+}
+
+// view for new object save
+func (this *WebhookAdminRouter) Save() {
+	form := webhook.WebhookAdminForm{Create: true}
+	if this.ValidFormSets(&form) == false {
+		return
+	}
+
+	var hook models.Webhook
+	form.SetToWebhook(&hook)
+	if err := hook.Insert(); err == nil {
+		this.FlashRedirect(fmt.Sprintf("/admin/webhook/%d", hook.Id), 302, "CreateSuccess")
+		return
+	} else {
+		beego.Error(err)
+		this.Data["Error"] = err
+	}
+}
+
+// recent deliveries of the webhook
+func (this *WebhookAdminRouter) setDeliveries() {
+	var deliveries []models.WebhookDelivery
+	this.object.Deliveries().Limit(30).All(&deliveries)
+	this.Data["Deliveries"] = deliveries
+}
+
+// view for edit object
+func (this *WebhookAdminRouter) Edit() {
+	form := webhook.WebhookAdminForm{}
+	form.SetFromWebhook(&this.object)
+	this.SetFormSets(&form)
+	this.setDeliveries()
+}
+
+// view for update object
+func (this *WebhookAdminRouter) Update() {
+	form := webhook.WebhookAdminForm{Id: this.object.Id}
+	if this.ValidFormSets(&form) == false {
+		this.setDeliveries()
+		return
+	}
+
+	// get changed field names, events compared after joined
+	changes := utils.FormChanges(&this.object, &form, "Events")
+	events := this.object.Events
+
+	url := fmt.Sprintf("/admin/webhook/%d", this.object.Id)
+
+	form.SetToWebhook(&this.object)
+	if this.object.Events != events {
+		changes = append(changes, "Events")
+	}
+
+	// update changed fields only
+	if len(changes) > 0 {
+		if err := this.object.Update(changes...); err == nil {
+			this.FlashRedirect(url, 302, "UpdateSuccess")
+			return
+		} else {
+			beego.Error(err)
+			this.Data["Error"] = err
+			this.setDeliveries()
+		}
+	} else {
+		this.Redirect(url, 302)
+	}
+}
+
+// resend a delivery of the webhook
+func (this *WebhookAdminRouter) Redeliver() {
+	url := fmt.Sprintf("/admin/webhook/%d", this.object.Id)
+
+	id, _ := this.GetInt("delivery")
+	delivery := models.WebhookDelivery{Id: int(id)}
+	if err := delivery.Read(); err != nil || delivery.Webhook.Id != this.object.Id {
+		this.Abort("404")
+		return
+	}
+
+	if _, err := webhook.Redeliver(&delivery); err != nil {
+		beego.Error(err)
+		this.Data["Error"] = err
+		this.Edit()
+		return
+	}
+
+	this.FlashRedirect(url, 302, "RedeliverSuccess")
+}
+
+// view for confirm delete object
+func (this *WebhookAdminRouter) Confirm() {
+}
+
+// view for delete object
+func (this *WebhookAdminRouter) Delete() {
+	if this.FormOnceNotMatch() {
+		return
+	}
+
+	// delete delivery logs of the webhook
+	this.object.Deliveries().Delete()
+
+	if err := this.object.Delete(); err == nil {
+		this.FlashRedirect("/admin/webhook", 302, "DeleteSuccess")
+		return
+	} else {
+		beego.Error(err)
+		this.Data["Error"] = err
+	}
+}
@@ -16,8 +16,6 @@ package admin
 
 import (
 	"github.com/astaxie/beego/orm"
-
-	"github.com/varding/wetalk/modules/models"
 )
 
 type AdminRouter struct {
@@ -42,19 +40,12 @@ func (this *AdminRouter) ModelGet() {
 		this.ServeJson()
 	}()
 
-	var qs orm.QuerySeter
-
-	switch model {
-	case "User":
-		qs = models.Users()
+	admin := getModelAdmin(model)
+	if admin == nil || admin.Label == "" {
+		return
 	}
 
-	qs = qs.Filter("Id", id).Limit(1)
-
-	switch model {
-	case "User":
-		qs.ValuesList(&data, "Id", "UserName")
-	}
+	orm.NewOrm().QueryTable(admin.New()).Filter("Id", id).Limit(1).ValuesList(&data, "Id", admin.Label)
 }
 
 func (this *AdminRouter) ModelSelect() {
@@ -79,8 +70,15 @@ func (this *AdminRouter) ModelSelect() {
 		return
 	}
 
-	switch model {
-	case "User":
-		models.Users().Filter("UserName__icontains", search).Limit(10).ValuesList(&data, "Id", "UserName")
+	admin := getModelAdmin(model)
+	if admin == nil || admin.Label == "" {
+		return
+	}
+
+	// search by id when label is id
+	field := admin.Label + "__icontains"
+	if admin.Label == "Id" {
+		field = "Id"
 	}
+	orm.NewOrm().QueryTable(admin.New()).Filter(field, search).Limit(10).ValuesList(&data, "Id", admin.Label)
 }
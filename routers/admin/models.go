@@ -0,0 +1,412 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"github.com/astaxie/beego/orm"
+	"github.com/beego/i18n"
+
+	"github.com/varding/wetalk/modules/account"
+	"github.com/varding/wetalk/modules/auth"
+	"github.com/varding/wetalk/modules/bulletin"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/page"
+	"github.com/varding/wetalk/modules/perm"
+	"github.com/varding/wetalk/modules/post"
+	"github.com/varding/wetalk/modules/spam"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// bulk action sets a field of objects
+func setFieldAction(name, field string, value interface{}) BulkAction {
+	return BulkAction{
+		Name: name,
+		Handle: func(object interface{}, target string) error {
+			_, err := orm.NewOrm().QueryTable(object).Filter("Id", objectId(object)).Update(orm.Params{field: value})
+			return err
+		},
+	}
+}
+
+func topicChoices() [][]string {
+	var topics []models.Topic
+	post.ListTopics(&topics)
+	data := make([][]string, 0, len(topics))
+	for _, topic := range topics {
+		data = append(data, []string{topic.Name, utils.ToStr(topic.Id)})
+	}
+	return data
+}
+
+// move post to the topic, category follows the topic
+func movePost(object interface{}, target string) error {
+	id, _ := utils.StrTo(target).Int()
+	topic := models.Topic{Id: id}
+	if err := topic.Read(); err != nil {
+		return err
+	}
+
+	p := object.(*models.Post)
+	p.Topic = &topic
+	p.Category = topic.Category
+	return p.Update("Topic", "Category")
+}
+
+func init() {
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "user",
+		New:  func() interface{} { return new(models.User) },
+		Qs:   func() orm.QuerySeter { return models.Users().RelatedSel() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "user"},
+			{Field: "UserName", Label: "model.user_username", Link: "user"},
+			{Field: "Email", Label: "model.user_email"},
+			{Field: "IsAdmin", Label: "model.user_isadmin"},
+			{Field: "IsActive", Label: "model.user_isactive"},
+			{Field: "IsForbid", Label: "model.user_isforbid"},
+			{Field: "Created", Label: "model.created"},
+			{Field: "Updated", Label: "model.updated"},
+		},
+		Search:  []string{"UserName__icontains", "Email__icontains"},
+		Filters: []string{"IsAdmin", "IsActive", "IsForbid"},
+		Sorts:   []string{"Id", "UserName", "Created", "Updated"},
+		Label:   "UserName",
+		Form: func(object interface{}) interface{} {
+			if object == nil {
+				return &auth.UserAdminForm{Create: true}
+			}
+			return &auth.UserAdminForm{Id: object.(*models.User).Id}
+		},
+		SetFrom: func(form, object interface{}) { form.(*auth.UserAdminForm).SetFromUser(object.(*models.User)) },
+		SetTo:   func(form, object interface{}) { form.(*auth.UserAdminForm).SetToUser(object.(*models.User)) },
+		Actions: []BulkAction{
+			setFieldAction("activate", "IsActive", true),
+			setFieldAction("forbid", "IsForbid", true),
+			setFieldAction("unforbid", "IsForbid", false),
+		},
+		Delete: func(object interface{}) error {
+			user := object.(*models.User)
+			// content of deleted users are reassigned to the ghost user
+			if user.IsGhost {
+				return DeleteNotAllowed("admin.delete_ghost_not_allowed")
+			}
+			return account.DeleteAccount(user)
+		},
+		Import: true,
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "post",
+		New:  func() interface{} { return new(models.Post) },
+		Qs:   func() orm.QuerySeter { return models.Posts().RelatedSel() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "post"},
+			{Field: "Title", Label: "model.post_title", Link: "post"},
+			{Field: "User.UserName", Label: "model.user_username", Link: "user"},
+			{Field: "Topic.Name", Label: "model.topic_name", Link: "topic"},
+			{Field: "Category.Name", Label: "model.category_name", Link: "category"},
+			{Field: "Browsers", Label: "model.post_browsers"},
+			{Field: "Replys", Label: "model.post_replys"},
+			{Field: "Favorites", Label: "model.post_favorites"},
+			{Field: "IsBest", Label: "model.post_best"},
+			{Field: "IsHidden", Label: "model.post_hidden"},
+			{Field: "Created", Label: "model.created"},
+			{Field: "Updated", Label: "model.updated"},
+		},
+		Search:  []string{"Title__icontains"},
+		Filters: []string{"User", "Topic", "Category", "IsBest", "IsHidden"},
+		Sorts:   []string{"Id", "Browsers", "Replys", "Favorites", "Created", "Updated"},
+		Label:   "Title",
+		Form: func(object interface{}) interface{} {
+			form := post.PostAdminForm{Create: object == nil}
+			post.ListTopics(&form.Topics)
+			return &form
+		},
+		SetFrom: func(form, object interface{}) { form.(*post.PostAdminForm).SetFromPost(object.(*models.Post)) },
+		SetTo:   func(form, object interface{}) { form.(*post.PostAdminForm).SetToPost(object.(*models.Post)) },
+		// category is set from topic
+		UpdateFields: []string{"Category"},
+		Perm:         perm.PostEdit,
+		Actions: []BulkAction{
+			{Name: "move", Choices: topicChoices, Handle: movePost},
+			setFieldAction("best", "IsBest", true),
+			setFieldAction("unbest", "IsBest", false),
+			setFieldAction("hide", "IsHidden", true),
+			setFieldAction("unhide", "IsHidden", false),
+		},
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "comment",
+		New:  func() interface{} { return new(models.Comment) },
+		Qs:   func() orm.QuerySeter { return models.Comments().RelatedSel() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "comment"},
+			{Field: "Post.Title", Label: "model.post_title", Link: "post"},
+			{Field: "User.UserName", Label: "model.user_username", Link: "user"},
+			{Field: "Created", Label: "model.created"},
+		},
+		Search:  []string{"Message__icontains"},
+		Filters: []string{"User", "Post", "Status"},
+		Sorts:   []string{"Id", "Created"},
+		Label:   "Id",
+		Form: func(object interface{}) interface{} {
+			return &post.CommentAdminForm{Create: object == nil}
+		},
+		SetFrom: func(form, object interface{}) { form.(*post.CommentAdminForm).SetFromComment(object.(*models.Comment)) },
+		SetTo:   func(form, object interface{}) { form.(*post.CommentAdminForm).SetToComment(object.(*models.Comment)) },
+		Perm:    perm.CommentDelete,
+		Actions: []BulkAction{
+			setFieldAction("hide", "Status", setting.COMMENT_STATUS_HIDDEN),
+			setFieldAction("unhide", "Status", setting.COMMENT_STATUS_NORMAL),
+		},
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "topic",
+		New:  func() interface{} { return new(models.Topic) },
+		Qs:   func() orm.QuerySeter { return models.Topics().OrderBy("-Category__id").RelatedSel() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "topic"},
+			{Field: "Name", Label: "model.topic_name", Link: "topic"},
+			{Field: "Slug", Label: "model.topic_slug"},
+			{Field: "Order", Label: "model.topic_order"},
+			{Field: "Created", Label: "model.created"},
+			{Field: "Updated", Label: "model.updated"},
+			{Field: "Category.Name", Label: "model.category", Link: "category"},
+		},
+		Search:  []string{"Name__icontains", "Slug__icontains"},
+		Filters: []string{"Category"},
+		Sorts:   []string{"Id", "Name", "Order", "Followers", "Created"},
+		Label:   "Name",
+		Form: func(object interface{}) interface{} {
+			if object == nil {
+				return &post.TopicAdminForm{Create: true}
+			}
+			return &post.TopicAdminForm{Id: object.(*models.Topic).Id}
+		},
+		SetFrom: func(form, object interface{}) { form.(*post.TopicAdminForm).SetFromTopic(object.(*models.Topic)) },
+		SetTo:   func(form, object interface{}) { form.(*post.TopicAdminForm).SetToTopic(object.(*models.Topic)) },
+		Delete: func(object interface{}) error {
+			topic := object.(*models.Topic)
+			// check whether there are posts under this topic
+			if cnt, _ := models.Posts().Filter("Topic__id", topic.Id).Count(); cnt > 0 {
+				return DeleteNotAllowed("admin.delete_topic_not_allowed")
+			}
+			return topic.Delete()
+		},
+		Import: true,
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "category",
+		New:  func() interface{} { return new(models.Category) },
+		Qs:   func() orm.QuerySeter { return models.Categories().RelatedSel() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "category"},
+			{Field: "Name", Label: "model.category_name", Link: "category"},
+			{Field: "Slug", Label: "model.category_slug"},
+			{Field: "Order", Label: "model.category_order"},
+		},
+		Search: []string{"Name__icontains", "Slug__icontains"},
+		Sorts:  []string{"Id", "Name", "Order"},
+		Label:  "Name",
+		Form: func(object interface{}) interface{} {
+			if object == nil {
+				return &post.CategoryAdminForm{Create: true}
+			}
+			return &post.CategoryAdminForm{Id: object.(*models.Category).Id}
+		},
+		SetFrom: func(form, object interface{}) {
+			form.(*post.CategoryAdminForm).SetFromCategory(object.(*models.Category))
+		},
+		SetTo: func(form, object interface{}) {
+			form.(*post.CategoryAdminForm).SetToCategory(object.(*models.Category))
+		},
+		Delete: func(object interface{}) error {
+			cat := object.(*models.Category)
+			// check whether there are topics under the category
+			if cnt, _ := models.Topics().Filter("Category__Id", cat.Id).Count(); cnt > 0 {
+				return DeleteNotAllowed("admin.delete_category_not_allowed")
+			}
+			return cat.Delete()
+		},
+		Import: true,
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "page",
+		New:  func() interface{} { return new(models.Page) },
+		Qs:   func() orm.QuerySeter { return models.Pages().RelatedSel() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "page"},
+			{Field: "Title", Label: "model.page_title", Link: "page"},
+			{Field: "Uri", Label: "model.page_uri"},
+			{Field: "User.UserName", Label: "model.user_username", Link: "user"},
+			{Field: "IsPublish", Label: "model.page_ispublish"},
+			{Field: "Created", Label: "model.created"},
+			{Field: "Updated", Label: "model.updated"},
+		},
+		Search:  []string{"Title__icontains", "Uri__icontains"},
+		Filters: []string{"IsPublish"},
+		Sorts:   []string{"Id", "Created", "Updated"},
+		Label:   "Title",
+		Form: func(object interface{}) interface{} {
+			return &page.PageAdminForm{Create: object == nil}
+		},
+		SetFrom: func(form, object interface{}) { form.(*page.PageAdminForm).SetFromPage(object.(*models.Page)) },
+		SetTo:   func(form, object interface{}) { form.(*page.PageAdminForm).SetToPage(object.(*models.Page)) },
+		Actions: []BulkAction{
+			setFieldAction("publish", "IsPublish", true),
+			setFieldAction("unpublish", "IsPublish", false),
+		},
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "bulletin",
+		New:  func() interface{} { return new(models.Bulletin) },
+		Qs:   func() orm.QuerySeter { return models.Bulletins().OrderBy("Type") },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "bulletin"},
+			{Field: "Name", Label: "model.bulletin_name", Link: "bulletin"},
+			{Field: "Url", Label: "model.bulletin_url"},
+			{Field: "Type", Label: "model.bulletin_type", Format: func(lang string, value interface{}) string {
+				return utils.GetBulletinType(lang, value.(int))
+			}},
+		},
+		Search:  []string{"Name__icontains"},
+		Filters: []string{"Type"},
+		Sorts:   []string{"Id", "Type"},
+		Label:   "Name",
+		Form: func(object interface{}) interface{} {
+			if object == nil {
+				return &bulletin.BulletinAdminForm{Create: true}
+			}
+			return &bulletin.BulletinAdminForm{Id: object.(*models.Bulletin).Id}
+		},
+		SetFrom: func(form, object interface{}) {
+			form.(*bulletin.BulletinAdminForm).SetFromBulletin(object.(*models.Bulletin))
+		},
+		SetTo: func(form, object interface{}) {
+			form.(*bulletin.BulletinAdminForm).SetToBulletin(object.(*models.Bulletin))
+		},
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "spamrule",
+		New:  func() interface{} { return new(models.SpamRule) },
+		Qs:   func() orm.QuerySeter { return models.SpamRules() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "spamrule"},
+			{Field: "Pattern", Label: "model.spamrule_pattern", Link: "spamrule"},
+			{Field: "IsRegex", Label: "model.spamrule_isregex"},
+			{Field: "Action", Label: "model.spamrule_action", Format: func(lang string, value interface{}) string {
+				if value.(int) == setting.SPAM_ACTION_REJECT {
+					return i18n.Tr(lang, "model.spamrule_action_reject")
+				}
+				return i18n.Tr(lang, "model.spamrule_action_hold")
+			}},
+			{Field: "IsActive", Label: "model.spamrule_isactive"},
+		},
+		Search:  []string{"Pattern__icontains"},
+		Filters: []string{"IsRegex", "Action", "IsActive"},
+		Sorts:   []string{"Id"},
+		Label:   "Pattern",
+		Form: func(object interface{}) interface{} {
+			if object == nil {
+				return &spam.SpamRuleAdminForm{Create: true, IsActive: true}
+			}
+			return &spam.SpamRuleAdminForm{Id: object.(*models.SpamRule).Id}
+		},
+		SetFrom: func(form, object interface{}) {
+			form.(*spam.SpamRuleAdminForm).SetFromSpamRule(object.(*models.SpamRule))
+		},
+		SetTo: func(form, object interface{}) {
+			form.(*spam.SpamRuleAdminForm).SetToSpamRule(object.(*models.SpamRule))
+		},
+		Changed: spam.ReloadRules,
+		Actions: []BulkAction{
+			setFieldAction("enable", "IsActive", true),
+			setFieldAction("disable", "IsActive", false),
+		},
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "role",
+		New:  func() interface{} { return new(models.Role) },
+		Qs:   func() orm.QuerySeter { return models.Roles() },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "role"},
+			{Field: "Name", Label: "model.role_name", Link: "role"},
+			{Field: "Permissions", Label: "model.role_permissions"},
+			{Field: "Updated", Label: "model.updated"},
+		},
+		Search: []string{"Name__icontains"},
+		Sorts:  []string{"Id", "Name", "Updated"},
+		Label:  "Name",
+		Form: func(object interface{}) interface{} {
+			if object == nil {
+				return &perm.RoleAdminForm{Create: true}
+			}
+			return &perm.RoleAdminForm{Id: object.(*models.Role).Id}
+		},
+		SetFrom:      func(form, object interface{}) { form.(*perm.RoleAdminForm).SetFromRole(object.(*models.Role)) },
+		SetTo:        func(form, object interface{}) { form.(*perm.RoleAdminForm).SetToRole(object.(*models.Role)) },
+		UpdateFields: []string{"Name", "Description", "Permissions", "Updated"},
+		// grants are cascade deleted with role
+		Changed: perm.Reload,
+	})
+
+	RegisterModelAdmin(&ModelAdmin{
+		Name: "rolegrant",
+		New:  func() interface{} { return new(models.RoleGrant) },
+		// null scopes only joined by name
+		Qs: func() orm.QuerySeter { return models.RoleGrants().RelatedSel("User", "Role", "Category", "Topic") },
+		Columns: []Column{
+			{Field: "Id", Label: "Id", Link: "rolegrant"},
+			{Field: "User.UserName", Label: "model.user", Link: "user"},
+			{Field: "Role.Name", Label: "model.role", Link: "role"},
+			{Field: "Category.Name", Label: "model.category", Format: grantScopeFormat},
+			{Field: "Topic.Name", Label: "model.topic", Format: grantScopeFormat},
+			{Field: "Created", Label: "model.created"},
+		},
+		Filters: []string{"User", "Role", "Category", "Topic"},
+		Sorts:   []string{"Id", "Created"},
+		Label:   "Id",
+		Form: func(object interface{}) interface{} {
+			if object == nil {
+				return &perm.RoleGrantAdminForm{Create: true}
+			}
+			return &perm.RoleGrantAdminForm{Id: object.(*models.RoleGrant).Id}
+		},
+		SetFrom: func(form, object interface{}) {
+			form.(*perm.RoleGrantAdminForm).SetFromRoleGrant(object.(*models.RoleGrant))
+		},
+		SetTo: func(form, object interface{}) {
+			form.(*perm.RoleGrantAdminForm).SetToRoleGrant(object.(*models.RoleGrant))
+		},
+		UpdateFields: []string{"User", "Role", "Category", "Topic"},
+		Changed:      perm.Reload,
+	})
+}
+
+// null scope of grant matches any
+func grantScopeFormat(lang string, value interface{}) string {
+	if value == nil {
+		return i18n.Tr(lang, "model.grant_any")
+	}
+	return utils.ToStr(value)
+}
@@ -0,0 +1,304 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package admin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// column of model list
+// Field: field name, field of related object is reached by dot, as "User.UserName"
+// Label: locale key of column title
+// Link: admin model name of the object owns the field, cell links to its edit page
+// Format: optional, display text of the value
+type Column struct {
+	Field  string
+	Label  string
+	Link   string
+	Format func(lang string, value interface{}) string
+}
+
+// action applied to selected objects of model list
+// Name: action name, also locale key admin.bulk_<name>
+// Choices: optional, [label, value] list of the target, as topic of moving posts
+type BulkAction struct {
+	Name    string
+	Choices func() [][]string
+	Handle  func(object interface{}, target string) error
+}
+
+// ModelAdmin declares how a model is managed in admin pages.
+// Name is the url name, templates of edit, new and delete are in views/admin/<name>/.
+type ModelAdmin struct {
+	Name string
+
+	// New returns pointer of a new model object
+	New func() interface{}
+
+	// Qs returns query of objects, with related objects selected
+	Qs func() orm.QuerySeter
+
+	Columns []Column
+
+	// fields matched by search keyword, with operator, as "UserName__icontains"
+	Search []string
+
+	// fields can be filtered by query value named in lower case
+	Filters []string
+
+	// fields can be sorted by query value sort=Field or sort=-Field
+	Sorts []string
+
+	// field shown as text of model select
+	Label string
+
+	// Form returns pointer of form, object is nil when create
+	Form    func(object interface{}) interface{}
+	SetFrom func(form, object interface{})
+	SetTo   func(form, object interface{})
+
+	// fields always updated even they are not changed
+	UpdateFields []string
+
+	// optional, Delete checks and deletes the object
+	Delete func(object interface{}) error
+
+	// optional, called after any object changed
+	Changed func()
+
+	// moderators with site wide grant of the permission can manage the model,
+	// empty is admin only
+	Perm string
+
+	Actions []BulkAction
+
+	// objects can be created from csv, columns are named by form fields
+	Import bool
+}
+
+// error of Delete when the object can't be deleted, value is locale key of the reason
+type DeleteNotAllowed string
+
+func (e DeleteNotAllowed) Error() string {
+	return string(e)
+}
+
+// object methods every model implemented
+type modelObject interface {
+	Insert() error
+	Update(fields ...string) error
+	Delete() error
+}
+
+var modelAdmins = make(map[string]*ModelAdmin)
+
+// RegisterModelAdmin adds model to admin pages, call it in init
+func RegisterModelAdmin(admin *ModelAdmin) {
+	if _, ok := modelAdmins[admin.Name]; ok {
+		panic("admin model registered twice: " + admin.Name)
+	}
+	modelAdmins[admin.Name] = admin
+}
+
+// ModelAdmins returns registered model names in sorted order
+func ModelAdmins() []string {
+	names := make([]string, 0, len(modelAdmins))
+	for name := range modelAdmins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// id of model object
+func objectId(object interface{}) int {
+	return int(reflect.Indirect(reflect.ValueOf(object)).FieldByName("Id").Int())
+}
+
+// find model admin by name, case insensitive
+func getModelAdmin(name string) *ModelAdmin {
+	return modelAdmins[strings.ToLower(name)]
+}
+
+func (m *ModelAdmin) hasField(fields []string, name string) bool {
+	for _, field := range fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ModelAdmin) deleteObject(object interface{}, target string) error {
+	if m.Delete != nil {
+		return m.Delete(object)
+	}
+	return object.(modelObject).Delete()
+}
+
+func (m *ModelAdmin) changed() {
+	if m.Changed != nil {
+		m.Changed()
+	}
+}
+
+// one cell of model list
+type ListCell struct {
+	Value  string
+	IsBool bool
+	Bool   bool
+	Link   string
+}
+
+// plain text of cell, for export
+func (c ListCell) String() string {
+	if c.IsBool {
+		return utils.ToStr(c.Bool)
+	}
+	return c.Value
+}
+
+type ListRow struct {
+	Id    int
+	Cells []ListCell
+}
+
+// create list rows from a slice of objects
+func (m *ModelAdmin) listRows(lang string, objects interface{}) []ListRow {
+	slice := reflect.Indirect(reflect.ValueOf(objects))
+	rows := make([]ListRow, 0, slice.Len())
+
+	for i := 0; i < slice.Len(); i++ {
+		elm := reflect.Indirect(slice.Index(i))
+		row := ListRow{Id: objectId(elm.Addr().Interface())}
+
+		for _, column := range m.Columns {
+			row.Cells = append(row.Cells, m.listCell(lang, elm, column))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (m *ModelAdmin) listCell(lang string, elm reflect.Value, column Column) (cell ListCell) {
+	owner := elm
+	value := elm
+	for _, name := range strings.Split(column.Field, ".") {
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if column.Format != nil {
+					cell.Value = column.Format(lang, nil)
+				}
+				return
+			}
+			value = value.Elem()
+		}
+		owner = value
+		value = value.FieldByName(name)
+		if !value.IsValid() {
+			return
+		}
+	}
+
+	if column.Link != "" {
+		cell.Link = fmt.Sprintf("%sadmin/%s/%d", setting.AppUrl, column.Link, owner.FieldByName("Id").Int())
+	}
+
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		if column.Format != nil {
+			cell.Value = column.Format(lang, nil)
+		}
+		return
+	}
+
+	v := value.Interface()
+	switch {
+	case column.Format != nil:
+		cell.Value = column.Format(lang, v)
+	case value.Kind() == reflect.Bool:
+		cell.IsBool = true
+		cell.Bool = value.Bool()
+	default:
+		if t, ok := v.(time.Time); ok {
+			cell.Value = beego.Date(t, setting.DateTimeFormat)
+		} else {
+			cell.Value = utils.ToStr(v)
+		}
+	}
+	return
+}
+
+// convert query value to the type of model field
+func (m *ModelAdmin) filterValue(name, value string) (interface{}, bool) {
+	elm := reflect.Indirect(reflect.ValueOf(m.New()))
+	field, ok := elm.Type().FieldByName(name)
+	if !ok {
+		return nil, false
+	}
+
+	switch field.Type.Kind() {
+	case reflect.Bool:
+		b, err := utils.StrTo(value).Bool()
+		return b, err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Ptr:
+		// related object is filtered by id
+		n, err := utils.StrTo(value).Int()
+		return n, err == nil
+	case reflect.String:
+		return value, true
+	}
+	return nil, false
+}
+
+// names and types of filters, for list template
+type ListFilter struct {
+	Name   string
+	Label  string
+	Value  string
+	IsBool bool
+}
+
+func (m *ModelAdmin) listFilters(values func(string) string) []ListFilter {
+	elm := reflect.Indirect(reflect.ValueOf(m.New()))
+	filters := make([]ListFilter, 0, len(m.Filters))
+	for _, field := range m.Filters {
+		f, _ := elm.Type().FieldByName(field)
+		filter := ListFilter{
+			Name:   strings.ToLower(field),
+			Label:  field,
+			IsBool: f.Type.Kind() == reflect.Bool,
+		}
+		filter.Value = values(filter.Name)
+		// use title of the column shows the field
+		for _, column := range m.Columns {
+			if column.Field == field || strings.HasPrefix(column.Field, field+".") {
+				filter.Label = column.Label
+				break
+			}
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
@@ -15,7 +15,8 @@
 package api
 
 import (
-	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/modules/markdown"
+	"github.com/varding/wetalk/modules/playground"
 )
 
 func (this *ApiRouter) Markdown() {
@@ -31,7 +32,18 @@ func (this *ApiRouter) Markdown() {
 		switch action {
 		case "preview":
 			content := this.GetString("content")
-			result["preview"] = utils.RenderMarkdown(content)
+			result["preview"] = markdown.Render(this.GetString("profile"), content)
+			result["success"] = true
+		case "gofmt":
+			content, errs := playground.FormatMarkdown(this.GetString("content"))
+			result["content"] = content
+			if len(errs) > 0 {
+				var messages []string
+				for _, err := range errs {
+					messages = append(messages, err.Error())
+				}
+				result["errors"] = messages
+			}
 			result["success"] = true
 		}
 		this.Data["json"] = result
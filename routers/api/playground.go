@@ -0,0 +1,75 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package api
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/playground"
+)
+
+// run, format and share go snippets on playground
+func (this *ApiRouter) Playground() {
+	if !this.IsAjax() {
+		return
+	}
+
+	result := map[string]interface{}{
+		"success": false,
+	}
+	code := this.GetString("code")
+
+	var err error
+	switch this.GetString("action") {
+	case "run":
+		var res *playground.Result
+		if res, err = playground.Run(code); err == nil {
+			result["errors"] = res.Errors
+			result["vet_errors"] = res.VetErrors
+			result["output"] = res.Output()
+			result["status"] = res.Status
+			result["success"] = true
+		}
+	case "format":
+		var body string
+		if body, err = playground.Format(code); err == nil {
+			result["code"] = body
+			result["success"] = true
+		} else if e, ok := err.(playground.SyntaxError); ok {
+			result["errors"] = e.Error()
+			err = nil
+		}
+	case "share":
+		var link string
+		if link, err = playground.Share(code); err == nil {
+			result["url"] = link
+			result["success"] = true
+		}
+	}
+
+	switch err {
+	case nil:
+	case playground.ErrTooLarge:
+		result["errors"] = this.Tr("playground.code_too_large")
+	case playground.ErrDisabled:
+		result["errors"] = this.Tr("playground.disabled")
+	default:
+		beego.Error("Playground: ", err)
+		result["errors"] = this.Tr("playground.failed")
+	}
+
+	this.Data["json"] = result
+	this.ServeJson()
+}
@@ -3,6 +3,10 @@ package api
 import (
 	"github.com/astaxie/beego/orm"
 	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/perm"
+	"github.com/varding/wetalk/modules/post"
+	"github.com/varding/wetalk/modules/webhook"
+	"github.com/varding/wetalk/setting"
 )
 
 func (this *ApiRouter) Post() {
@@ -17,16 +21,63 @@ func (this *ApiRouter) Post() {
 		action := this.GetString("action")
 		switch action {
 		case "toggle-best":
-			if !this.User.IsAdmin {
-				result["success"] = false
-			} else {
-				if postId, err := this.GetInt("post"); err == nil {
-					//set post best
-					var post models.Post
-					if err := orm.NewOrm().QueryTable("post").Filter("Id", postId).One(&post); err == nil {
+			if postId, err := this.GetInt("post"); err == nil {
+				//set post best
+				var post models.Post
+				if err := orm.NewOrm().QueryTable("post").Filter("Id", postId).One(&post); err == nil {
+					if perm.Can(&this.User, perm.PostBest, perm.ScopeOfPost(&post)) {
 						post.IsBest = !post.IsBest
 						if post.Update("IsBest") == nil {
 							result["success"] = true
+							if post.IsBest {
+								webhook.PostBest(&post, &this.User)
+							}
+						}
+					}
+				}
+			}
+		case "toggle-lock":
+			if postId, err := this.GetInt("post"); err == nil {
+				var post models.Post
+				if err := orm.NewOrm().QueryTable("post").Filter("Id", postId).One(&post); err == nil {
+					if perm.Can(&this.User, perm.PostLock, perm.ScopeOfPost(&post)) {
+						post.IsLocked = !post.IsLocked
+						if post.Update("IsLocked") == nil {
+							result["success"] = true
+						}
+					}
+				}
+			}
+		case "toggle-hide":
+			if postId, err := this.GetInt("post"); err == nil {
+				var post models.Post
+				if err := orm.NewOrm().QueryTable("post").Filter("Id", postId).One(&post); err == nil {
+					if perm.Can(&this.User, perm.PostHide, perm.ScopeOfPost(&post)) {
+						post.IsHidden = !post.IsHidden
+						if post.Update("IsHidden") == nil {
+							result["success"] = true
+						}
+					}
+				}
+			}
+		case "toggle-comment-hide", "delete-comment":
+			if commentId, err := this.GetInt("comment"); err == nil {
+				var comment models.Comment
+				if err := orm.NewOrm().QueryTable("comment").Filter("Id", commentId).RelatedSel("Post").One(&comment); err == nil {
+					scope := perm.ScopeOfPost(comment.Post)
+					if action == "delete-comment" {
+						if perm.Can(&this.User, perm.CommentDelete, scope) && comment.Delete() == nil {
+							result["success"] = true
+							post.PostReplysCount(comment.Post)
+						}
+					} else if perm.Can(&this.User, perm.CommentHide, scope) {
+						if comment.IsHidden() {
+							comment.Status = setting.COMMENT_STATUS_NORMAL
+						} else {
+							comment.Status = setting.COMMENT_STATUS_HIDDEN
+						}
+						if comment.Update("Status") == nil {
+							result["success"] = true
 						}
 					}
 				}
@@ -0,0 +1,85 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package attachment
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/avatar"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+// AvatarFilter serves avatars under /avatar/<uid>/<size>/<version>, uploaded avatars
+// are cropped and resized, default avatars are generated from user name.
+func AvatarFilter(ctx *context.Context) {
+	parts := strings.Split(strings.TrimPrefix(ctx.Request.URL.Path, "/avatar/"), "/")
+	if len(parts) != 3 {
+		return
+	}
+
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return
+	}
+	size, err := strconv.Atoi(parts[1])
+	if err != nil || !avatar.IsSize(size) {
+		return
+	}
+
+	user := models.User{Id: uid}
+	if err := user.Read(); err != nil {
+		return
+	}
+
+	// links of current version never change, links of old versions are cached shortly
+	version := user.AvatarVersion()
+	if parts[2] == version {
+		ctx.Output.Header("Cache-Control", "public, max-age=31536000")
+	} else {
+		ctx.Output.Header("Cache-Control", "public, max-age=600")
+	}
+
+	if user.AvatarType == setting.AvatarTypePersonalized && strings.HasPrefix(user.AvatarKey, "avatar/") {
+		key, mime, err := attachment.AvatarVariant(user.AvatarKey, size)
+		if err != nil {
+			beego.Error("AvatarFilter: ", user.AvatarKey, err)
+			return
+		}
+		ctx.Output.Header("Content-Type", mime)
+		serveFile(ctx, key)
+		return
+	}
+
+	etag := `"` + version + "-" + parts[1] + `"`
+	ctx.Output.Header("ETag", etag)
+	if ctx.Input.Header("If-None-Match") == etag {
+		ctx.Output.SetStatus(304)
+		return
+	}
+
+	data, err := avatar.Generate(setting.AvatarStyle, user.UserName, size)
+	if err != nil {
+		beego.Error("AvatarFilter: ", err)
+		return
+	}
+	ctx.Output.Header("Content-Type", "image/png")
+	ctx.Output.Body(data)
+}
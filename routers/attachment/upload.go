@@ -15,8 +15,10 @@
 package attachment
 
 import (
+	"fmt"
 	"github.com/varding/wetalk/setting"
 	"net/http"
+	"net/url"
 	"path"
 	"path/filepath"
 	"strings"
@@ -24,9 +26,12 @@ import (
 
 	"github.com/astaxie/beego"
 	"github.com/astaxie/beego/context"
+	"github.com/astaxie/beego/orm"
 
 	"github.com/varding/wetalk/modules/attachment"
 	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/ratelimit"
+	"github.com/varding/wetalk/modules/storage"
 	"github.com/varding/wetalk/routers/base"
 )
 
@@ -35,6 +40,10 @@ type UploadRouter struct {
 }
 
 func (this *UploadRouter) Post() {
+	if this.CheckRateLimit(ratelimit.ActionUpload) {
+		return
+	}
+
 	result := map[string]interface{}{
 		"success": false,
 	}
@@ -66,7 +75,14 @@ func (this *UploadRouter) Post() {
 
 	// save and resize image
 	if err := attachment.SaveImage(&image, file, mime, handler.Filename, t); err != nil {
-		beego.Error(err)
+		switch err {
+		case attachment.ErrImageQuota:
+			result["msg"] = this.Tr("editor.upload_quota_exceeded")
+		case attachment.ErrImageDailyLimit:
+			result["msg"] = this.Tr("editor.upload_daily_exceeded")
+		default:
+			beego.Error(err)
+		}
 		return
 	}
 
@@ -75,15 +91,99 @@ func (this *UploadRouter) Post() {
 
 }
 
+// File saves uploaded file of allowed types as attachment.
+func (this *UploadRouter) File() {
+	if this.CheckRateLimit(ratelimit.ActionUpload) {
+		return
+	}
+
+	result := map[string]interface{}{
+		"success": false,
+	}
+
+	defer func() {
+		this.Data["json"] = &result
+		this.ServeJson()
+	}()
+
+	// check permition
+	if !this.User.IsActive {
+		return
+	}
+
+	// get file object
+	file, handler, err := this.Ctx.Request.FormFile("file")
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	m := models.Attachment{}
+	m.User = &this.User
+
+	if err := attachment.SaveAttachment(&m, file, handler.Filename); err != nil {
+		switch err {
+		case attachment.ErrFileType:
+			result["msg"] = this.Tr("editor.upload_file_type")
+		case attachment.ErrFileSize:
+			result["msg"] = this.Tr("editor.upload_file_size")
+		default:
+			beego.Error(err)
+		}
+		return
+	}
+
+	result["link"] = m.Link()
+	result["name"] = m.Name
+	result["image"] = m.IsImage()
+	result["success"] = true
+}
+
+// AttachmentFilter serves attachment downloads under /attachment/<token>/<name>.
+func AttachmentFilter(ctx *context.Context) {
+	parts := strings.Split(strings.TrimPrefix(ctx.Request.URL.Path, "/attachment/"), "/")
+
+	m := models.Attachment{Token: parts[0]}
+	if err := m.Read("Token"); err != nil {
+		return
+	}
+
+	models.Attachments().Filter("Id", m.Id).Update(orm.Params{
+		"Downloads": orm.ColValue(orm.Col_Add, 1),
+	})
+
+	// images are shown in browsers, others are always downloaded
+	disposition := "attachment"
+	if m.IsImage() {
+		disposition = "inline"
+	}
+	ctx.Output.Header("Content-Type", m.Mime)
+	ctx.Output.Header("Content-Disposition", contentDisposition(disposition, m.Name))
+	ctx.Output.Header("X-Content-Type-Options", "nosniff")
+
+	serveFile(ctx, m.Key())
+}
+
+// contentDisposition encodes file name for all browsers, see RFC 6266.
+func contentDisposition(disposition, name string) string {
+	ascii := strings.Map(func(r rune) rune {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, ascii, strings.Replace(url.QueryEscape(name), "+", "%20", -1))
+}
+
 func ImageFilter(ctx *context.Context) {
 	token := path.Base(ctx.Request.RequestURI)
 
 	// split token and file ext
-	var filePath string
+	var fileName string
 	if i := strings.IndexRune(token, '.'); i == -1 {
 		return
 	} else {
-		filePath = token[i+1:]
+		fileName = token[i+1:]
 		token = token[:i]
 	}
 
@@ -94,15 +194,48 @@ func ImageFilter(ctx *context.Context) {
 		return
 	}
 
+	key, mime, err := attachment.ImageVariant(&image, fileName, ctx.Input.Header("Accept"))
+	if err != nil {
+		beego.Info(err)
+		return
+	}
+
+	// format of response depends on accepted formats of browser
+	ctx.Output.Header("Vary", "Accept")
+	ctx.Output.Header("Content-Type", mime)
+	serveFile(ctx, key)
+}
+
+// FileFilter serves files of storage under /files/, like self uploaded avatars.
+// only avatars and images are served here, attachments must go through
+// AttachmentFilter for their headers, other keys fall through to not found.
+func FileFilter(ctx *context.Context) {
+	key := strings.TrimPrefix(path.Clean(ctx.Request.URL.Path), "/files/")
+	if !strings.HasPrefix(key, "avatar/") && !strings.HasPrefix(key, "img/") {
+		return
+	}
+	serveFile(ctx, key)
+}
+
+// serveFile serves file of key in local storage,
+// files of other storages are redirected to their urls.
+func serveFile(ctx *context.Context, key string) {
+	local, ok := storage.Current().(*storage.Local)
+	if !ok {
+		ctx.Redirect(302, storage.Current().URL(key))
+		return
+	}
+
 	// file real path
-	filePath = attachment.GenImagePath(&image) + filePath
+	filePath := local.Path(key)
 
 	// if x-send on then set header and http status
 	// fall back use proxy serve file
 	if setting.ImageXSend {
-		ext := filepath.Ext(filePath)
-		ctx.Output.ContentType(ext)
-		ctx.Output.Header(setting.ImageXSendHeader, "/"+filePath)
+		if ctx.ResponseWriter.Header().Get("Content-Type") == "" {
+			ctx.Output.ContentType(filepath.Ext(filePath))
+		}
+		ctx.Output.Header(setting.ImageXSendHeader, "/"+strings.TrimPrefix(filepath.ToSlash(filePath), "/"))
 		ctx.Output.SetStatus(200)
 	} else {
 		// direct serve file use go
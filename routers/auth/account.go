@@ -0,0 +1,114 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/account"
+	"github.com/varding/wetalk/modules/auth"
+	"github.com/varding/wetalk/modules/models"
+)
+
+func (this *SettingsRouter) setAccount() {
+	var deletion models.AccountDeletion
+	if account.GetDeletion(&deletion, &this.User) {
+		this.Data["Deletion"] = &deletion
+	} else if _, ok := this.Data["AccountDeleteFormSets"]; !ok {
+		form := auth.AccountDeleteForm{}
+		this.SetFormSets(&form)
+	}
+}
+
+// Account implemented account export and deletion page.
+func (this *SettingsRouter) Account() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["AccountSetting"] = true
+	this.TplNames = "settings/account.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	this.setAccount()
+}
+
+// AccountSave implemented request and cancel account deletion.
+func (this *SettingsRouter) AccountSave() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["AccountSetting"] = true
+	this.TplNames = "settings/account.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	switch this.GetString("action") {
+	case "cancel":
+		if err := account.CancelDeletion(&this.User); err != nil {
+			beego.Error("AccountSave: cancel ", err)
+		}
+		this.FlashRedirect("/settings/account", 302, "DeletionCanceled")
+		return
+
+	case "delete":
+		if this.User.IsGhost {
+			this.Abort("403")
+			return
+		}
+
+		form := auth.AccountDeleteForm{User: &this.User}
+		if !this.ValidFormSets(&form) {
+			break
+		}
+
+		if _, err := account.RequestDeletion(&this.User, form.Reason); err != nil {
+			beego.Error("AccountSave: delete ", err)
+			break
+		}
+
+		// logout everywhere, login again can cancel the deletion
+		if err := auth.RevokeLoginSessions(&this.User, 0); err != nil {
+			beego.Error("AccountSave: revoke sessions ", err)
+		}
+		auth.LogoutUser(this.Ctx)
+		this.FlashRedirect("/login", 302, "DeletionRequested")
+		return
+	}
+
+	this.setAccount()
+}
+
+// AccountExport implemented download archive of personal data.
+func (this *SettingsRouter) AccountExport() {
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := account.Export(&this.User, &buf); err != nil {
+		beego.Error("AccountExport: ", err)
+		this.Abort("500")
+		return
+	}
+
+	filename := this.User.UserName + "-" + time.Now().Format("20060102") + ".zip"
+	this.Ctx.Output.Header("Content-Type", "application/zip")
+	this.Ctx.Output.Header("Content-Disposition", "attachment; filename="+filename)
+	this.Ctx.Output.Body(buf.Bytes())
+}
@@ -20,7 +20,9 @@ import (
 
 	"github.com/varding/wetalk/modules/auth"
 	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/ratelimit"
 	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/modules/webhook"
 	"github.com/varding/wetalk/routers/base"
 	"github.com/varding/wetalk/setting"
 )
@@ -52,6 +54,16 @@ func (this *LoginRouter) Get() {
 		this.Ctx.SetCookie("login_to", loginRedirect, 0, "/")
 	}
 
+	// show reason of suspension
+	if flash, ok := this.Data["flash"].(map[string]string); ok {
+		if id, _ := utils.StrTo(flash["UserSuspended"]).Int(); id > 0 {
+			b := models.Ban{Id: id}
+			if b.Read() == nil {
+				this.Data["SuspendBan"] = &b
+			}
+		}
+	}
+
 	form := auth.LoginForm{}
 	this.SetFormSets(&form)
 }
@@ -88,7 +100,14 @@ func (this *LoginRouter) Login() {
 		this.Data["ErrorReached"] = true
 
 	} else if auth.VerifyUser(&user, form.UserName, form.Password) {
-		loginRedirect := this.LoginUser(&user, form.Remember)
+		var loginRedirect string
+		if auth.HasTwoFactor(&user) {
+			// password is right, login after the second factor passed
+			auth.SetTwoFactorPending(this.CruSession, &user, form.Remember)
+			loginRedirect = "/login/twofactor"
+		} else {
+			loginRedirect = this.LoginUser(&user, form.Remember)
+		}
 
 		if this.IsAjax() {
 			this.Data["json"] = map[string]interface{}{
@@ -122,6 +141,7 @@ ajaxError:
 
 // Logout implemented user logout page.
 func (this *LoginRouter) Logout() {
+	auth.ClearTwoFactorPending(this.CruSession)
 	auth.LogoutUser(this.Ctx)
 
 	// write flash message
@@ -159,17 +179,22 @@ func (this *RegisterRouter) Register() {
 		return
 	}
 
-	form := auth.RegisterForm{Locale: this.Locale}
+	form := auth.RegisterForm{Locale: this.Locale, Ip: this.Ctx.Input.IP()}
 	// valid form and put errors to template context
 	if this.ValidFormSets(&form) == false {
 		return
 	}
 
+	if this.CheckRateLimit(ratelimit.ActionRegister) {
+		return
+	}
+
 	// Create new user.
 	user := new(models.User)
 
 	if err := auth.RegisterUser(user, form.UserName, form.Email, form.Password, this.Locale); err == nil {
 		auth.SendRegisterMail(this.Locale, user)
+		webhook.UserRegistered(user)
 
 		loginRedirect := this.LoginUser(user, false)
 		if loginRedirect == "/" {
@@ -304,6 +329,11 @@ func (this *ForgotRouter) ResetPost() {
 			beego.Error("ResetPost Save New Password: ", err)
 		}
 
+		// logout all devices of the account
+		if err := auth.RevokeLoginSessions(&user, 0); err != nil {
+			beego.Error("ResetPost Revoke Sessions: ", err)
+		}
+
 		if this.IsLogin {
 			auth.LogoutUser(this.Ctx)
 		}
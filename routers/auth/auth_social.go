@@ -40,6 +40,10 @@ func (p *socialAuther) IsUserLogin(ctx *context.Context) (int, bool) {
 func (p *socialAuther) LoginUser(ctx *context.Context, uid int) (string, error) {
 	user := models.User{Id: uid}
 	if user.Read() == nil {
+		if auth.HasTwoFactor(&user) {
+			auth.SetTwoFactorPending(ctx.Input.CruSession, &user, true)
+			return "/login/twofactor", nil
+		}
 		auth.LoginUser(&user, ctx, true)
 	}
 	return auth.GetLoginRedirect(ctx), nil
@@ -129,7 +133,7 @@ func (this *SocialAuthRouter) ConnectPost() {
 	formL := auth.OAuthLoginForm{}
 	this.SetFormSets(&formL)
 
-	formR := auth.OAuthRegisterForm{Locale: this.Locale}
+	formR := auth.OAuthRegisterForm{Locale: this.Locale, Ip: this.Ctx.Input.IP()}
 	this.SetFormSets(&formR)
 
 	action := this.GetString("action")
@@ -0,0 +1,79 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/auth"
+)
+
+func (this *SettingsRouter) setSessions() {
+	this.Data["LoginSessions"] = auth.UserLoginSessions(&this.User)
+	this.Data["CurrentSession"] = auth.GetLoginSessionId(this.CruSession)
+}
+
+// Sessions implemented login sessions page.
+func (this *SettingsRouter) Sessions() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["SessionsSetting"] = true
+	this.TplNames = "settings/sessions.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	this.setSessions()
+}
+
+// SessionsSave implemented revoke one or all login sessions.
+func (this *SettingsRouter) SessionsSave() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["SessionsSetting"] = true
+	this.TplNames = "settings/sessions.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	switch this.GetString("action") {
+	case "revoke":
+		id, _ := this.GetInt("id")
+		if int(id) == auth.GetLoginSessionId(this.CruSession) {
+			this.Redirect("/logout", 302)
+			return
+		}
+		if err := auth.RevokeLoginSession(&this.User, int(id)); err != nil {
+			beego.Error("SessionsSave: revoke ", err)
+		}
+		this.FlashRedirect("/settings/sessions", 302, "SessionRevoked")
+
+	case "revoke_others":
+		if err := auth.RevokeLoginSessions(&this.User, auth.GetLoginSessionId(this.CruSession)); err != nil {
+			beego.Error("SessionsSave: revoke others ", err)
+		}
+		this.FlashRedirect("/settings/sessions", 302, "SessionRevoked")
+
+	case "revoke_all":
+		if err := auth.RevokeLoginSessions(&this.User, 0); err != nil {
+			beego.Error("SessionsSave: revoke all ", err)
+		}
+		auth.LogoutUser(this.Ctx)
+		this.FlashRedirect("/login", 302, "HasLogout")
+
+	default:
+		this.setSessions()
+	}
+}
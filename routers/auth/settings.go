@@ -19,7 +19,6 @@ import (
 
 	"github.com/varding/wetalk/modules/auth"
 	"github.com/varding/wetalk/routers/base"
-	"github.com/varding/wetalk/setting"
 )
 
 // SettingsRouter serves user settings.
@@ -27,8 +26,18 @@ type SettingsRouter struct {
 	base.BaseRouter
 }
 
+// NestPrepare implemented settings pages are only for browser sessions, access
+// tokens can't change profile, email, password or other credentials.
+func (this *SettingsRouter) NestPrepare() {
+	if this.IsTokenAuth {
+		this.Abort("403")
+		return
+	}
+}
+
 func (this *SettingsRouter) ChangePassword() {
 	this.Data["IsUserSettingPage"] = true
+	this.Data["PasswordSetting"] = true
 	this.TplNames = "settings/change_password.html"
 
 	//need login
@@ -42,6 +51,7 @@ func (this *SettingsRouter) ChangePassword() {
 
 func (this *SettingsRouter) ChangePasswordSave() {
 	this.Data["IsUserSettingPage"] = true
+	this.Data["PasswordSetting"] = true
 	this.TplNames = "settings/change_password.html"
 	if this.CheckLoginRedirect() {
 		return
@@ -54,6 +64,8 @@ func (this *SettingsRouter) ChangePasswordSave() {
 	if this.ValidFormSets(&pwdForm) {
 		// verify success and save new password
 		if err := auth.SaveNewPassword(&this.User, pwdForm.Password); err == nil {
+			// other devices need login with the new password
+			auth.RevokeLoginSessions(&this.User, auth.GetLoginSessionId(this.CruSession))
 			this.FlashRedirect("/settings/change/password", 302, "PasswordSave")
 			return
 		} else {
@@ -64,6 +76,7 @@ func (this *SettingsRouter) ChangePasswordSave() {
 
 func (this *SettingsRouter) AvatarSetting() {
 	this.Data["IsUserSettingPage"] = true
+	this.Data["AvatarSetting"] = true
 	this.TplNames = "settings/user_avatar.html"
 	//need login
 	if this.CheckLoginRedirect() {
@@ -77,6 +90,7 @@ func (this *SettingsRouter) AvatarSetting() {
 
 func (this *SettingsRouter) AvatarSettingSave() {
 	this.Data["IsUserSettingPage"] = true
+	this.Data["AvatarSetting"] = true
 	this.TplNames = "settings/user_avatar.html"
 	//need login
 	if this.CheckLoginRedirect() {
@@ -98,6 +112,7 @@ func (this *SettingsRouter) AvatarSettingSave() {
 
 func (this *SettingsRouter) AvatarUpload() {
 	this.Data["IsUserSettingPage"] = true
+	this.Data["AvatarSetting"] = true
 	this.TplNames = "settings/user_avatar.html"
 	//need login and active
 	if this.CheckLoginRedirect() {
@@ -111,7 +126,7 @@ func (this *SettingsRouter) AvatarUpload() {
 	}
 	defer file.Close()
 	mime := handler.Header.Get("Content-Type")
-	if err := auth.UploadUserAvatarToQiniu(file, handler.Filename, mime, setting.QiniuAvatarBucket, &this.User); err != nil {
+	if err := auth.UploadUserAvatar(file, handler.Filename, mime, &this.User); err != nil {
 		return
 	}
 
@@ -124,6 +139,7 @@ func (this *SettingsRouter) AvatarUpload() {
 // Profile implemented user profile settings page.
 func (this *SettingsRouter) Profile() {
 	this.Data["IsUserSettingPage"] = true
+	this.Data["ProfileSetting"] = true
 	this.TplNames = "settings/profile.html"
 
 	// need login
@@ -140,6 +156,7 @@ func (this *SettingsRouter) Profile() {
 // ProfileSave implemented save user profile.
 func (this *SettingsRouter) ProfileSave() {
 	this.Data["IsUserSettingPage"] = true
+	this.Data["ProfileSetting"] = true
 	this.TplNames = "settings/profile.html"
 	if this.CheckLoginRedirect() {
 		return
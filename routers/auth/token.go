@@ -0,0 +1,317 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/auth"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/routers/base"
+	"github.com/varding/wetalk/setting"
+)
+
+func (this *SettingsRouter) setTokens() {
+	var tokens []models.AccessToken
+	qs := models.AccessTokens().Filter("User", this.User.Id).Filter("IsRevoked", false).RelatedSel("App")
+	models.ListObjects(qs, &tokens)
+	this.Data["Tokens"] = tokens
+}
+
+// Tokens implemented personal access tokens page.
+func (this *SettingsRouter) Tokens() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["TokensSetting"] = true
+	this.TplNames = "settings/tokens.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	form := auth.AccessTokenForm{User: &this.User}
+	this.SetFormSets(&form)
+	this.setTokens()
+}
+
+// TokensSave implemented create and revoke personal access tokens.
+func (this *SettingsRouter) TokensSave() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["TokensSetting"] = true
+	this.TplNames = "settings/tokens.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	defer this.setTokens()
+
+	switch this.GetString("action") {
+	case "revoke":
+		id, _ := this.GetInt("id")
+		if err := auth.RevokeAccessToken(&this.User, int(id)); err != nil {
+			beego.Error("TokensSave: revoke ", err)
+		}
+		this.FlashRedirect("/settings/tokens", 302, "TokenRevoked")
+		return
+	}
+
+	form := auth.AccessTokenForm{User: &this.User}
+	if !this.ValidFormSets(&form) {
+		return
+	}
+
+	var token models.AccessToken
+	raw, err := auth.CreateAccessToken(&token, &this.User, form.Name, form.Scopes, setting.TokenLiveDays)
+	if err != nil {
+		beego.Error("TokensSave: create ", err)
+		return
+	}
+
+	// raw token only show once, do not redirect
+	this.Data["NewToken"] = raw
+	form = auth.AccessTokenForm{User: &this.User}
+	this.SetFormSets(&form)
+}
+
+func (this *SettingsRouter) setApps() {
+	var apps []models.OAuthApp
+	models.ListObjects(models.OAuthApps().Filter("User", this.User.Id), &apps)
+	this.Data["Apps"] = apps
+}
+
+// Applications implemented oauth applications page.
+func (this *SettingsRouter) Applications() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["ApplicationsSetting"] = true
+	this.TplNames = "settings/applications.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	form := auth.OAuthAppForm{}
+	this.SetFormSets(&form)
+	this.setApps()
+}
+
+// ApplicationsSave implemented register and delete oauth applications.
+func (this *SettingsRouter) ApplicationsSave() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["ApplicationsSetting"] = true
+	this.TplNames = "settings/applications.html"
+
+	if this.CheckActiveRedirect() {
+		return
+	}
+
+	defer this.setApps()
+
+	switch this.GetString("action") {
+	case "delete":
+		id, _ := this.GetInt("id")
+		app := models.OAuthApp{Id: int(id)}
+		if app.Read() == nil && app.User.Id == this.User.Id {
+			// revoke all tokens issued to this app
+			models.AccessTokens().Filter("App", app.Id).Delete()
+			models.OAuthCodes().Filter("App", app.Id).Delete()
+			if err := app.Delete(); err != nil {
+				beego.Error("ApplicationsSave: delete ", err)
+			}
+		}
+		this.FlashRedirect("/settings/applications", 302, "AppDeleted")
+		return
+	}
+
+	form := auth.OAuthAppForm{}
+	if !this.ValidFormSets(&form) {
+		return
+	}
+
+	var app models.OAuthApp
+	form.SetToApp(&app)
+	secret, err := auth.CreateOAuthApp(&app, &this.User)
+	if err != nil {
+		beego.Error("ApplicationsSave: create ", err)
+		return
+	}
+
+	// client secret only show once
+	this.Data["NewApp"] = &app
+	this.Data["NewSecret"] = secret
+	form = auth.OAuthAppForm{}
+	this.SetFormSets(&form)
+}
+
+// OAuthProviderRouter serves oauth2 authorization page for third-party apps.
+type OAuthProviderRouter struct {
+	base.BaseRouter
+}
+
+// read and check authorize request params
+func (this *OAuthProviderRouter) readRequest(app *models.OAuthApp) bool {
+	app.ClientId = this.GetString("client_id")
+	if len(app.ClientId) == 0 || app.Read("ClientId") != nil {
+		this.Data["InvalidClient"] = true
+		return false
+	}
+
+	redirectUri := this.GetString("redirect_uri")
+	if !auth.IsValidRedirectUri(app, redirectUri) {
+		this.Data["InvalidClient"] = true
+		return false
+	}
+
+	scopes := auth.CleanScopes(strings.FieldsFunc(this.GetString("scope"), isScopeSep))
+	if len(scopes) == 0 {
+		scopes = auth.ScopeRead
+	}
+
+	this.Data["App"] = app
+	this.Data["ClientId"] = app.ClientId
+	this.Data["RedirectUri"] = redirectUri
+	this.Data["State"] = this.GetString("state")
+	this.Data["Scope"] = scopes
+	return true
+}
+
+func isScopeSep(r rune) bool {
+	return r == ' ' || r == ','
+}
+
+// redirect back to app with query params
+func (this *OAuthProviderRouter) redirectBack(app *models.OAuthApp, params url.Values) {
+	uri := this.GetString("redirect_uri")
+	if len(uri) == 0 {
+		uri = app.RedirectUri
+	}
+	if state := this.GetString("state"); len(state) > 0 {
+		params.Set("state", state)
+	}
+	sep := "?"
+	if strings.IndexRune(uri, '?') != -1 {
+		sep = "&"
+	}
+	this.Redirect(uri+sep+params.Encode(), 302)
+}
+
+// Authorize implemented oauth2 authorization consent page.
+func (this *OAuthProviderRouter) Authorize() {
+	this.TplNames = "auth/authorize.html"
+
+	if this.CheckActiveRedirect() {
+		return
+	}
+
+	if this.GetString("response_type") != "code" {
+		this.Data["InvalidClient"] = true
+		return
+	}
+
+	var app models.OAuthApp
+	this.readRequest(&app)
+}
+
+// AuthorizeSubmit implemented user approve or deny an oauth2 app.
+func (this *OAuthProviderRouter) AuthorizeSubmit() {
+	this.TplNames = "auth/authorize.html"
+
+	if this.CheckActiveRedirect() {
+		return
+	}
+
+	if this.IsTokenAuth {
+		this.Abort("403")
+		return
+	}
+
+	var app models.OAuthApp
+	if !this.readRequest(&app) {
+		return
+	}
+
+	if this.GetString("action") != "approve" {
+		this.redirectBack(&app, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	scopes := strings.Split(this.Data["Scope"].(string), ",")
+	code, err := auth.CreateOAuthCode(&app, &this.User, scopes, this.GetString("redirect_uri"))
+	if err != nil {
+		beego.Error("AuthorizeSubmit: ", err)
+		this.redirectBack(&app, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	this.redirectBack(&app, url.Values{"code": {code}})
+}
+
+// OAuthTokenRouter serves oauth2 token endpoint.
+type OAuthTokenRouter struct {
+	base.BaseRouter
+}
+
+// token endpoint is called by app servers, they have no xsrf cookie
+func (this *OAuthTokenRouter) CheckXsrfCookie() bool {
+	return true
+}
+
+// Post implemented exchange authorization code for access token.
+func (this *OAuthTokenRouter) Post() {
+	result := map[string]interface{}{}
+
+	defer func() {
+		if _, ok := result["error"]; ok {
+			this.Ctx.Output.SetStatus(400)
+		}
+		this.Data["json"] = result
+		this.ServeJson()
+	}()
+
+	if this.GetString("grant_type") != "authorization_code" {
+		result["error"] = "unsupported_grant_type"
+		return
+	}
+
+	clientId, secret, ok := this.Ctx.Request.BasicAuth()
+	if !ok {
+		clientId = this.GetString("client_id")
+		secret = this.GetString("client_secret")
+	}
+
+	var app models.OAuthApp
+	if !auth.VerifyOAuthClient(&app, clientId, secret) {
+		result["error"] = "invalid_client"
+		return
+	}
+
+	var token models.AccessToken
+	raw, err := auth.ExchangeOAuthCode(&token, &app, this.GetString("code"), this.GetString("redirect_uri"))
+	if err != nil {
+		beego.Info("OAuthToken: ", err)
+		result["error"] = "invalid_grant"
+		return
+	}
+
+	result["access_token"] = raw
+	result["token_type"] = "bearer"
+	result["scope"] = token.Scopes
+	if !token.Expires.IsZero() {
+		result["expires_in"] = int(token.Expires.Sub(time.Now()).Seconds())
+	}
+}
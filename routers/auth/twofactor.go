@@ -0,0 +1,193 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"html/template"
+
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/auth"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/setting"
+)
+
+// TwoFactor implemented second step of login page.
+func (this *LoginRouter) TwoFactor() {
+	this.Data["IsLoginPage"] = true
+	this.TplNames = "auth/twofactor.html"
+
+	if this.CheckLoginRedirect(false) {
+		return
+	}
+
+	var user models.User
+	if _, ok := auth.GetTwoFactorPending(&user, this.CruSession); !ok {
+		this.Redirect("/login", 302)
+		return
+	}
+
+	form := auth.TwoFactorLoginForm{}
+	this.SetFormSets(&form)
+}
+
+// TwoFactorPost implemented login with totp or recovery code.
+func (this *LoginRouter) TwoFactorPost() {
+	this.Data["IsLoginPage"] = true
+	this.TplNames = "auth/twofactor.html"
+
+	if this.CheckLoginRedirect(false) {
+		return
+	}
+
+	var user models.User
+	remember, ok := auth.GetTwoFactorPending(&user, this.CruSession)
+	if !ok {
+		this.Redirect("/login", 302)
+		return
+	}
+
+	form := auth.TwoFactorLoginForm{}
+	if this.ValidFormSets(&form) == false {
+		return
+	}
+
+	key := "auth.twofactor." + utils.ToStr(user.Id)
+	if times, ok := utils.TimesReachedTest(key, setting.LoginMaxRetries); ok {
+		this.Data["ErrorReached"] = true
+	} else {
+		var tf models.TwoFactor
+		if auth.GetTwoFactor(&tf, &user) && (auth.VerifyTotp(&tf, form.Code) || auth.UseRecoveryCode(&user, form.Code)) {
+			auth.ClearTwoFactorPending(this.CruSession)
+			loginRedirect := this.LoginUser(&user, remember)
+			this.Redirect(loginRedirect, 302)
+			return
+		}
+		utils.TimesReachedSet(key, times, setting.LoginFailedBlocks)
+	}
+	this.Data["Error"] = true
+}
+
+func (this *SettingsRouter) setTwoFactor() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["TwoFactorSetting"] = true
+	this.TplNames = "settings/twofactor.html"
+}
+
+// data of two-factor page, enroll with a new secret when it is not enabled
+func (this *SettingsRouter) setTwoFactorData() {
+	if auth.HasTwoFactor(&this.User) {
+		this.Data["TwoFactorEnabled"] = true
+		this.Data["RecoveryCount"] = auth.CountRecoveryCodes(&this.User)
+
+		if _, ok := this.Data["TwoFactorPasswordFormSets"]; !ok {
+			form := auth.TwoFactorPasswordForm{}
+			this.SetFormSets(&form)
+		}
+		return
+	}
+
+	// secret is kept in session until the code is checked
+	secret, _ := this.GetSession("twofactor_secret").(string)
+	if secret == "" {
+		secret = auth.GenerateTotpSecret()
+		this.SetSession("twofactor_secret", secret)
+	}
+	this.Data["Secret"] = secret
+
+	if img, err := auth.TotpQRCode(auth.TotpUri(&this.User, secret)); err == nil {
+		this.Data["QRCode"] = template.URL(img)
+	} else {
+		beego.Error("TwoFactor: qrcode ", err)
+	}
+
+	if _, ok := this.Data["TwoFactorEnableFormSets"]; !ok {
+		form := auth.TwoFactorEnableForm{}
+		this.SetFormSets(&form)
+	}
+}
+
+// rewrite remember cookie of current browser, two-factor secret signs it
+func (this *SettingsRouter) refreshRememberCookie() {
+	if this.Ctx.GetCookie(setting.CookieUserName) != "" {
+		auth.WriteRememberCookie(&this.User, this.Ctx)
+	}
+}
+
+// TwoFactor implemented two-factor setting page.
+func (this *SettingsRouter) TwoFactor() {
+	this.setTwoFactor()
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	this.setTwoFactorData()
+}
+
+// TwoFactorSave implemented enable and disable two-factor, regenerate recovery codes.
+func (this *SettingsRouter) TwoFactorSave() {
+	this.setTwoFactor()
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	defer this.setTwoFactorData()
+
+	switch this.GetString("action") {
+	case "enable":
+		secret, _ := this.GetSession("twofactor_secret").(string)
+		form := auth.TwoFactorEnableForm{Secret: secret}
+		if !this.ValidFormSets(&form) {
+			return
+		}
+
+		codes, err := auth.EnableTwoFactor(&this.User, secret)
+		if err != nil {
+			beego.Error("TwoFactorSave: enable ", err)
+			return
+		}
+		this.DelSession("twofactor_secret")
+		this.refreshRememberCookie()
+
+		// recovery codes only show once, do not redirect
+		this.Data["RecoveryCodes"] = codes
+
+	case "disable", "recovery":
+		form := auth.TwoFactorPasswordForm{User: &this.User}
+		if !this.ValidFormSets(&form) {
+			return
+		}
+
+		if this.GetString("action") == "recovery" {
+			codes, err := auth.CreateRecoveryCodes(&this.User)
+			if err != nil {
+				beego.Error("TwoFactorSave: recovery ", err)
+				return
+			}
+			this.Data["RecoveryCodes"] = codes
+			return
+		}
+
+		if err := auth.DisableTwoFactor(&this.User); err != nil {
+			beego.Error("TwoFactorSave: disable ", err)
+			return
+		}
+		this.refreshRememberCookie()
+		this.FlashRedirect("/settings/twofactor", 302, "TwoFactorDisabled")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/setting"
+)
+
+// Uploads implemented uploaded images page with usage of quotas.
+func (this *SettingsRouter) Uploads() {
+	this.Data["IsUserSettingPage"] = true
+	this.Data["UploadsSetting"] = true
+	this.TplNames = "settings/uploads.html"
+
+	if this.CheckLoginRedirect() {
+		return
+	}
+
+	count, used, _ := attachment.ImageUsage(&this.User)
+	today, _ := attachment.TodayImageUploads(&this.User)
+	this.Data["ImageCount"] = count
+	this.Data["ImageUsed"] = used
+	this.Data["ImageQuota"] = setting.ImageUserQuota
+	this.Data["TodayUploads"] = today
+	this.Data["DailyUploads"] = setting.ImageDailyUploads
+
+	var images []*models.Image
+	qs := models.Images().Filter("User", this.User.Id)
+	pager := this.SetPaginator(20, count)
+	qs.Limit(20, pager.Offset()).All(&images)
+	this.Data["Images"] = images
+}
@@ -90,7 +90,7 @@ func (this *UserRouter) Home() {
 	var favPosts []models.Post
 	favNums, _ := user.FavoritePosts().Limit(8).OrderBy("-Created").ValuesFlat(&favPostIds, "Post")
 	if favNums > 0 {
-		qs := models.Posts().Filter("Id__in", favPostIds)
+		qs := models.PublicPosts().Filter("Id__in", favPostIds)
 		qs = qs.OrderBy("-Created").RelatedSel()
 		models.ListObjects(qs, &favPosts)
 	}
@@ -272,7 +272,7 @@ func (this *UserRouter) FavoritePosts() {
 	var posts []models.Post
 	nums, _ := user.FavoritePosts().OrderBy("-Created").ValuesFlat(&postIds, "Post")
 	if nums > 0 {
-		qs := models.Posts().Filter("Id__in", postIds)
+		qs := models.PublicPosts().Filter("Id__in", postIds)
 		cnt, _ := models.CountObjects(qs)
 		pager := this.SetPaginator(setting.PostCountPerPage, cnt)
 		qs = qs.OrderBy("-Created").Limit(setting.PostCountPerPage, pager.Offset()).RelatedSel()
@@ -18,6 +18,7 @@ package routers
 import (
 	"fmt"
 	"github.com/astaxie/beego"
+	"github.com/varding/wetalk/modules/ratelimit"
 	"github.com/varding/wetalk/routers/admin"
 	"github.com/varding/wetalk/routers/api"
 	"github.com/varding/wetalk/routers/attachment"
@@ -30,14 +31,17 @@ import (
 
 func Initialize() {
 	/* Add Filters */
-	if setting.QiniuServiceEnabled {
-		beego.InsertFilter("/img/*", beego.BeforeRouter, attachment.QiniuImageFilter)
-	} else {
-		beego.InsertFilter("/img/*", beego.BeforeRouter, attachment.ImageFilter)
-	}
+	beego.InsertFilter("/img/*", beego.BeforeRouter, attachment.ImageFilter)
+	beego.InsertFilter("/files/*", beego.BeforeRouter, attachment.FileFilter)
+	beego.InsertFilter("/avatar/*", beego.BeforeRouter, attachment.AvatarFilter)
+	beego.InsertFilter("/attachment/*", beego.BeforeRouter, attachment.AttachmentFilter)
 
 	beego.InsertFilter("/captcha/*", beego.BeforeRouter, setting.Captcha.Handler)
 
+	beego.InsertFilter("/api/*", beego.BeforeRouter, ratelimit.Filter(ratelimit.ActionApi, "/api/playground"))
+	beego.InsertFilter("/api/playground", beego.BeforeRouter, ratelimit.Filter(ratelimit.ActionPlay))
+	beego.InsertFilter("/oauth/token", beego.BeforeRouter, ratelimit.Filter(ratelimit.ActionApi))
+
 	beego.InsertFilter("/login/*/access", beego.BeforeRouter, auth.OAuthAccess)
 	beego.InsertFilter("/login/*", beego.BeforeRouter, auth.OAuthRedirect)
 
@@ -54,6 +58,9 @@ func Initialize() {
 	beego.Router("/post/:post([0-9]+)", postR, "get:SinglePost;post:SinglePostCommentSubmit")
 	beego.Router("/post/:post([0-9]+)/edit", postR, "get:EditPost;post:EditPostSubmit")
 
+	reportR := new(post.ReportRouter)
+	beego.Router("/report/:kind(post|comment|user)/:id([0-9]+)", reportR, "get:Report;post:ReportSubmit")
+
 	noticeRouter := new(post.NoticeRouter)
 	beego.Router("/notification", noticeRouter, "get:Get")
 
@@ -73,6 +80,7 @@ func Initialize() {
 
 	login := new(auth.LoginRouter)
 	beego.Router("/login", login, "get:Get;post:Login")
+	beego.Router("/login/twofactor", login, "get:TwoFactor;post:TwoFactorPost")
 	beego.Router("/logout", login, "get:Logout")
 
 	//socialR := new(auth.SocialAuthRouter)
@@ -88,18 +96,25 @@ func Initialize() {
 	beego.Router("/settings/change/password", settings, "get:ChangePassword;post:ChangePasswordSave")
 	beego.Router("/settings/avatar", settings, "get:AvatarSetting;post:AvatarSettingSave")
 	beego.Router("/settings/avatar/upload", settings, "post:AvatarUpload")
+	beego.Router("/settings/twofactor", settings, "get:TwoFactor;post:TwoFactorSave")
+	beego.Router("/settings/sessions", settings, "get:Sessions;post:SessionsSave")
+	beego.Router("/settings/account", settings, "get:Account;post:AccountSave")
+	beego.Router("/settings/account/export", settings, "get:AccountExport")
+	beego.Router("/settings/uploads", settings, "get:Uploads")
+	beego.Router("/settings/tokens", settings, "get:Tokens;post:TokensSave")
+	beego.Router("/settings/applications", settings, "get:Applications;post:ApplicationsSave")
+
+	oauthR := new(auth.OAuthProviderRouter)
+	beego.Router("/oauth/authorize", oauthR, "get:Authorize;post:AuthorizeSubmit")
+	beego.Router("/oauth/token", new(auth.OAuthTokenRouter), "post:Post")
 
 	forgot := new(auth.ForgotRouter)
 	beego.Router("/forgot", forgot)
 	beego.Router("/reset/:code([0-9a-zA-Z]+)", forgot, "get:Reset;post:ResetPost")
 
-	if setting.QiniuServiceEnabled {
-		upload := new(attachment.QiniuUploadRouter)
-		beego.Router("/upload", upload, "post:Post")
-	} else {
-		upload := new(attachment.UploadRouter)
-		beego.Router("/upload", upload, "post:Post")
-	}
+	upload := new(attachment.UploadRouter)
+	beego.Router("/upload", upload, "post:Post")
+	beego.Router("/upload/file", upload, "post:File")
 
 	//download
 
@@ -108,23 +123,45 @@ func Initialize() {
 	beego.Router("/api/user", apiR, "post:Users")
 	beego.Router("/api/md", apiR, "post:Markdown")
 	beego.Router("/api/post", apiR, "post:Post")
+	beego.Router("/api/playground", apiR, "post:Playground")
 
 	/* Admin Routers */
 	adminDashboard := new(admin.AdminDashboardRouter)
 	beego.Router("/admin", adminDashboard)
 
+	moderationR := new(admin.ModerationAdminRouter)
+	beego.Router("/admin/moderation", moderationR, "get:List")
+	beego.Router("/admin/moderation/:id([0-9]+)", moderationR, "post:Handle")
+
+	reportAdminR := new(admin.ReportAdminRouter)
+	beego.Router("/admin/report", reportAdminR, "get:List;post:Bulk")
+
+	auditR := new(admin.AuditAdminRouter)
+	beego.Router("/admin/audit", auditR, "get:List")
+	beego.Router("/admin/audit/export", auditR, "get:Export")
+
+	imageAdminR := new(admin.ImageAdminRouter)
+	beego.Router("/admin/image/cleanup", imageAdminR, "get:Cleanup;post:CleanupRun")
+
 	adminR := new(admin.AdminRouter)
 	beego.Router("/admin/model/get", adminR, "post:ModelGet")
 	beego.Router("/admin/model/select", adminR, "post:ModelSelect")
 
+	// registered models are served by one generic router
+	modelR := new(admin.ModelRouter)
+	for _, name := range admin.ModelAdmins() {
+		beego.Router(fmt.Sprintf("/admin/:model(%s)", name), modelR, "get:List")
+		beego.Router(fmt.Sprintf("/admin/:model(%s)/bulk", name), modelR, "post:Bulk")
+		beego.Router(fmt.Sprintf("/admin/:model(%s)/export", name), modelR, "get:Export")
+		beego.Router(fmt.Sprintf("/admin/:model(%s)/import", name), modelR, "get:ImportForm;post:Import")
+		beego.Router(fmt.Sprintf("/admin/:model(%s)/:id(new)", name), modelR, "get:Create;post:Save")
+		beego.Router(fmt.Sprintf("/admin/:model(%s)/:id([0-9]+)", name), modelR, "get:Edit;post:Update")
+		beego.Router(fmt.Sprintf("/admin/:model(%s)/:id([0-9]+)/:action(delete)", name), modelR, "get:Confirm;post:Delete")
+	}
+
 	routes := map[string]beego.ControllerInterface{
-		"user":     new(admin.UserAdminRouter),
-		"post":     new(admin.PostAdminRouter),
-		"comment":  new(admin.CommentAdminRouter),
-		"topic":    new(admin.TopicAdminRouter),
-		"category": new(admin.CategoryAdminRouter),
-		"page":     new(admin.PageAdminRouter),
-		"bulletin": new(admin.BulletinAdminRouter),
+		"webhook": new(admin.WebhookAdminRouter),
+		"ban":     new(admin.BanAdminRouter),
 	}
 	for name, router := range routes {
 		beego.Router(fmt.Sprintf("/admin/:model(%s)", name), router, "get:List")
@@ -132,6 +169,8 @@ func Initialize() {
 		beego.Router(fmt.Sprintf("/admin/:model(%s)/:id([0-9]+)", name), router, "get:Edit;post:Update")
 		beego.Router(fmt.Sprintf("/admin/:model(%s)/:id([0-9]+)/:action(delete)", name), router, "get:Confirm;post:Delete")
 	}
+	beego.Router("/admin/:model(webhook)/:id([0-9]+)/:action(redeliver)", routes["webhook"], "post:Redeliver")
+
 	pageR := new(page.PageRouter)
 	beego.Router("/:slug", pageR, "get:Show")
 
@@ -27,8 +27,11 @@ import (
 	"github.com/astaxie/beego/validation"
 	"github.com/beego/i18n"
 
+	"github.com/varding/wetalk/modules/account"
 	"github.com/varding/wetalk/modules/auth"
+	"github.com/varding/wetalk/modules/ban"
 	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/ratelimit"
 	"github.com/varding/wetalk/modules/utils"
 	"github.com/varding/wetalk/setting"
 )
@@ -43,6 +46,13 @@ type BaseRouter struct {
 	i18n.Locale
 	User    models.User
 	IsLogin bool
+
+	// set when user logined by an access token
+	Token       models.AccessToken
+	IsTokenAuth bool
+
+	// set when logined user is muted
+	MuteBan *models.Ban
 }
 
 // Prepare implemented Prepare method for baseRouter.
@@ -69,9 +79,18 @@ func (this *BaseRouter) Prepare() {
 	}
 
 	switch {
-	// save logined user if exist in session
-	case auth.GetUserFromSession(&this.User, this.CruSession):
+	// save logined user if request carries a bearer access token
+	case auth.LoginUserFromToken(&this.User, &this.Token, this.Ctx):
 		this.IsLogin = true
+		this.IsTokenAuth = true
+	// save logined user if exist in session, unless the login session is revoked
+	case auth.GetUserFromSession(&this.User, this.CruSession):
+		if auth.CheckLoginSession(&this.User, this.Ctx) {
+			this.IsLogin = true
+		} else {
+			auth.LogoutUser(this.Ctx)
+			this.User = models.User{}
+		}
 	// save logined user if exist in remember cookie
 	case auth.LoginUserFromRememberCookie(&this.User, this.Ctx):
 		this.IsLogin = true
@@ -88,6 +107,23 @@ func (this *BaseRouter) Prepare() {
 			this.FlashRedirect("/login", 302, "UserForbid")
 			return
 		}
+
+		// suspended user is logged out, muted user can only read
+		if b := ban.UserBan(&this.User); b != nil {
+			if !b.IsMute() {
+				auth.LogoutUser(this.Ctx)
+				this.FlashRedirect("/login", 302, "UserSuspended", utils.ToStr(b.Id))
+				return
+			}
+			this.MuteBan = b
+			this.Data["MuteBan"] = b
+		}
+
+		// token without write scope only can read
+		if this.IsTokenAuth && !this.isSafeMethod() && !auth.TokenAllows(&this.Token, auth.ScopeWrite) {
+			this.Abort("403")
+			return
+		}
 	}
 
 	// Setting properties.
@@ -98,6 +134,7 @@ func (this *BaseRouter) Prepare() {
 	this.Data["AvatarURL"] = setting.AvatarURL
 	this.Data["IsProMode"] = setting.IsProMode
 	this.Data["SearchEnabled"] = setting.SearchEnabled
+	this.Data["PlaygroundEnabled"] = setting.PlaygroundEnabled
 
 	// Redirect to make URL clean.
 	if this.setLang() {
@@ -147,6 +184,12 @@ func (this *BaseRouter) LoginUser(user *models.User, remember bool) string {
 
 	this.setLangCookie(i18n.GetLangByIndex(user.Lang))
 
+	// show pending deletion, user may cancel it
+	var deletion models.AccountDeletion
+	if account.GetDeletion(&deletion, user) {
+		loginRedirect = "/settings/account"
+	}
+
 	return loginRedirect
 }
 
@@ -447,9 +490,57 @@ func (this *BaseRouter) SetFormError(form interface{}, fieldName, errMsg string,
 
 // check xsrf and show a friendly page
 func (this *BaseRouter) CheckXsrfCookie() bool {
+	// token requests carry no cookie, the bearer token itself proves the origin
+	if this.IsTokenAuth {
+		return true
+	}
 	return this.Controller.CheckXsrfCookie()
 }
 
+// check token scope, always true when user logined by session
+func (this *BaseRouter) CheckTokenScope(scope string) bool {
+	if !this.IsTokenAuth {
+		return true
+	}
+	return auth.TokenAllows(&this.Token, scope)
+}
+
+// check rate limit of action, write 429 and return true if limited
+func (this *BaseRouter) CheckRateLimit(action string) bool {
+	if this.IsLogin && this.User.IsAdmin {
+		return false
+	}
+	if ok, wait := ratelimit.Allow(action, this.User.Id, this.Ctx.Input.IP()); !ok {
+		ratelimit.Deny(this.Ctx, wait)
+		return true
+	}
+	return false
+}
+
+// check user is muted, write 403 for ajax and return true if muted
+func (this *BaseRouter) CheckMuted() bool {
+	if this.MuteBan == nil {
+		return false
+	}
+	if this.IsAjax() {
+		this.Ctx.Output.SetStatus(403)
+		this.Data["json"] = map[string]interface{}{
+			"success": false,
+			"message": this.Tr("auth.user_muted"),
+		}
+		this.ServeJson()
+	}
+	return true
+}
+
+func (this *BaseRouter) isSafeMethod() bool {
+	switch this.Ctx.Request.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
 func (this *BaseRouter) SystemException() {
 
 }
@@ -17,7 +17,11 @@ package post
 import (
 	"github.com/astaxie/beego"
 	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/perm"
 	"github.com/varding/wetalk/modules/post"
+	"github.com/varding/wetalk/modules/ratelimit"
+	"github.com/varding/wetalk/modules/spam"
+	"github.com/varding/wetalk/modules/webhook"
 	"github.com/varding/wetalk/routers/base"
 	"github.com/varding/wetalk/setting"
 )
@@ -44,7 +48,7 @@ func (this *PostListRouter) setTopicsOfCategory(topics *[]models.Topic, category
 
 //Get new best posts
 func (this *PostListRouter) setNewBestPosts(posts *[]models.Post) {
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	qs = qs.Filter("IsBest", true).OrderBy("-Created").Limit(10)
 	models.ListObjects(qs, posts)
 	this.Data["NewBestPosts"] = posts
@@ -52,7 +56,7 @@ func (this *PostListRouter) setNewBestPosts(posts *[]models.Post) {
 
 //Get new best posts by category
 func (this *PostListRouter) setNewBestPostsOfCategory(posts *[]models.Post, cat *models.Category) {
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	qs = qs.Filter("IsBest", true).Filter("Category__id", cat.Id).OrderBy("-Created").Limit(10)
 	models.ListObjects(qs, posts)
 	this.Data["NewBestPosts"] = posts
@@ -60,7 +64,7 @@ func (this *PostListRouter) setNewBestPostsOfCategory(posts *[]models.Post, cat
 
 //Get new best posts by topic
 func (this *PostListRouter) setNewBestPostsOfTopic(posts *[]models.Post, topic *models.Topic) {
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	qs = qs.Filter("IsBest", true).Filter("Topic__id", topic.Id).OrderBy("-Created").Limit(10)
 	models.ListObjects(qs, posts)
 	this.Data["NewBestPosts"] = posts
@@ -68,7 +72,7 @@ func (this *PostListRouter) setNewBestPostsOfTopic(posts *[]models.Post, topic *
 
 //Get most replys posts
 func (this *PostListRouter) setMostReplysPosts(posts *[]models.Post) {
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	qs = qs.Filter("Replys__gt", 0).OrderBy("-Created", "-Replys").Limit(10)
 	models.ListObjects(qs, posts)
 	this.Data["MostReplysPosts"] = posts
@@ -76,7 +80,7 @@ func (this *PostListRouter) setMostReplysPosts(posts *[]models.Post) {
 
 //Get most replys posts of category
 func (this *PostListRouter) setMostReplysPostsOfCategory(posts *[]models.Post, cat *models.Category) {
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	qs = qs.Filter("Category__id", cat.Id).Filter("Replys__gt", 0).OrderBy("-Created", "-Replys").Limit(10)
 	models.ListObjects(qs, posts)
 	this.Data["MostReplysPosts"] = posts
@@ -84,7 +88,7 @@ func (this *PostListRouter) setMostReplysPostsOfCategory(posts *[]models.Post, c
 
 //Get most replys post of topic
 func (this *PostListRouter) setMostReplysPostsOfTopic(posts *[]models.Post, topic *models.Topic) {
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	qs = qs.Filter("Topic__id", topic.Id).Filter("Replys__gt", 0).OrderBy("-Created", "-Replys").Limit(10)
 	models.ListObjects(qs, posts)
 	this.Data["MostReplysPosts"] = posts
@@ -125,7 +129,7 @@ func (this *PostListRouter) Home() {
 
 	//get posts by Created datetime desc order
 	var posts []models.Post
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	cnt, _ := models.CountObjects(qs)
 	pager := this.SetPaginator(setting.PostCountPerPage, cnt)
 	qs = qs.OrderBy("-LastReplied").Limit(setting.PostCountPerPage, pager.Offset()).RelatedSel()
@@ -152,7 +156,7 @@ func (this *PostListRouter) Navs() {
 
 	sortSlug := this.GetString(":sortSlug")
 	var posts []models.Post
-	qs := models.Posts()
+	qs := models.PublicPosts()
 	cnt, _ := models.CountObjects(qs)
 	pager := this.SetPaginator(setting.PostCountPerPage, cnt)
 	switch sortSlug {
@@ -196,7 +200,7 @@ func (this *PostListRouter) Category() {
 		return
 	}
 	//get posts by category slug, order by Created desc
-	qs := models.Posts().Filter("Category", &cat)
+	qs := models.PublicPosts().Filter("Category", &cat)
 	cnt, _ := models.CountObjects(qs)
 	pager := this.SetPaginator(setting.PostCountPerPage, cnt)
 	qs = qs.OrderBy("-LastReplied").Limit(setting.PostCountPerPage, pager.Offset()).RelatedSel()
@@ -231,7 +235,7 @@ func (this *PostListRouter) CatNavs() {
 		this.Abort("404")
 		return
 	}
-	qs := models.Posts().Filter("Category", &cat)
+	qs := models.PublicPosts().Filter("Category", &cat)
 	cnt, _ := models.CountObjects(qs)
 	pager := this.SetPaginator(setting.PostCountPerPage, cnt)
 	switch sortSlug {
@@ -285,7 +289,7 @@ func (this *PostListRouter) Topic() {
 	}
 
 	//get posts by topic
-	qs := models.Posts().Filter("Topic", &topic)
+	qs := models.PublicPosts().Filter("Topic", &topic)
 	cnt, _ := models.CountObjects(qs)
 	pager := this.SetPaginator(setting.PostCountPerPage, cnt)
 	qs = qs.OrderBy("-LastReplied").Limit(setting.PostCountPerPage, pager.Offset()).RelatedSel()
@@ -436,10 +440,32 @@ func (this *PostRouter) NewPostSubmit() {
 		return
 	}
 
-	var post models.Post
-	if err := form.SavePost(&post, &this.User); err == nil {
+	if this.CheckMuted() {
+		return
+	}
+
+	if this.CheckRateLimit(ratelimit.ActionPost) {
+		return
+	}
+
+	form.Ip = this.Ctx.Input.IP()
+	form.UserAgent = this.Ctx.Input.UserAgent()
+
+	var postMd models.Post
+	switch err := form.SavePost(&postMd, &this.User); err {
+	case nil:
+		webhook.PostCreated(&postMd, &this.User)
+		this.JsStorage("deleteKey", "post/new")
+		this.Redirect(postMd.Link(), 302)
+	case spam.ErrHeld:
+		this.Data["PostHeld"] = true
 		this.JsStorage("deleteKey", "post/new")
-		this.Redirect(post.Link(), 302)
+		form = post.PostForm{Locale: this.Locale, Category: form.Category, Topic: form.Topic, Topics: form.Topics}
+		this.SetFormSets(&form)
+	case spam.ErrRejected:
+		this.SetFormError(&form, "Content", "post.content_rejected")
+	default:
+		beego.Error("NewPostSubmit: ", err)
 	}
 }
 
@@ -453,7 +479,8 @@ func (this *PostRouter) loadPost(post *models.Post, user *models.User) bool {
 		qs.RelatedSel(1).One(post)
 	}
 
-	if post.Id == 0 {
+	// hidden post only can be seen by moderator
+	if post.Id == 0 || post.IsHidden && !perm.Can(&this.User, perm.PostHide, perm.ScopeOfPost(post)) {
 		this.Abort("404")
 		return true
 	}
@@ -463,6 +490,20 @@ func (this *PostRouter) loadPost(post *models.Post, user *models.User) bool {
 	return false
 }
 
+// load post for edit, author or moderator with post.edit permission
+func (this *PostRouter) loadEditPost(post *models.Post) bool {
+	if this.loadPost(post, nil) {
+		return true
+	}
+
+	if post.User.Id != this.User.Id && !perm.Can(&this.User, perm.PostEdit, perm.ScopeOfPost(post)) {
+		this.Abort("404")
+		return true
+	}
+
+	return false
+}
+
 func (this *PostRouter) loadComments(post *models.Post, comments *[]*models.Comment) {
 	qs := post.Comments()
 	if num, err := qs.RelatedSel("User").OrderBy("Id").All(comments); err == nil {
@@ -529,14 +570,42 @@ func (this *PostRouter) SinglePostCommentSubmit() {
 		return
 	}
 
+	// locked post only accepts comments from moderator
+	if postMd.IsLocked && !perm.Can(&this.User, perm.PostLock, perm.ScopeOfPost(&postMd)) {
+		this.SetFormError(&form, "Message", "post.post_locked")
+		return
+	}
+
+	if this.CheckMuted() {
+		return
+	}
+
+	if this.CheckRateLimit(ratelimit.ActionComment) {
+		return
+	}
+
+	form.Ip = this.Ctx.Input.IP()
+	form.UserAgent = this.Ctx.Input.UserAgent()
+
 	comment := models.Comment{}
-	if err := form.SaveComment(&comment, &this.User, &postMd); err == nil {
+	switch err := form.SaveComment(&comment, &this.User, &postMd); err {
+	case nil:
 		post.FilterCommentMentions(&this.User, &postMd, &comment)
+		webhook.CommentCreated(&comment, &postMd, &this.User)
 		this.JsStorage("deleteKey", "post/comment")
 		this.Redirect(postMd.Link(), 302)
 		redir = true
 
 		post.PostReplysCount(&postMd)
+	case spam.ErrHeld:
+		this.Data["CommentHeld"] = true
+		this.JsStorage("deleteKey", "post/comment")
+		form = post.CommentForm{}
+		this.SetFormSets(&form)
+	case spam.ErrRejected:
+		this.SetFormError(&form, "Message", "post.content_rejected")
+	default:
+		beego.Error("SinglePostCommentSubmit: ", err)
 	}
 }
 
@@ -548,7 +617,7 @@ func (this *PostRouter) EditPost() {
 	}
 
 	var postMd models.Post
-	if this.loadPost(&postMd, &this.User) {
+	if this.loadEditPost(&postMd) {
 		return
 	}
 
@@ -569,12 +638,13 @@ func (this *PostRouter) EditPostSubmit() {
 	}
 
 	var postMd models.Post
-	if this.loadPost(&postMd, &this.User) {
+	if this.loadEditPost(&postMd) {
 		return
 	}
 
-	if !postMd.CanEdit {
+	if !postMd.CanEdit && !perm.Can(&this.User, perm.PostEdit, perm.ScopeOfPost(&postMd)) {
 		this.FlashRedirect(postMd.Path(), 302, "CanNotEditPost")
+		return
 	}
 
 	form := post.PostForm{}
@@ -584,8 +654,27 @@ func (this *PostRouter) EditPostSubmit() {
 		return
 	}
 
-	if err := form.UpdatePost(&postMd, &this.User); err == nil {
+	if this.CheckMuted() {
+		return
+	}
+
+	form.Ip = this.Ctx.Input.IP()
+	form.UserAgent = this.Ctx.Input.UserAgent()
+
+	switch err := form.UpdatePost(&postMd, &this.User); err {
+	case nil:
+		webhook.PostEdited(&postMd, &this.User)
 		this.JsStorage("deleteKey", "post/edit")
 		this.Redirect(postMd.Link(), 302)
+	case spam.ErrHeld:
+		this.Data["PostEditHeld"] = true
+		this.JsStorage("deleteKey", "post/edit")
+		form = post.PostForm{Locale: this.Locale, Topics: form.Topics}
+		form.SetFromPost(&postMd)
+		this.SetFormSets(&form)
+	case spam.ErrRejected:
+		this.SetFormError(&form, "Content", "post.content_rejected")
+	default:
+		beego.Error("EditPostSubmit: ", err)
 	}
 }
@@ -0,0 +1,99 @@
+// Copyright 2013 wetalk authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package post
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/varding/wetalk/modules/models"
+	"github.com/varding/wetalk/modules/post"
+	"github.com/varding/wetalk/modules/ratelimit"
+	"github.com/varding/wetalk/modules/utils"
+	"github.com/varding/wetalk/routers/base"
+)
+
+// ReportRouter serves reports of posts, comments and users.
+type ReportRouter struct {
+	base.BaseRouter
+}
+
+// load report target from url, return true if aborted
+func (this *ReportRouter) loadTarget(report *models.Report) bool {
+	kind := this.GetString(":kind")
+	id, _ := utils.StrTo(this.GetString(":id")).Int()
+	if err := post.SetReportTarget(report, kind, id); err != nil {
+		this.Abort("404")
+		return true
+	}
+	this.Data["Report"] = report
+	return false
+}
+
+// Report implemented report form page.
+func (this *ReportRouter) Report() {
+	this.TplNames = "post/report.html"
+
+	if this.CheckActiveRedirect() {
+		return
+	}
+
+	var report models.Report
+	if this.loadTarget(&report) {
+		return
+	}
+
+	form := post.ReportForm{}
+	this.SetFormSets(&form)
+}
+
+// ReportSubmit implemented save report.
+func (this *ReportRouter) ReportSubmit() {
+	this.TplNames = "post/report.html"
+
+	if this.CheckActiveRedirect() {
+		return
+	}
+
+	var report models.Report
+	if this.loadTarget(&report) {
+		return
+	}
+
+	form := post.ReportForm{}
+	if !this.ValidFormSets(&form) {
+		return
+	}
+
+	if report.User.Id == this.User.Id {
+		this.SetFormError(&form, "Reason", "post.report_self")
+		return
+	}
+
+	if post.HasOpenReport(&this.User, report.Kind, report.TargetId) {
+		this.SetFormError(&form, "Reason", "post.report_duplicate")
+		return
+	}
+
+	if this.CheckRateLimit(ratelimit.ActionReport) {
+		return
+	}
+
+	if err := form.SaveReport(&report, &this.User); err != nil {
+		beego.Error("ReportSubmit: ", err)
+		return
+	}
+
+	this.FlashRedirect(this.Ctx.Request.RequestURI, 302, "ReportSuccess")
+}
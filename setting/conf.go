@@ -21,6 +21,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +37,8 @@ import (
 	"github.com/beego/i18n"
 	"github.com/beego/social-auth"
 	"github.com/beego/social-auth/apps"
+
+	sharedcache "github.com/varding/wetalk/modules/cache"
 )
 
 const (
@@ -49,6 +53,7 @@ var (
 	AppLogo             string
 	EnforceRedirect     bool
 	AvatarURL           string
+	AvatarStyle         string
 	SecretKey           string
 	IsProMode           bool
 	ActiveCodeLives     int
@@ -58,8 +63,18 @@ var (
 	DateTimeShortFormat string
 	TimeZone            string
 	RealtimeRenderMD    bool
+	MarkdownProfiles    map[string][]string
+	MarkdownCacheSize   int
+	MarkdownCacheTTL    int
+	MarkdownCacheShared bool
 	ImageSizeSmall      int
 	ImageSizeMiddle     int
+	ImageSizes          []int
+	ImageWebpEncoder    string
+	ImageAvifEncoder    string
+	ImageUserQuota      int64
+	ImageDailyUploads   int
+	ImageUnusedDays     int
 	ImageLinkAlphabets  []byte
 	ImageXSend          bool
 	ImageXSendHeader    string
@@ -72,6 +87,71 @@ var (
 	CookieRememberName string
 	CookieUserName     string
 
+	// admins and moderators must enable two-factor login to open admin pages
+	TwoFactorEnforceAdmin bool
+
+	// account deletion
+	AccountDeleteGraceDays int
+	AccountGhostUser       string
+
+	// password hashing and policy
+	PasswordHasher        string
+	PasswordBcryptCost    int
+	PasswordArgon2Time    int
+	PasswordArgon2Memory  int
+	PasswordArgon2Threads int
+	PasswordMinLength     int
+	PasswordBreachedList  string
+
+	// access token and oauth2 provider
+	TokenMaxPerUser    int
+	TokenLiveDays      int
+	OAuthCodeLives     int
+	OAuthTokenLiveDays int
+
+	// outgoing webhooks
+	WebhookEnabled      bool
+	WebhookTimeout      int
+	WebhookMaxAttempts  int
+	WebhookRetryBackoff int
+
+	// rate limits of write actions, action name => "<requests>/<seconds>"
+	RateLimitEnabled bool
+	RateLimits       map[string]string
+
+	// content check
+	SpamEnabled         bool
+	SpamMaxLinks        int
+	SpamNewUserHours    int
+	SpamNewUserMaxLinks int
+	SpamDuplicateHours  int
+	AkismetKey          string
+	AkismetEndpoint     string
+	AkismetTimeout      int
+
+	// cards of links in posts
+	UnfurlEnabled     bool
+	UnfurlAllowHosts  []string
+	UnfurlTimeout     int
+	UnfurlWaitMillis  int
+	UnfurlMaxLinks    int
+	UnfurlMaxBodySize int64
+	UnfurlCacheHours  int
+	UnfurlRetryHours  int
+
+	// go playground
+	PlaygroundEnabled      bool
+	PlaygroundEndpoint     string
+	PlaygroundShareURL     string
+	PlaygroundTimeout      int
+	PlaygroundMaxCodeSize  int64
+	PlaygroundCacheMinutes int
+
+	// daily statistics for admin dashboard
+	StatsInterval     int
+	StatsBackfillDays int
+	StatsRanges       []int
+
 	// search
 	SearchEnabled bool
 
@@ -105,6 +185,22 @@ var (
 	QiniuAvatarDomain   string
 )
 
+var (
+	AttachmentAllow       map[string]int64
+	AttachmentOrphanHours int
+)
+
+var (
+	StorageBackend   string
+	StorageLocalRoot string
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3PublicUrl      string
+)
+
 var (
 	PostCountPerPage int
 )
@@ -130,11 +226,47 @@ const (
 const (
 	NOTICE_TYPE_COMMENT   = 1
 	NOTICE_TYPE_FAVOURITE = 2
+	NOTICE_TYPE_REPORT    = 3
 
 	NOTICE_UNREAD = 1
 	NOTICE_READ   = 2
 )
 
+const (
+	SPAM_ACTION_HOLD   = 1
+	SPAM_ACTION_REJECT = 2
+)
+
+const (
+	HELD_PENDING = iota
+	HELD_APPROVED
+	HELD_REJECTED
+)
+
+const (
+	COMMENT_STATUS_NORMAL = 0
+	COMMENT_STATUS_HIDDEN = 1
+)
+
+const (
+	REPORT_REASON_SPAM = iota + 1
+	REPORT_REASON_ABUSE
+	REPORT_REASON_OFFTOPIC
+	REPORT_REASON_ILLEGAL
+	REPORT_REASON_OTHER
+)
+
+const (
+	REPORT_OPEN = iota
+	REPORT_RESOLVED
+	REPORT_DISMISSED
+)
+
+const (
+	BAN_SUSPEND = iota + 1
+	BAN_MUTE
+)
+
 var (
 	// Social Auth
 	GithubAuth *apps.Github
@@ -200,7 +332,11 @@ func LoadConfig() *goconfig.ConfigFile {
 	IsProMode = beego.RunMode == "pro"
 
 	// cache system
-	Cache, err = cache.NewCache("memory", `{"interval":360}`)
+	Cache, err = newCache()
+	if err != nil {
+		fmt.Println("Fail to create cache: " + err.Error())
+		os.Exit(2)
+	}
 
 	Captcha = captcha.NewCaptcha("/captcha/", Cache)
 	Captcha.FieldIdName = "CaptchaId"
@@ -267,6 +403,35 @@ func LoadConfig() *goconfig.ConfigFile {
 	return Cfg
 }
 
+// newCache creates cache of adapter in [cache], all adapters except memory
+// can be shared by app instances.
+func newCache() (cache.Cache, error) {
+	prefix := Cfg.MustValue("cache", "prefix", "wetalk:")
+	interval := Cfg.MustInt("cache", "interval", 360)
+	timeout := time.Duration(Cfg.MustInt("cache", "timeout_seconds", 3)) * time.Second
+
+	switch adapter := Cfg.MustValue("cache", "adapter", "memory"); adapter {
+	case "memory":
+		return cache.NewCache("memory", fmt.Sprintf(`{"interval":%d}`, interval))
+	case "file":
+		dir := Cfg.MustValue("cache", "file_dir", "cache")
+		return sharedcache.NewFileCache(dir, prefix, time.Duration(interval)*time.Second)
+	case "redis":
+		return sharedcache.NewRedisCache(Cfg.MustValue("cache", "redis_conn", "127.0.0.1:6379"),
+			Cfg.MustValue("cache", "redis_password"), Cfg.MustInt("cache", "redis_db", 0), prefix, timeout)
+	case "memcache":
+		var servers []string
+		for _, server := range strings.Split(Cfg.MustValue("cache", "memcache_conn", "127.0.0.1:11211"), ",") {
+			if server = strings.TrimSpace(server); server != "" {
+				servers = append(servers, server)
+			}
+		}
+		return sharedcache.NewMemcacheCache(servers, prefix, timeout)
+	default:
+		return nil, fmt.Errorf("unknown cache adapter %q", adapter)
+	}
+}
+
 func reloadConfig() {
 	AppName = Cfg.MustValue("app", "app_name", "WeTalk Community")
 	beego.AppName = AppName
@@ -275,6 +440,7 @@ func reloadConfig() {
 	AppUrl = Cfg.MustValue("app", "app_url", "http://127.0.0.1:8092/")
 	AppLogo = Cfg.MustValue("app", "app_logo", "/static/img/logo.gif")
 	AvatarURL = Cfg.MustValue("app", "avatar_url")
+	AvatarStyle = Cfg.MustValue("app", "avatar_style", "identicon")
 
 	EnforceRedirect = Cfg.MustBool("app", "enforce_redirect")
 
@@ -299,6 +465,101 @@ func reloadConfig() {
 
 	RealtimeRenderMD = Cfg.MustBool("app", "realtime_render_markdown")
 
+	// features of markdown rendering in each context
+	MarkdownProfiles = make(map[string][]string)
+	for profile, features := range map[string]string{
+		"post":         "html, highlight, emoji, tasklist, anchor, footnote, unfurl",
+		"comment":      "html, highlight, emoji, tasklist",
+		"page":         "html, highlight, emoji, tasklist, anchor, footnote, unfurl",
+		"notification": "emoji",
+	} {
+		var names []string
+		for _, name := range strings.Split(Cfg.MustValue("markdown", profile, features), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		MarkdownProfiles[profile] = names
+	}
+	MarkdownCacheSize = Cfg.MustInt("markdown", "cache_size", 10000)
+	MarkdownCacheTTL = Cfg.MustInt("markdown", "cache_minutes", 60) * 60
+	MarkdownCacheShared = Cfg.MustBool("markdown", "cache_shared", false)
+
+	TwoFactorEnforceAdmin = Cfg.MustBool("twofactor", "enforce_admin", false)
+
+	AccountDeleteGraceDays = Cfg.MustInt("account", "delete_grace_days", 14)
+	AccountGhostUser = Cfg.MustValue("account", "ghost_user", "ghost")
+
+	PasswordHasher = Cfg.MustValue("password", "hasher", "bcrypt")
+	PasswordBcryptCost = Cfg.MustInt("password", "bcrypt_cost", 10)
+	PasswordArgon2Time = Cfg.MustInt("password", "argon2_time", 1)
+	PasswordArgon2Memory = Cfg.MustInt("password", "argon2_memory", 65536)
+	PasswordArgon2Threads = Cfg.MustInt("password", "argon2_threads", 2)
+	PasswordMinLength = Cfg.MustInt("password", "min_length", 8)
+	PasswordBreachedList = Cfg.MustValue("password", "breached_list", "conf/global/breached_passwords.txt")
+
+	TokenMaxPerUser = Cfg.MustInt("token", "max_tokens_per_user", 10)
+	TokenLiveDays = Cfg.MustInt("token", "token_live_days", 0)
+	OAuthCodeLives = Cfg.MustInt("token", "oauth_code_live_minutes", 10)
+	OAuthTokenLiveDays = Cfg.MustInt("token", "oauth_token_live_days", 90)
+
+	WebhookEnabled = Cfg.MustBool("webhook", "enabled", true)
+	WebhookTimeout = Cfg.MustInt("webhook", "timeout_seconds", 10)
+	WebhookMaxAttempts = Cfg.MustInt("webhook", "max_attempts", 5)
+	WebhookRetryBackoff = Cfg.MustInt("webhook", "retry_backoff_seconds", 60)
+
+	RateLimitEnabled = Cfg.MustBool("ratelimit", "enabled", true)
+	RateLimits = make(map[string]string)
+	if section, err := Cfg.GetSection("ratelimit"); err == nil {
+		for key, value := range section {
+			if key != "enabled" {
+				RateLimits[key] = value
+			}
+		}
+	}
+
+	SpamEnabled = Cfg.MustBool("spam", "enabled", true)
+	SpamMaxLinks = Cfg.MustInt("spam", "max_links", 5)
+	SpamNewUserHours = Cfg.MustInt("spam", "new_user_hours", 24)
+	SpamNewUserMaxLinks = Cfg.MustInt("spam", "new_user_max_links", 0)
+	SpamDuplicateHours = Cfg.MustInt("spam", "duplicate_hours", 24)
+	AkismetKey = Cfg.MustValue("spam", "akismet_key")
+	AkismetEndpoint = Cfg.MustValue("spam", "akismet_endpoint", "https://rest.akismet.com/1.1/comment-check")
+	AkismetTimeout = Cfg.MustInt("spam", "akismet_timeout_seconds", 5)
+
+	UnfurlEnabled = Cfg.MustBool("unfurl", "enabled", true)
+	UnfurlAllowHosts = UnfurlAllowHosts[:0]
+	for _, host := range strings.Split(Cfg.MustValue("unfurl", "allow_hosts"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			UnfurlAllowHosts = append(UnfurlAllowHosts, host)
+		}
+	}
+	UnfurlTimeout = Cfg.MustInt("unfurl", "timeout_seconds", 3)
+	UnfurlWaitMillis = Cfg.MustInt("unfurl", "wait_ms", 1000)
+	UnfurlMaxLinks = Cfg.MustInt("unfurl", "max_links", 5)
+	UnfurlMaxBodySize = int64(Cfg.MustInt("unfurl", "max_body_kb", 512)) << 10
+	UnfurlCacheHours = Cfg.MustInt("unfurl", "cache_hours", 168)
+	UnfurlRetryHours = Cfg.MustInt("unfurl", "retry_hours", 24)
+
+	PlaygroundEnabled = Cfg.MustBool("playground", "enabled", true)
+	PlaygroundEndpoint = Cfg.MustValue("playground", "endpoint", "https://play.golang.org")
+	PlaygroundShareURL = Cfg.MustValue("playground", "share_url", PlaygroundEndpoint)
+	PlaygroundTimeout = Cfg.MustInt("playground", "timeout_seconds", 10)
+	PlaygroundMaxCodeSize = int64(Cfg.MustInt("playground", "max_code_kb", 64)) << 10
+	PlaygroundCacheMinutes = Cfg.MustInt("playground", "cache_minutes", 1440)
+
+	StatsInterval = Cfg.MustInt("stats", "interval_minutes", 60)
+	StatsBackfillDays = Cfg.MustInt("stats", "backfill_days", 90)
+	StatsRanges = StatsRanges[:0]
+	for _, value := range strings.Split(Cfg.MustValue("stats", "ranges", "7,30,90"), ",") {
+		if days, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && days > 0 {
+			StatsRanges = append(StatsRanges, days)
+		}
+	}
+	if len(StatsRanges) == 0 {
+		StatsRanges = []int{7, 30, 90}
+	}
+
 	ImageSizeSmall = Cfg.MustInt("image", "image_size_small")
 	ImageSizeMiddle = Cfg.MustInt("image", "image_size_middle")
 
@@ -310,6 +571,24 @@ func reloadConfig() {
 		ImageSizeMiddle = ImageSizeSmall + 400
 	}
 
+	// widths of resized images, small and middle are always included
+	ImageSizes = []int{ImageSizeSmall, ImageSizeMiddle}
+	for _, size := range strings.Split(Cfg.MustValue("image", "image_sizes"), ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil || width <= 0 || width == ImageSizeSmall || width == ImageSizeMiddle {
+			continue
+		}
+		ImageSizes = append(ImageSizes, width)
+	}
+	sort.Ints(ImageSizes)
+
+	ImageWebpEncoder = Cfg.MustValue("image", "webp_encoder")
+	ImageAvifEncoder = Cfg.MustValue("image", "avif_encoder")
+
+	ImageUserQuota = int64(Cfg.MustInt("image", "user_quota_mb")) << 20
+	ImageDailyUploads = Cfg.MustInt("image", "daily_uploads")
+	ImageUnusedDays = Cfg.MustInt("image", "unused_days", 7)
+
 	str := Cfg.MustValue("image", "image_link_alphabets")
 	if len(str) == 0 {
 		str = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -351,6 +630,36 @@ func reloadConfig() {
 	QiniuAvatarBucket = Cfg.MustValue("qiniu", "qiniu_avatar_bucket")
 	QiniuAvatarDomain = Cfg.MustValue("qiniu", "qiniu_avatar_domain")
 
+	// <mime>:<max KiB> of allowed attachments
+	AttachmentAllow = make(map[string]int64)
+	for _, allow := range strings.Split(Cfg.MustValue("attachment", "allow"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(allow), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if size, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err == nil && size > 0 {
+			AttachmentAllow[strings.ToLower(strings.TrimSpace(parts[0]))] = size * 1024
+		}
+	}
+	AttachmentOrphanHours = Cfg.MustInt("attachment", "orphan_hours", 24)
+
+	// storage of uploaded files, qiniu is used when it was enabled before
+	StorageBackend = Cfg.MustValue("storage", "backend")
+	if StorageBackend == "" {
+		if QiniuServiceEnabled {
+			StorageBackend = "qiniu"
+		} else {
+			StorageBackend = "local"
+		}
+	}
+	StorageLocalRoot = Cfg.MustValue("storage", "local_root", "upload")
+	S3Endpoint = Cfg.MustValue("s3", "endpoint")
+	S3Region = Cfg.MustValue("s3", "region", "us-east-1")
+	S3Bucket = Cfg.MustValue("s3", "bucket")
+	S3AccessKey = Cfg.MustValue("s3", "access_key")
+	S3SecretKey = Cfg.MustValue("s3", "secret_key")
+	S3PublicUrl = Cfg.MustValue("s3", "public_url")
+
 	//post
 	PostCountPerPage = Cfg.MustInt("post", "post_count_per_page", 20)
 }
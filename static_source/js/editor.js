@@ -361,7 +361,7 @@
                     if(n == cache) return;
 
                     cache = n;
-                    $.post(url, {'action': 'preview', 'content': n}, function(data){
+                    $.post(url, {'action': 'preview', 'content': n, 'profile': $editor.data('preview-profile') || 'post'}, function(data){
                         if(data.success){
                             $preview.html(data.preview);
                             if($preview.mdFilter){
@@ -372,6 +372,22 @@
                 }
             });
 
+            $editor.on('click', '[data-meta=gofmt]', function(){
+                var $e = $(this);
+                if($e.hasClass('disabled')) return;
+                $.post(url, {'action': 'gofmt', 'content': $textarea.val()}, function(data){
+                    if(data.success){
+                        if(data.content !== $textarea.val()){
+                            $textarea.val(data.content).trigger('autosize.resize');
+                            undoManager.save();
+                        }
+                        if(data.errors){
+                            alert($e.data('failed') + '\n\n' + data.errors.join('\n'));
+                        }
+                    }
+                });
+            });
+
             $textarea.on('keypress', function(e){
                 if ((e.ctrlKey || e.metaKey) && (e.keyCode == 89 || e.keyCode == 90)) {
                     e.preventDefault();
@@ -504,6 +520,43 @@
                 });
             });
 
+            $editor.find('[data-meta=file]').popover({
+                'html': true,
+                'container': $editor,
+                'title': $editor.find('[rel=file-popover-title]').html(),
+                'content': $editor.find('[rel=file-popover-content]').html()
+            });
+
+            $editor.on('submit', '.md-file-form', function(){
+                var $form = $(this);
+                var $err = $form.find('.alert-danger').hide();
+                if(onUpload || $form.find('[rel=filename]').val() === ''){
+                    return false;
+                }
+                onUpload = true;
+                $form.ajaxSubmit({
+                    dataType: 'json',
+                    success: function(data) {
+                        onUpload = false;
+                        if(data && data.success){
+                            var sel = getSelection(te);
+                            var text = (data.image ? "![" : "[") + data.name + "](" + data.link + ")";
+                            insertText(text, sel.start+text.length);
+                            $(popup).popover('hide');
+                        } else {
+                            $err.text(data && data.msg ? data.msg : $err.data('message'));
+                            $err.show();
+                        }
+                    },
+                    error: function(){
+                        onUpload = false;
+                        $err.text($err.data('message'));
+                        $err.show();
+                    }
+                });
+                return false;
+            });
+
             $editor.on('click', '[data-meta=code]', function(){
                 var sel = getSelection(te);
                 if(sel.start != sel.end){
@@ -202,11 +202,63 @@
 				});
 			});
 
-			var $pre = $e.find('pre > code').parent();
+			var $pre = $e.find('pre > code').parent().not('.highlight');
 			$pre.addClass("prettyprint");
 			prettyPrint();
+
+			// run and share buttons of go code
+			var toolbar = $('script[rel=playground-toolbar]').html();
+			if(toolbar){
+				$e.find('pre.highlight > code.language-go').parent().each(function(_,pre){
+					var $pre = $(pre);
+					if(!$pre.next().hasClass('playground')){
+						$pre.after('<div class="playground">'+toolbar+'</div>');
+					}
+				});
+			}
 		};
 
+		$(document).on('click', '[rel^=playground-]', function(){
+			var $btn = $(this);
+			var $play = $btn.parents('.playground:first');
+			var $code = $play.prev('pre').find('code');
+			var $output = $play.find('.playground-output');
+			var action = $btn.attr('rel').replace('playground-', '');
+			var code = $code.data('code') || $code.text();
+			$btn.button('loading');
+			$.post('/api/playground', {action: action, code: code}, function(data){
+				if(data.errors){
+					$output.addClass('error').text(data.errors).show();
+				} else {
+					$output.removeClass('error');
+				}
+				if(!data.success){
+					return;
+				}
+				switch(action){
+				case 'run':
+					var out = data.output;
+					if(data.vet_errors){
+						out = data.vet_errors + '\n' + out;
+					}
+					if(data.status){
+						out += '\n' + $output.data('exited').replace('%s', data.status);
+					}
+					$output.text(out).show();
+					break;
+				case 'format':
+					// highlighted html is lost, keep formatted code for running
+					$code.data('code', data.code).text(data.code);
+					break;
+				case 'share':
+					$output.html($('<a target="_blank">').attr('href', data.url).text(data.url)).show();
+					break;
+				}
+			}).complete(function(){
+				$btn.button('reset');
+			});
+		});
+
 	})();
 
 	$(document).on('click', '[rel=user-follow],[rel=user-unfollow]', function(){
@@ -16,15 +16,22 @@
 package main
 
 import (
+	"os"
+
 	"github.com/astaxie/beego"
 	"github.com/beego/social-auth"
 
 	"github.com/astaxie/beego/orm"
+	"github.com/varding/wetalk/modules/account"
+	"github.com/varding/wetalk/modules/attachment"
+	"github.com/varding/wetalk/modules/markdown"
+	"github.com/varding/wetalk/modules/stats"
+	"github.com/varding/wetalk/modules/unfurl"
+	"github.com/varding/wetalk/modules/webhook"
 	"github.com/varding/wetalk/routers"
 	"github.com/varding/wetalk/routers/auth"
 	"github.com/varding/wetalk/setting"
 	_ "github.com/go-sql-driver/mysql"
-	. "github.com/qiniu/api.v6/conf"
 )
 
 // We have to call a initialize function manully
@@ -49,15 +56,17 @@ func initialize() {
 	setting.SocialAuth.ConnectFailedURL = "/settings/profile"
 	setting.SocialAuth.ConnectRegisterURL = "/register/connect"
 	setting.SocialAuth.LoginURL = "/login"
-
-	//Qiniu
-	ACCESS_KEY = setting.QiniuAccessKey
-	SECRET_KEY = setting.QiniuSecurityKey
 }
 
 func main() {
 	initialize()
 
+	// run maintenance command instead of web server
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
 	beego.Info("AppPath:", beego.AppPath)
 
 	if setting.IsProMode {
@@ -67,8 +76,23 @@ func main() {
 	}
 	beego.Info(beego.AppName, setting.APP_VER, setting.AppUrl)
 
+	// render cards of links in posts
+	markdown.RegisterExtension("unfurl", unfurl.Extension)
+
 	//initialize the routers
 	routers.Initialize()
+
+	// retry failed webhook deliveries
+	webhook.StartRetryWorker()
+
+	// aggregate daily statistics for admin dashboard
+	stats.StartWorker()
+
+	// delete accounts passed grace period
+	account.StartWorker()
+
+	// remove uploaded files not linked by posts or comments
+	attachment.StartWorker()
 	if !setting.IsProMode {
 		beego.SetStaticPath("/static_source", "static_source")
 		beego.DirectoryIndex = true